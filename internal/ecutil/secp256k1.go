@@ -0,0 +1,250 @@
+package ecutil
+
+import (
+	"crypto/elliptic"
+	"math/big"
+	"sync"
+)
+
+// Secp256k1Name is the name of the secp256k1 curve, as it appears in
+// the curve parameters, as well as the "crv" field of JWKs (RFC 8812)
+const Secp256k1Name = "secp256k1"
+
+// secp256k1Curve implements elliptic.Curve for secp256k1 (SEC 2, Section 2.4.1).
+//
+// The generic implementation in crypto/elliptic assumes a = -3, which
+// does not hold for secp256k1 (y² = x³ + 7), so the arithmetic is
+// implemented here using Jacobian coordinates. This implementation
+// is not constant-time, and therefore must only be used with public
+// values (i.e. verifying signatures and validating public keys). It
+// must never be used for operations involving private keys, such as
+// signing, key generation or key agreement. This is why jwk rejects
+// secp256k1 private keys, and ES256K is only supported for verification.
+type secp256k1Curve struct {
+	params *elliptic.CurveParams
+}
+
+var secp256k1Once sync.Once
+var secp256k1 *secp256k1Curve
+
+func initSecp256k1() {
+	params := &elliptic.CurveParams{Name: Secp256k1Name, BitSize: 256}
+	params.P, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	params.N, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	params.B = big.NewInt(7)
+	params.Gx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+	params.Gy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+	secp256k1 = &secp256k1Curve{params: params}
+}
+
+// Secp256k1 returns an elliptic.Curve which implements secp256k1
+func Secp256k1() elliptic.Curve {
+	secp256k1Once.Do(initSecp256k1)
+	return secp256k1
+}
+
+// IsSecp256k1 returns true if the curve is secp256k1. Curves are
+// compared by name, so that implementations of secp256k1 from other
+// libraries are also recognized
+func IsSecp256k1(crv elliptic.Curve) bool {
+	return crv != nil && crv.Params() != nil && crv.Params().Name == Secp256k1Name
+}
+
+func (c *secp256k1Curve) Params() *elliptic.CurveParams {
+	return c.params
+}
+
+func (c *secp256k1Curve) IsOnCurve(x, y *big.Int) bool {
+	p := c.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+
+	// y² = x³ + 7
+	lhs := new(big.Int).Mul(y, y)
+	lhs.Mod(lhs, p)
+
+	rhs := new(big.Int).Mul(x, x)
+	rhs.Mul(rhs, x)
+	rhs.Add(rhs, c.params.B)
+	rhs.Mod(rhs, p)
+
+	return lhs.Cmp(rhs) == 0
+}
+
+// jacobianPoint represents (X/Z², Y/Z³). Z = 0 is the point at infinity
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+func (c *secp256k1Curve) toJacobian(x, y *big.Int) *jacobianPoint {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return &jacobianPoint{x: new(big.Int), y: new(big.Int), z: new(big.Int)}
+	}
+	return &jacobianPoint{x: new(big.Int).Set(x), y: new(big.Int).Set(y), z: big.NewInt(1)}
+}
+
+func (c *secp256k1Curve) toAffine(pt *jacobianPoint) (*big.Int, *big.Int) {
+	if pt.z.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+
+	p := c.params.P
+	zinv := new(big.Int).ModInverse(pt.z, p)
+	zinv2 := new(big.Int).Mul(zinv, zinv)
+	zinv2.Mod(zinv2, p)
+	zinv3 := new(big.Int).Mul(zinv2, zinv)
+	zinv3.Mod(zinv3, p)
+
+	x := new(big.Int).Mul(pt.x, zinv2)
+	x.Mod(x, p)
+	y := new(big.Int).Mul(pt.y, zinv3)
+	y.Mod(y, p)
+	return x, y
+}
+
+// double computes 2 * pt, using the "dbl-2009-l" formulas for a = 0
+func (c *secp256k1Curve) double(pt *jacobianPoint) *jacobianPoint {
+	if pt.z.Sign() == 0 || pt.y.Sign() == 0 {
+		return &jacobianPoint{x: new(big.Int), y: new(big.Int), z: new(big.Int)}
+	}
+
+	p := c.params.P
+	a := new(big.Int).Mul(pt.x, pt.x)
+	a.Mod(a, p)
+	b := new(big.Int).Mul(pt.y, pt.y)
+	b.Mod(b, p)
+	cc := new(big.Int).Mul(b, b)
+	cc.Mod(cc, p)
+
+	// D = 2 * ((X1 + B)² - A - C)
+	d := new(big.Int).Add(pt.x, b)
+	d.Mul(d, d)
+	d.Sub(d, a)
+	d.Sub(d, cc)
+	d.Lsh(d, 1)
+	d.Mod(d, p)
+
+	// E = 3 * A, F = E²
+	e := new(big.Int).Lsh(a, 1)
+	e.Add(e, a)
+	f := new(big.Int).Mul(e, e)
+
+	// X3 = F - 2 * D
+	x3 := new(big.Int).Sub(f, new(big.Int).Lsh(d, 1))
+	x3.Mod(x3, p)
+
+	// Y3 = E * (D - X3) - 8 * C
+	y3 := new(big.Int).Sub(d, x3)
+	y3.Mul(y3, e)
+	y3.Sub(y3, new(big.Int).Lsh(cc, 3))
+	y3.Mod(y3, p)
+
+	// Z3 = 2 * Y1 * Z1
+	z3 := new(big.Int).Mul(pt.y, pt.z)
+	z3.Lsh(z3, 1)
+	z3.Mod(z3, p)
+
+	return &jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// add computes p1 + p2, using the "add-2007-bl" formulas
+func (c *secp256k1Curve) add(p1, p2 *jacobianPoint) *jacobianPoint {
+	if p1.z.Sign() == 0 {
+		return p2
+	}
+	if p2.z.Sign() == 0 {
+		return p1
+	}
+
+	p := c.params.P
+	z1z1 := new(big.Int).Mul(p1.z, p1.z)
+	z1z1.Mod(z1z1, p)
+	z2z2 := new(big.Int).Mul(p2.z, p2.z)
+	z2z2.Mod(z2z2, p)
+
+	u1 := new(big.Int).Mul(p1.x, z2z2)
+	u1.Mod(u1, p)
+	u2 := new(big.Int).Mul(p2.x, z1z1)
+	u2.Mod(u2, p)
+
+	s1 := new(big.Int).Mul(p1.y, p2.z)
+	s1.Mul(s1, z2z2)
+	s1.Mod(s1, p)
+	s2 := new(big.Int).Mul(p2.y, p1.z)
+	s2.Mul(s2, z1z1)
+	s2.Mod(s2, p)
+
+	h := new(big.Int).Sub(u2, u1)
+	h.Mod(h, p)
+	r := new(big.Int).Sub(s2, s1)
+	r.Mod(r, p)
+
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return c.double(p1)
+		}
+		// p1 = -p2
+		return &jacobianPoint{x: new(big.Int), y: new(big.Int), z: new(big.Int)}
+	}
+	r.Lsh(r, 1)
+
+	// I = (2 * H)², J = H * I, V = U1 * I
+	i := new(big.Int).Lsh(h, 1)
+	i.Mul(i, i)
+	i.Mod(i, p)
+	j := new(big.Int).Mul(h, i)
+	j.Mod(j, p)
+	v := new(big.Int).Mul(u1, i)
+	v.Mod(v, p)
+
+	// X3 = r² - J - 2 * V
+	x3 := new(big.Int).Mul(r, r)
+	x3.Sub(x3, j)
+	x3.Sub(x3, new(big.Int).Lsh(v, 1))
+	x3.Mod(x3, p)
+
+	// Y3 = r * (V - X3) - 2 * S1 * J
+	y3 := new(big.Int).Sub(v, x3)
+	y3.Mul(y3, r)
+	s1.Mul(s1, j)
+	s1.Lsh(s1, 1)
+	y3.Sub(y3, s1)
+	y3.Mod(y3, p)
+
+	// Z3 = ((Z1 + Z2)² - Z1Z1 - Z2Z2) * H
+	z3 := new(big.Int).Add(p1.z, p2.z)
+	z3.Mul(z3, z3)
+	z3.Sub(z3, z1z1)
+	z3.Sub(z3, z2z2)
+	z3.Mul(z3, h)
+	z3.Mod(z3, p)
+
+	return &jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+func (c *secp256k1Curve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	return c.toAffine(c.add(c.toJacobian(x1, y1), c.toJacobian(x2, y2)))
+}
+
+func (c *secp256k1Curve) Double(x1, y1 *big.Int) (*big.Int, *big.Int) {
+	return c.toAffine(c.double(c.toJacobian(x1, y1)))
+}
+
+func (c *secp256k1Curve) ScalarMult(x1, y1 *big.Int, k []byte) (*big.Int, *big.Int) {
+	base := c.toJacobian(x1, y1)
+	result := &jacobianPoint{x: new(big.Int), y: new(big.Int), z: new(big.Int)}
+	for _, b := range k {
+		for bit := 7; bit >= 0; bit-- {
+			result = c.double(result)
+			if (b>>uint(bit))&1 == 1 {
+				result = c.add(result, base)
+			}
+		}
+	}
+	return c.toAffine(result)
+}
+
+func (c *secp256k1Curve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	return c.ScalarMult(c.params.Gx, c.params.Gy, k)
+}
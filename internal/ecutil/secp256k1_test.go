@@ -0,0 +1,86 @@
+package ecutil_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/ecutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecp256k1(t *testing.T) {
+	crv := ecutil.Secp256k1()
+	params := crv.Params()
+
+	t.Run("Generator is on curve", func(t *testing.T) {
+		if !assert.True(t, crv.IsOnCurve(params.Gx, params.Gy), `G should be on the curve`) {
+			return
+		}
+		if !assert.False(t, crv.IsOnCurve(params.Gx, new(big.Int).Add(params.Gy, big.NewInt(1))), `G + (0, 1) should not be on the curve`) {
+			return
+		}
+	})
+	t.Run("Double", func(t *testing.T) {
+		expectedX, _ := new(big.Int).SetString("C6047F9441ED7D6D3045406E95C07CD85C778E4B8CEF3CA7ABAC09B95C709EE5", 16)
+		expectedY, _ := new(big.Int).SetString("1AE168FEA63DC339A3C58419466CEAEEF7F632653266D0E1236431A950CFE52A", 16)
+
+		x, y := crv.Double(params.Gx, params.Gy)
+		if !assert.Equal(t, expectedX, x, `x of 2G should match`) {
+			return
+		}
+		if !assert.Equal(t, expectedY, y, `y of 2G should match`) {
+			return
+		}
+
+		x, y = crv.Add(params.Gx, params.Gy, params.Gx, params.Gy)
+		if !assert.Equal(t, expectedX, x, `x of G + G should match`) {
+			return
+		}
+		if !assert.Equal(t, expectedY, y, `y of G + G should match`) {
+			return
+		}
+
+		x, y = crv.ScalarBaseMult([]byte{2})
+		if !assert.Equal(t, expectedX, x, `x of 2 * G should match`) {
+			return
+		}
+		if !assert.Equal(t, expectedY, y, `y of 2 * G should match`) {
+			return
+		}
+	})
+	t.Run("Order", func(t *testing.T) {
+		x, y := crv.ScalarBaseMult(params.N.Bytes())
+		if !assert.Equal(t, 0, x.Sign(), `N * G should be the point at infinity`) {
+			return
+		}
+		if !assert.Equal(t, 0, y.Sign(), `N * G should be the point at infinity`) {
+			return
+		}
+	})
+	t.Run("ECDSA", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(crv, rand.Reader)
+		if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+			return
+		}
+		if !assert.True(t, crv.IsOnCurve(key.X, key.Y), `public key should be on the curve`) {
+			return
+		}
+
+		digest := sha256.Sum256([]byte("Lorem ipsum"))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if !assert.NoError(t, err, `ecdsa.Sign should succeed`) {
+			return
+		}
+		if !assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s), `ecdsa.Verify should succeed`) {
+			return
+		}
+
+		digest[0] ^= 0xff
+		if !assert.False(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s), `ecdsa.Verify should fail`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package jwa
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupportedAlgorithm is matched by `errors.Is()` when an algorithm
+// is not supported for the requested operation. To find out which
+// algorithm was requested, use `errors.As()` with UnsupportedAlgorithmError.
+var ErrUnsupportedAlgorithm = errors.New(`unsupported algorithm`)
+
+// UnsupportedAlgorithmError is returned when an algorithm is not supported
+// for the requested operation, such as signing a payload or decrypting
+// a content encryption key.
+type UnsupportedAlgorithmError struct {
+	// Algorithm is the name of the algorithm that was requested
+	Algorithm string
+	// Usage describes what the algorithm was requested for,
+	// such as "signature" or "key encryption"
+	Usage string
+}
+
+func (e *UnsupportedAlgorithmError) Error() string {
+	return fmt.Sprintf(`unsupported %s algorithm %s`, e.Usage, e.Algorithm)
+}
+
+// Is returns true if target is ErrUnsupportedAlgorithm
+func (e *UnsupportedAlgorithmError) Is(target error) bool {
+	return target == ErrUnsupportedAlgorithm
+}
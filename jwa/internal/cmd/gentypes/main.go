@@ -131,6 +131,11 @@ func _main() error {
 					name:  `P521`,
 					value: `P-521`,
 				},
+				{
+					name:    `Secp256k1`,
+					value:   `secp256k1`,
+					comment: `SECG secp256k1 (RFC 8812)`,
+				},
 				{
 					name:  `Ed25519`,
 					value: `Ed25519`,
@@ -150,9 +155,10 @@ func _main() error {
 			},
 		},
 		{
-			name:     `SignatureAlgorithm`,
-			comment:  `SignatureAlgorithm represents the various signature algorithms as described in https://tools.ietf.org/html/rfc7518#section-3.1`,
-			filename: `signature_gen.go`,
+			name:         `SignatureAlgorithm`,
+			comment:      `SignatureAlgorithm represents the various signature algorithms as described in https://tools.ietf.org/html/rfc7518#section-3.1`,
+			filename:     `signature_gen.go`,
+			registerable: true,
 			elements: []element{
 				{
 					name:  `NoSignature`,
@@ -203,6 +209,11 @@ func _main() error {
 					value:   "ES512",
 					comment: `ECDSA using P-521 and SHA-512`,
 				},
+				{
+					name:    `ES256K`,
+					value:   "ES256K",
+					comment: `ECDSA using secp256k1 and SHA-256`,
+				},
 				{
 					name:    `EdDSA`,
 					value:   `EdDSA`,
@@ -343,6 +354,9 @@ type typ struct {
 	comment  string
 	filename string
 	elements []element
+	// registerable types allow users to register values other than
+	// the ones listed in `elements`
+	registerable bool
 }
 
 type element struct {
@@ -377,6 +391,9 @@ func (t typ) Generate() error {
 		"fmt",
 		"github.com/pkg/errors",
 	}
+	if t.registerable {
+		pkgs = append(pkgs, "sync")
+	}
 	for _, pkg := range pkgs {
 		fmt.Fprintf(&buf, "\n%s", strconv.Quote(pkg))
 	}
@@ -395,6 +412,26 @@ func (t typ) Generate() error {
 	}
 	fmt.Fprintf(&buf, "\n)") // end const
 
+	if t.registerable {
+		fmt.Fprintf(&buf, "\n\nvar mu%[1]ss sync.RWMutex", t.name)
+		fmt.Fprintf(&buf, "\nvar custom%[1]ss = map[%[1]s]struct{}{}", t.name)
+
+		fmt.Fprintf(&buf, "\n\n// Register%[1]s registers a new %[1]s, so that", t.name)
+		fmt.Fprintf(&buf, "\n// values other than the predefined constants are accepted")
+		fmt.Fprintf(&buf, "\nfunc Register%[1]s(v %[1]s) {", t.name)
+		fmt.Fprintf(&buf, "\nmu%ss.Lock()", t.name)
+		fmt.Fprintf(&buf, "\ncustom%ss[v] = struct{}{}", t.name)
+		fmt.Fprintf(&buf, "\nmu%ss.Unlock()", t.name)
+		fmt.Fprintf(&buf, "\n}")
+
+		fmt.Fprintf(&buf, "\n\nfunc isCustom%[1]s(v %[1]s) bool {", t.name)
+		fmt.Fprintf(&buf, "\nmu%ss.RLock()", t.name)
+		fmt.Fprintf(&buf, "\n_, ok := custom%ss[v]", t.name)
+		fmt.Fprintf(&buf, "\nmu%ss.RUnlock()", t.name)
+		fmt.Fprintf(&buf, "\nreturn ok")
+		fmt.Fprintf(&buf, "\n}")
+	}
+
 	fmt.Fprintf(&buf, "\n\n// Accept is used when conversion from values given by")
 	fmt.Fprintf(&buf, "\n// outside sources (such as JSON payloads) is required")
 	fmt.Fprintf(&buf, "\nfunc (v *%s) Accept(value interface{}) error {", t.name)
@@ -432,7 +469,13 @@ func (t typ) Generate() error {
 	}
 	fmt.Fprintf(&buf, ":")
 	fmt.Fprintf(&buf, "\ndefault:")
-	fmt.Fprintf(&buf, "\nreturn errors.Errorf(`invalid jwa.%s value`)", t.name)
+	if t.registerable {
+		fmt.Fprintf(&buf, "\nif !isCustom%s(tmp) {", t.name)
+		fmt.Fprintf(&buf, "\nreturn errors.Errorf(`invalid jwa.%s value`)", t.name)
+		fmt.Fprintf(&buf, "\n}")
+	} else {
+		fmt.Fprintf(&buf, "\nreturn errors.Errorf(`invalid jwa.%s value`)", t.name)
+	}
 	fmt.Fprintf(&buf, "\n}")
 
 	fmt.Fprintf(&buf, "\n\n*v = tmp")
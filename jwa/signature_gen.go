@@ -4,6 +4,7 @@ package jwa
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -13,13 +14,14 @@ type SignatureAlgorithm string
 
 // Supported values for SignatureAlgorithm
 const (
-	ES256       SignatureAlgorithm = "ES256" // ECDSA using P-256 and SHA-256
-	ES384       SignatureAlgorithm = "ES384" // ECDSA using P-384 and SHA-384
-	ES512       SignatureAlgorithm = "ES512" // ECDSA using P-521 and SHA-512
-	EdDSA       SignatureAlgorithm = "EdDSA" // EdDSA signature algorithms
-	HS256       SignatureAlgorithm = "HS256" // HMAC using SHA-256
-	HS384       SignatureAlgorithm = "HS384" // HMAC using SHA-384
-	HS512       SignatureAlgorithm = "HS512" // HMAC using SHA-512
+	ES256       SignatureAlgorithm = "ES256"  // ECDSA using P-256 and SHA-256
+	ES256K      SignatureAlgorithm = "ES256K" // ECDSA using secp256k1 and SHA-256
+	ES384       SignatureAlgorithm = "ES384"  // ECDSA using P-384 and SHA-384
+	ES512       SignatureAlgorithm = "ES512"  // ECDSA using P-521 and SHA-512
+	EdDSA       SignatureAlgorithm = "EdDSA"  // EdDSA signature algorithms
+	HS256       SignatureAlgorithm = "HS256"  // HMAC using SHA-256
+	HS384       SignatureAlgorithm = "HS384"  // HMAC using SHA-384
+	HS512       SignatureAlgorithm = "HS512"  // HMAC using SHA-512
 	NoSignature SignatureAlgorithm = "none"
 	PS256       SignatureAlgorithm = "PS256" // RSASSA-PSS using SHA256 and MGF1-SHA256
 	PS384       SignatureAlgorithm = "PS384" // RSASSA-PSS using SHA384 and MGF1-SHA384
@@ -29,6 +31,24 @@ const (
 	RS512       SignatureAlgorithm = "RS512" // RSASSA-PKCS-v1.5 using SHA-512
 )
 
+var muSignatureAlgorithms sync.RWMutex
+var customSignatureAlgorithms = map[SignatureAlgorithm]struct{}{}
+
+// RegisterSignatureAlgorithm registers a new SignatureAlgorithm, so that
+// values other than the predefined constants are accepted
+func RegisterSignatureAlgorithm(v SignatureAlgorithm) {
+	muSignatureAlgorithms.Lock()
+	customSignatureAlgorithms[v] = struct{}{}
+	muSignatureAlgorithms.Unlock()
+}
+
+func isCustomSignatureAlgorithm(v SignatureAlgorithm) bool {
+	muSignatureAlgorithms.RLock()
+	_, ok := customSignatureAlgorithms[v]
+	muSignatureAlgorithms.RUnlock()
+	return ok
+}
+
 // Accept is used when conversion from values given by
 // outside sources (such as JSON payloads) is required
 func (v *SignatureAlgorithm) Accept(value interface{}) error {
@@ -48,9 +68,11 @@ func (v *SignatureAlgorithm) Accept(value interface{}) error {
 		tmp = SignatureAlgorithm(s)
 	}
 	switch tmp {
-	case ES256, ES384, ES512, EdDSA, HS256, HS384, HS512, NoSignature, PS256, PS384, PS512, RS256, RS384, RS512:
+	case ES256, ES256K, ES384, ES512, EdDSA, HS256, HS384, HS512, NoSignature, PS256, PS384, PS512, RS256, RS384, RS512:
 	default:
-		return errors.Errorf(`invalid jwa.SignatureAlgorithm value`)
+		if !isCustomSignatureAlgorithm(tmp) {
+			return errors.Errorf(`invalid jwa.SignatureAlgorithm value`)
+		}
 	}
 
 	*v = tmp
@@ -1,15 +1,11 @@
 package jwe
 
 import (
-	"crypto/aes"
-	cryptocipher "crypto/cipher"
+	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/sha512"
-	"hash"
-
-	"golang.org/x/crypto/pbkdf2"
+	"io"
 
 	"github.com/lestrrat-go/jwx/internal/keyconv"
 	"github.com/lestrrat-go/jwx/jwa"
@@ -29,6 +25,7 @@ type Decrypter struct {
 	apv         []byte
 	cipher      content_crypt.Cipher
 	computedAad []byte
+	ctx         context.Context
 	ctalg       jwa.ContentEncryptionAlgorithm
 	iv          []byte
 	keyalg      jwa.KeyEncryptionAlgorithm
@@ -77,6 +74,13 @@ func (d *Decrypter) ComputedAuthenticatedData(aad []byte) *Decrypter {
 	return d
 }
 
+// Context sets the context.Context that is passed to private keys
+// that implement ContextDecrypter
+func (d *Decrypter) Context(ctx context.Context) *Decrypter {
+	d.ctx = ctx
+	return d
+}
+
 func (d *Decrypter) ContentEncryptionAlgorithm(ctalg jwa.ContentEncryptionAlgorithm) *Decrypter {
 	d.ctalg = ctalg
 	return d
@@ -129,7 +133,7 @@ func (d *Decrypter) ContentCipher() (content_crypt.Cipher, error) {
 			}
 			d.cipher = cipher
 		default:
-			return nil, errors.Errorf(`invalid content cipher algorithm (%s)`, d.ctalg)
+			return nil, &jwa.UnsupportedAlgorithmError{Algorithm: d.ctalg.String(), Usage: `content encryption`}
 		}
 	}
 
@@ -175,95 +179,22 @@ func (d *Decrypter) Decrypt(recipientKey, ciphertext []byte) (plaintext []byte,
 	return plaintext, nil
 }
 
-func (d *Decrypter) decryptSymmetricKey(recipientKey, cek []byte) ([]byte, error) {
-	if pdebug.Enabled {
-		pdebug.Printf("Detected symmetric key algorithm %s", d.keyalg)
-	}
-
-	switch d.keyalg {
-	case jwa.DIRECT:
-		if pdebug.Enabled {
-			pdebug.Printf("Successfully decrypted symmetric key (key len = %d)", len(cek))
-		}
-		return cek, nil
-	case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
-		var hashFunc func() hash.Hash
-		var keylen int
-		switch d.keyalg {
-		case jwa.PBES2_HS256_A128KW:
-			hashFunc = sha256.New
-			keylen = 16
-		case jwa.PBES2_HS384_A192KW:
-			hashFunc = sha512.New384
-			keylen = 24
-		case jwa.PBES2_HS512_A256KW:
-			hashFunc = sha512.New
-			keylen = 32
-		}
-		salt := []byte(d.keyalg)
-		salt = append(salt, byte(0))
-		salt = append(salt, d.keysalt...)
-		cek = pbkdf2.Key(cek, salt, d.keycount, keylen, hashFunc)
-		fallthrough
-	case jwa.A128KW, jwa.A192KW, jwa.A256KW:
-		block, err := aes.NewCipher(cek)
-		if err != nil {
-			return nil, errors.Wrap(err, `failed to create new AES cipher`)
-		}
-
-		jek, err := keyenc.Unwrap(block, recipientKey)
-		if err != nil {
-			return nil, errors.Wrap(err, `failed to unwrap key`)
-		}
-
-		if pdebug.Enabled {
-			pdebug.Printf("cek len = %d", len(cek))
-			pdebug.Printf("Wrapped key len = %d", len(jek))
-		}
-		return jek, nil
-	case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
-		if pdebug.Enabled {
-			pdebug.Printf("cek len = %d", len(cek))
-		}
-		if len(d.keyiv) != 12 {
-			return nil, errors.Errorf("GCM requires 96-bit iv, got %d", len(d.keyiv)*8)
-		}
-		if len(d.keytag) != 16 {
-			return nil, errors.Errorf("GCM requires 128-bit tag, got %d", len(d.keytag)*8)
-		}
-		block, err := aes.NewCipher(cek)
-		if err != nil {
-			return nil, errors.Wrap(err, `failed to create new AES cipher`)
-		}
-		aesgcm, err := cryptocipher.NewGCM(block)
-		if err != nil {
-			return nil, errors.Wrap(err, `failed to create new GCM wrap`)
-		}
-		ciphertext := recipientKey[:]
-		ciphertext = append(ciphertext, d.keytag...)
-		jek, err := aesgcm.Open(nil, d.keyiv, ciphertext, nil)
-		if err != nil {
-			return nil, errors.Wrap(err, `failed to decode key`)
-		}
-		return jek, nil
-	default:
-		return nil, errors.Errorf("decrypt key: unsupported algorithm %s", d.keyalg)
-	}
-}
-
 func (d *Decrypter) DecryptKey(recipientKey []byte) (cek []byte, err error) {
 	if pdebug.Enabled {
 		g := pdebug.FuncMarker().BindError(&err)
 		defer g.End()
 	}
-	if d.keyalg.IsSymmetric() {
+	if d.keyalg == jwa.DIRECT {
 		var ok bool
 		cek, ok = d.privkey.([]byte)
 		if !ok {
-			return nil, errors.Errorf("decrypt key: []byte is required as the key to build %s key decrypter (got %T)", d.keyalg, d.privkey)
+			return nil, errors.Errorf("decrypt key: []byte is required as the key for %s (got %T)", d.keyalg, d.privkey)
 		}
 
-		return d.decryptSymmetricKey(recipientKey, cek)
+		if pdebug.Enabled {
+			pdebug.Printf("Successfully decrypted symmetric key (key len = %d)", len(cek))
+		}
+		return cek, nil
 	}
 
 	k, err := d.BuildKeyDecrypter()
@@ -283,6 +214,49 @@ func (d *Decrypter) DecryptKey(recipientKey []byte) (cek []byte, err error) {
 	return cek, nil
 }
 
+// contextBoundDecrypter binds a context to a ContextDecrypter, so that
+// it can be used where a crypto.Decrypter is expected
+type contextBoundDecrypter struct {
+	ctx       context.Context
+	decrypter ContextDecrypter
+}
+
+func (d contextBoundDecrypter) Public() crypto.PublicKey {
+	return d.decrypter.Public()
+}
+
+func (d contextBoundDecrypter) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return d.decrypter.DecryptContext(d.ctx, rand, msg, opts)
+}
+
+// rsaDecrypter converts the key to a crypto.Decrypter. The key may be
+// either a rsa.PrivateKey/*rsa.PrivateKey, or a crypto.Decrypter whose
+// public key is a *rsa.PublicKey, such as keys stored in an HSM or a KMS.
+// If the key is a ContextDecrypter, the context is bound to it
+func rsaDecrypter(ctx context.Context, key interface{}) (crypto.Decrypter, error) {
+	var privkey rsa.PrivateKey
+	if err := keyconv.RSAPrivateKey(&privkey, key); err == nil {
+		return &privkey, nil
+	}
+
+	decrypter, ok := key.(crypto.Decrypter)
+	if !ok {
+		return nil, errors.Errorf(`invalid key type %T`, key)
+	}
+
+	if _, ok := decrypter.Public().(*rsa.PublicKey); !ok {
+		return nil, errors.Errorf(`invalid crypto.Decrypter: public key must be *rsa.PublicKey, got %T`, decrypter.Public())
+	}
+
+	if v, ok := decrypter.(ContextDecrypter); ok {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return contextBoundDecrypter{ctx: ctx, decrypter: v}, nil
+	}
+	return decrypter, nil
+}
+
 func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 	cipher, err := d.ContentCipher()
 	if err != nil {
@@ -291,19 +265,19 @@ func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 
 	switch alg := d.keyalg; alg {
 	case jwa.RSA1_5:
-		var privkey rsa.PrivateKey
-		if err := keyconv.RSAPrivateKey(&privkey, d.privkey); err != nil {
-			return nil, errors.Wrapf(err, "*rsa.PrivateKey is required as the key to build %s key decrypter", alg)
+		privkey, err := rsaDecrypter(d.ctx, d.privkey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "*rsa.PrivateKey or crypto.Decrypter is required as the key to build %s key decrypter", alg)
 		}
 
-		return keyenc.NewRSAPKCS15Decrypt(alg, &privkey, cipher.KeySize()/2), nil
+		return keyenc.NewRSAPKCS15Decrypt(alg, privkey, cipher.KeySize()/2)
 	case jwa.RSA_OAEP, jwa.RSA_OAEP_256:
-		var privkey rsa.PrivateKey
-		if err := keyconv.RSAPrivateKey(&privkey, d.privkey); err != nil {
-			return nil, errors.Wrapf(err, "*rsa.PrivateKey is required as the key to build %s key decrypter", alg)
+		privkey, err := rsaDecrypter(d.ctx, d.privkey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "*rsa.PrivateKey or crypto.Decrypter is required as the key to build %s key decrypter", alg)
 		}
 
-		return keyenc.NewRSAOAEPDecrypt(alg, &privkey)
+		return keyenc.NewRSAOAEPDecrypt(alg, privkey)
 	case jwa.A128KW, jwa.A192KW, jwa.A256KW:
 		sharedkey, ok := d.privkey.([]byte)
 		if !ok {
@@ -311,6 +285,20 @@ func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 		}
 
 		return keyenc.NewAES(alg, sharedkey)
+	case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
+		sharedkey, ok := d.privkey.([]byte)
+		if !ok {
+			return nil, errors.Errorf("[]byte is required as the key to build %s key decrypter", alg)
+		}
+
+		return keyenc.NewAESGCMDecrypt(alg, sharedkey, d.keyiv, d.keytag)
+	case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
+		password, ok := d.privkey.([]byte)
+		if !ok {
+			return nil, errors.Errorf("[]byte is required as the key to build %s key decrypter", alg)
+		}
+
+		return keyenc.NewPBES2Decrypt(alg, password, d.keysalt, d.keycount)
 	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
 		switch d.pubkey.(type) {
 		case x25519.PublicKey:
@@ -329,6 +317,6 @@ func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 			return keyenc.NewECDHESDecrypt(alg, d.ctalg, &pubkey, d.apu, d.apv, &privkey), nil
 		}
 	default:
-		return nil, errors.Errorf(`unsupported algorithm for key decryption (%s)`, alg)
+		return nil, &jwa.UnsupportedAlgorithmError{Algorithm: alg.String(), Usage: `key decryption`}
 	}
 }
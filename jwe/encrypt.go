@@ -4,7 +4,6 @@ import (
 	"context"
 	"sync"
 
-	"github.com/lestrrat-go/jwx/buffer"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/pdebug/v3"
 	"github.com/pkg/errors"
@@ -24,7 +23,10 @@ func releaseEncryptCtx(ctx *encryptCtx) {
 	ctx.contentEncrypter = nil
 	ctx.generator = nil
 	ctx.keyEncrypters = nil
+	ctx.protected = nil
+	ctx.recipientHeaders = nil
 	ctx.compress = jwa.NoCompress
+	ctx.compact = false
 	encryptCtxPool.Put(ctx)
 }
 
@@ -49,6 +51,19 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 	}
 
 	protected := NewHeaders()
+	if e.protected != nil {
+		h, err := e.protected.Clone(context.TODO())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to copy protected header")
+		}
+		// These are always computed by us
+		for _, key := range []string{AlgorithmKey, ContentEncryptionKey, CompressionKey} {
+			if err := h.Remove(key); err != nil {
+				return nil, errors.Wrapf(err, "failed to remove %#v from protected header", key)
+			}
+		}
+		protected = h
+	}
 	if err := protected.Set(ContentEncryptionKey, e.contentEncrypter.Algorithm()); err != nil {
 		return nil, errors.Wrap(err, `failed to set "enc" in protected header`)
 	}
@@ -66,6 +81,15 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 	recipients := make([]Recipient, len(e.keyEncrypters))
 	for i, enc := range e.keyEncrypters {
 		r := NewRecipient()
+		if i < len(e.recipientHeaders) && e.recipientHeaders[i] != nil {
+			h, err := e.recipientHeaders[i].Clone(context.TODO())
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to copy recipient header")
+			}
+			if err := r.SetHeaders(h); err != nil {
+				return nil, errors.Wrap(err, "failed to set recipient header")
+			}
+		}
 		if err := r.Headers().Set(AlgorithmKey, enc.Algorithm()); err != nil {
 			return nil, errors.Wrap(err, "failed to set header")
 		}
@@ -103,14 +127,43 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 		recipients[i] = r
 	}
 
-	// If there's only one recipient, you want to include that in the
-	// protected header
+	// If there's only one recipient, you want to include the header
+	// parameters computed by us (e.g. "alg" and "epk") in the protected
+	// header. The header parameters are moved, as the protected and
+	// per-recipient headers must be disjoint. The per-recipient headers
+	// given by the user are left as is, unless we are creating a message
+	// in the compact serialization format, which has no unprotected headers
 	if len(recipients) == 1 {
-		h, err := protected.Merge(context.TODO(), recipients[0].Headers())
+		ctx := context.TODO()
+		var unprotected Headers
+		if !e.compact && len(e.recipientHeaders) > 0 {
+			unprotected = e.recipientHeaders[0]
+		}
+
+		moved := NewHeaders()
+		kept := NewHeaders()
+		for iter := recipients[0].Headers().Iterate(ctx); iter.Next(ctx); {
+			pair := iter.Pair()
+			name := pair.Key.(string)
+			dst := moved
+			if unprotected != nil {
+				if _, ok := unprotected.Get(name); ok {
+					dst = kept
+				}
+			}
+			if err := dst.Set(name, pair.Value); err != nil {
+				return nil, errors.Wrapf(err, "failed to set %s", name)
+			}
+		}
+
+		h, err := protected.Merge(ctx, moved)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to merge protected headers")
 		}
 		protected = h
+		if err := recipients[0].SetHeaders(kept); err != nil {
+			return nil, errors.Wrap(err, "failed to reset recipient headers")
+		}
 	}
 
 	aad, err := protected.Encode()
@@ -142,13 +195,9 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 
 	msg := NewMessage()
 
-	decodedAad, err := buffer.FromBase64(aad)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode base64")
-	}
-	if err := msg.Set(AuthenticatedDataKey, decodedAad.Bytes()); err != nil {
-		return nil, errors.Wrapf(err, `failed to set %s`, AuthenticatedDataKey)
-	}
+	// NOTE: "aad" is reserved for additional authenticated data provided
+	// by the user. The protected header is already part of the AAD that
+	// was computed above, so it must not be stored in the message again.
 	if err := msg.Set(CipherTextKey, ciphertext); err != nil {
 		return nil, errors.Wrapf(err, `failed to set %s`, CipherTextKey)
 	}
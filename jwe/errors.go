@@ -0,0 +1,12 @@
+package jwe
+
+import "github.com/pkg/errors"
+
+// ErrDecryptionFailed is matched by `errors.Is()` when a JWE message
+// could not be decrypted for any of its recipients using the given key.
+//
+// Errors caused by malformed messages or by the cancellation of the
+// context do not match ErrDecryptionFailed. If no key matching the
+// message could be found in a key set, the error matches
+// `jwk.ErrKeyNotFound` instead.
+var ErrDecryptionFailed = errors.New(`decryption failed`)
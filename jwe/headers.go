@@ -2,6 +2,7 @@ package jwe
 
 import (
 	"context"
+	"sync"
 
 	"github.com/lestrrat-go/jwx/internal/json"
 
@@ -113,3 +114,86 @@ func (h *stdHeaders) Decode(buf []byte) error {
 
 	return nil
 }
+
+var muCriticalExtensions sync.RWMutex
+var criticalExtensions = map[string]struct{}{}
+
+// RegisterCriticalExtension registers header parameter names that the
+// application understands and processes. By default, messages that list
+// any header parameter in the "crit" header are rejected during
+// decryption, as this library does not implement any JWE extensions.
+//
+// If you only need to accept extensions for a particular call,
+// use the WithCriticalExtensions option instead.
+func RegisterCriticalExtension(names ...string) {
+	muCriticalExtensions.Lock()
+	for _, name := range names {
+		criticalExtensions[name] = struct{}{}
+	}
+	muCriticalExtensions.Unlock()
+}
+
+func isStandardHeader(name string) bool {
+	switch name {
+	case AgreementPartyUInfoKey, AgreementPartyVInfoKey, AlgorithmKey, CompressionKey,
+		ContentEncryptionKey, ContentTypeKey, CriticalKey, EphemeralPublicKeyKey,
+		JWKKey, JWKSetURLKey, KeyIDKey, TypeKey, X509CertChainKey, X509CertThumbprintKey,
+		X509CertThumbprintS256Key, X509URLKey, "iv", "tag", "p2s", "p2c":
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnderstoodExtension(name string, extra []string) bool {
+	for _, v := range extra {
+		if v == name {
+			return true
+		}
+	}
+
+	muCriticalExtensions.RLock()
+	_, ok := criticalExtensions[name]
+	muCriticalExtensions.RUnlock()
+	return ok
+}
+
+// checkCritical validates the "crit" header of the protected headers,
+// as described in RFC 7516 Section 4.1.13 (which in turn refers to
+// RFC 7515 Section 4.1.11). Any of the unprotected headers given in
+// `unprotected` must not contain "crit" at all.
+func checkCritical(protected Headers, extra []string, unprotected ...Headers) error {
+	for _, h := range unprotected {
+		if h != nil && h.Critical() != nil {
+			return errors.Errorf(`%q header must be in the protected headers`, CriticalKey)
+		}
+	}
+
+	if protected == nil {
+		return nil
+	}
+
+	crit := protected.Critical()
+	if crit == nil {
+		return nil
+	}
+
+	if len(crit) == 0 {
+		return errors.Errorf(`%q header must not be empty`, CriticalKey)
+	}
+
+	for _, name := range crit {
+		if isStandardHeader(name) {
+			return errors.Errorf(`%q header must not contain standard header %q`, CriticalKey, name)
+		}
+
+		if _, ok := protected.Get(name); !ok {
+			return errors.Errorf(`critical header %q is not present in the protected headers`, name)
+		}
+
+		if !isUnderstoodExtension(name, extra) {
+			return errors.Errorf(`critical header %q is not supported`, name)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package jwe
 
 import (
+	"context"
+	"crypto"
+	"io"
+
 	"github.com/lestrrat-go/iter/mapiter"
 	"github.com/lestrrat-go/jwx/buffer"
 	"github.com/lestrrat-go/jwx/internal/iter"
@@ -9,6 +13,15 @@ import (
 	"github.com/lestrrat-go/jwx/jwe/internal/keygen"
 )
 
+// ContextDecrypter is a crypto.Decrypter that can take a context.Context,
+// such as keys stored in a remote KMS. When such a key is used to decrypt
+// a message, the context specified via `jwe.WithDecryptContext` is passed to
+// DecryptContext.
+type ContextDecrypter interface {
+	crypto.Decrypter
+	DecryptContext(ctx context.Context, rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error)
+}
+
 // Recipient holds the encrypted key and hints to decrypt the key
 type Recipient interface {
 	Headers() Headers
@@ -38,13 +51,17 @@ type Message struct {
 type contentEncrypter interface {
 	Algorithm() jwa.ContentEncryptionAlgorithm
 	Encrypt([]byte, []byte, []byte) ([]byte, []byte, []byte, error)
+	KeySize() int
 }
 
 type encryptCtx struct {
 	contentEncrypter contentEncrypter
 	generator        keygen.Generator
 	keyEncrypters    []keyenc.Encrypter
+	protected        Headers
+	recipientHeaders []Headers
 	compress         jwa.CompressionAlgorithm
+	compact          bool
 }
 
 // populater is an interface for things that may modify the
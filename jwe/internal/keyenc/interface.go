@@ -1,6 +1,7 @@
 package keyenc
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"hash"
 
@@ -45,6 +46,14 @@ type AESGCMEncrypt struct {
 	keyID     string
 }
 
+// AESGCMDecrypt decrypts content encryption keys using AES-GCM key wrap.
+type AESGCMDecrypt struct {
+	algorithm jwa.KeyEncryptionAlgorithm
+	sharedkey []byte
+	iv        []byte
+	tag       []byte
+}
+
 // ECDHESEncrypt encrypts content encryption keys using ECDH-ES.
 type ECDHESEncrypt struct {
 	algorithm jwa.KeyEncryptionAlgorithm
@@ -72,13 +81,14 @@ type RSAOAEPEncrypt struct {
 // RSAOAEPDecrypt decrypts keys using RSA OAEP algorithm
 type RSAOAEPDecrypt struct {
 	alg     jwa.KeyEncryptionAlgorithm
-	privkey *rsa.PrivateKey
+	privkey crypto.Decrypter
 }
 
 // RSAPKCS15Decrypt decrypts keys using RSA PKCS1v15 algorithm
 type RSAPKCS15Decrypt struct {
 	alg       jwa.KeyEncryptionAlgorithm
-	privkey   *rsa.PrivateKey
+	privkey   crypto.Decrypter
+	pubkey    *rsa.PublicKey
 	generator keygen.Generator
 }
 
@@ -102,3 +112,13 @@ type PBES2Encrypt struct {
 	keylen    int
 	keyID     string
 }
+
+// PBES2Decrypt decrypts keys with PBES2 / PBKDF2 password
+type PBES2Decrypt struct {
+	algorithm jwa.KeyEncryptionAlgorithm
+	password  []byte
+	salt      []byte
+	count     int
+	hashFunc  func() hash.Hash
+	keylen    int
+}
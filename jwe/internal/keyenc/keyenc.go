@@ -135,21 +135,80 @@ func (kw AESGCMEncrypt) Encrypt(cek []byte) (keygen.ByteSource, error) {
 	}, nil
 }
 
-func NewPBES2Encrypt(alg jwa.KeyEncryptionAlgorithm, password []byte) (*PBES2Encrypt, error) {
-	var hashFunc func() hash.Hash
-	var keylen int
+// NewAESGCMDecrypt creates a key decrypter using AES-GCM key wrap.
+// The iv and tag values are those found in the "iv" and "tag" header fields
+func NewAESGCMDecrypt(alg jwa.KeyEncryptionAlgorithm, sharedkey, iv, tag []byte) (*AESGCMDecrypt, error) {
+	switch alg {
+	case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
+	default:
+		return nil, errors.Errorf("invalid AES-GCM key wrap algorithm (%s)", alg)
+	}
+
+	return &AESGCMDecrypt{
+		algorithm: alg,
+		sharedkey: sharedkey,
+		iv:        iv,
+		tag:       tag,
+	}, nil
+}
+
+// Algorithm returns the key encryption algorithm being used
+func (kw AESGCMDecrypt) Algorithm() jwa.KeyEncryptionAlgorithm {
+	return kw.algorithm
+}
+
+// Decrypt decrypts the encrypted key using AES-GCM
+func (kw AESGCMDecrypt) Decrypt(enckey []byte) ([]byte, error) {
+	if len(kw.iv) != 12 {
+		return nil, errors.Errorf("GCM requires 96-bit iv, got %d", len(kw.iv)*8)
+	}
+	if len(kw.tag) != 16 {
+		return nil, errors.Errorf("GCM requires 128-bit tag, got %d", len(kw.tag)*8)
+	}
+
+	block, err := aes.NewCipher(kw.sharedkey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher from shared key")
+	}
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gcm from cipher")
+	}
+
+	ciphertext := make([]byte, 0, len(enckey)+len(kw.tag))
+	ciphertext = append(ciphertext, enckey...)
+	ciphertext = append(ciphertext, kw.tag...)
+	cek, err := aesgcm.Open(nil, kw.iv, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt key")
+	}
+	return cek, nil
+}
+
+func pbes2Params(alg jwa.KeyEncryptionAlgorithm) (func() hash.Hash, int, error) {
 	switch alg {
 	case jwa.PBES2_HS256_A128KW:
-		hashFunc = sha256.New
-		keylen = 16
+		return sha256.New, 16, nil
 	case jwa.PBES2_HS384_A192KW:
-		hashFunc = sha512.New384
-		keylen = 24
+		return sha512.New384, 24, nil
 	case jwa.PBES2_HS512_A256KW:
-		hashFunc = sha512.New
-		keylen = 32
+		return sha512.New, 32, nil
 	default:
-		return nil, errors.Errorf("unexpected key encryption algorithm %s", alg)
+		return nil, 0, errors.Errorf("unexpected key encryption algorithm %s", alg)
+	}
+}
+
+func pbes2SharedKey(alg jwa.KeyEncryptionAlgorithm, password, salt []byte, count, keylen int, hashFunc func() hash.Hash) []byte {
+	fullsalt := []byte(alg)
+	fullsalt = append(fullsalt, byte(0))
+	fullsalt = append(fullsalt, salt...)
+	return pbkdf2.Key(password, fullsalt, count, keylen, hashFunc)
+}
+
+func NewPBES2Encrypt(alg jwa.KeyEncryptionAlgorithm, password []byte) (*PBES2Encrypt, error) {
+	hashFunc, keylen, err := pbes2Params(alg)
+	if err != nil {
+		return nil, err
 	}
 	return &PBES2Encrypt{
 		algorithm: alg,
@@ -175,10 +234,7 @@ func (kw PBES2Encrypt) Encrypt(cek []byte) (keygen.ByteSource, error) {
 		return nil, errors.Wrap(err, "failed to get random salt")
 	}
 
-	fullsalt := []byte(kw.algorithm)
-	fullsalt = append(fullsalt, byte(0))
-	fullsalt = append(fullsalt, salt...)
-	sharedkey := pbkdf2.Key(kw.password, fullsalt, count, kw.keylen, kw.hashFunc)
+	sharedkey := pbes2SharedKey(kw.algorithm, kw.password, salt, count, kw.keylen, kw.hashFunc)
 
 	block, err := aes.NewCipher(sharedkey)
 	if err != nil {
@@ -195,15 +251,60 @@ func (kw PBES2Encrypt) Encrypt(cek []byte) (keygen.ByteSource, error) {
 	}, nil
 }
 
+// NewPBES2Decrypt creates a key decrypter using PBES2. The salt and
+// count values are those found in the "p2s" and "p2c" header fields
+// (the salt must NOT contain the algorithm name prefix)
+func NewPBES2Decrypt(alg jwa.KeyEncryptionAlgorithm, password, salt []byte, count int) (*PBES2Decrypt, error) {
+	hashFunc, keylen, err := pbes2Params(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if count < 1 {
+		return nil, errors.Errorf("invalid PBES2 count %d", count)
+	}
+
+	return &PBES2Decrypt{
+		algorithm: alg,
+		password:  password,
+		salt:      salt,
+		count:     count,
+		hashFunc:  hashFunc,
+		keylen:    keylen,
+	}, nil
+}
+
+// Algorithm returns the key encryption algorithm being used
+func (kw PBES2Decrypt) Algorithm() jwa.KeyEncryptionAlgorithm {
+	return kw.algorithm
+}
+
+// Decrypt derives the key encryption key from the password, and
+// uses it to unwrap the encrypted key
+func (kw PBES2Decrypt) Decrypt(enckey []byte) ([]byte, error) {
+	sharedkey := pbes2SharedKey(kw.algorithm, kw.password, kw.salt, kw.count, kw.keylen, kw.hashFunc)
+
+	block, err := aes.NewCipher(sharedkey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher from shared key")
+	}
+
+	cek, err := Unwrap(block, enckey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data")
+	}
+	return cek, nil
+}
+
 // NewECDHESEncrypt creates a new key encrypter based on ECDH-ES
-func NewECDHESEncrypt(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, keysize int, keyif interface{}) (*ECDHESEncrypt, error) {
+func NewECDHESEncrypt(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, keysize int, keyif interface{}, apu, apv []byte) (*ECDHESEncrypt, error) {
 	var generator keygen.Generator
 	var err error
 	switch key := keyif.(type) {
 	case *ecdsa.PublicKey:
-		generator, err = keygen.NewEcdhes(alg, enc, keysize, key)
+		generator, err = keygen.NewEcdhes(alg, enc, keysize, key, apu, apv)
 	case x25519.PublicKey:
-		generator, err = keygen.NewX25519(alg, enc, keysize, key)
+		generator, err = keygen.NewX25519(alg, enc, keysize, key, apu, apv)
 	default:
 		return nil, errors.Errorf("unexpected key type %T", keyif)
 	}
@@ -295,6 +396,9 @@ func DeriveZ(privkeyif interface{}, pubkeyif interface{}) ([]byte, error) {
 		if !ok {
 			return nil, errors.Errorf(`public key must be *ecdsa.PublicKey, was: %T`, pubkeyif)
 		}
+		if ecutil.IsSecp256k1(privkey.Curve) {
+			return nil, errors.New(`key agreement using secp256k1 keys is not supported`)
+		}
 		if !privkey.PublicKey.Curve.IsOnCurve(pubkey.X, pubkey.Y) {
 			return nil, errors.New(`public key must be on the same curve as private key`)
 		}
@@ -463,14 +567,22 @@ func (e RSAOAEPEncrypt) Encrypt(cek []byte) (keygen.ByteSource, error) {
 	return keygen.ByteKey(encrypted), nil
 }
 
-// NewRSAPKCS15Decrypt creates a new decrypter using RSA PKCS1v15
-func NewRSAPKCS15Decrypt(alg jwa.KeyEncryptionAlgorithm, privkey *rsa.PrivateKey, keysize int) *RSAPKCS15Decrypt {
+// NewRSAPKCS15Decrypt creates a new decrypter using RSA PKCS1v15.
+// The private key may be either a *rsa.PrivateKey or a crypto.Decrypter
+// backed by an RSA key (e.g. a key stored in an HSM or a KMS)
+func NewRSAPKCS15Decrypt(alg jwa.KeyEncryptionAlgorithm, privkey crypto.Decrypter, keysize int) (*RSAPKCS15Decrypt, error) {
+	pubkey, ok := privkey.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("invalid private key for RSA PKCS1v15 decrypt: public key must be *rsa.PublicKey, got %T", privkey.Public())
+	}
+
 	generator := keygen.NewRandom(keysize * 2)
 	return &RSAPKCS15Decrypt{
 		alg:       alg,
 		privkey:   privkey,
+		pubkey:    pubkey,
 		generator: generator,
-	}
+	}, nil
 }
 
 // Algorithm returns the key encryption algorithm being used
@@ -495,7 +607,7 @@ func (d RSAPKCS15Decrypt) Decrypt(enckey []byte) ([]byte, error) {
 	}()
 
 	// Perform some input validation.
-	expectedlen := d.privkey.PublicKey.N.BitLen() / 8
+	expectedlen := d.pubkey.N.BitLen() / 8
 	if expectedlen != len(enckey) {
 		// Input size is incorrect, the encrypted payload should always match
 		// the size of the public modulus (e.g. using a 2048 bit key will
@@ -519,22 +631,42 @@ func (d RSAPKCS15Decrypt) Decrypt(enckey []byte) ([]byte, error) {
 	// prevent chosen-ciphertext attacks as described in RFC 3218, "Preventing
 	// the Million Message Attack on Cryptographic Message Syntax". We are
 	// therefore deliberately ignoring errors here.
-	err = rsa.DecryptPKCS1v15SessionKey(rand.Reader, d.privkey, enckey, cek)
+	if privkey, ok := d.privkey.(*rsa.PrivateKey); ok {
+		err = rsa.DecryptPKCS1v15SessionKey(rand.Reader, privkey, enckey, cek)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt via PKCS1v15")
+		}
+		return cek, nil
+	}
+
+	// For other crypto.Decrypter implementations, the same precaution is
+	// requested through SessionKeyLen. Implementations that do not honor
+	// it must at least return a key of the expected length
+	decrypted, err := d.privkey.Decrypt(rand.Reader, enckey, &rsa.PKCS1v15DecryptOptions{SessionKeyLen: len(cek)})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to decrypt via PKCS1v15")
 	}
+	if len(decrypted) != len(cek) {
+		return nil, errors.New("failed to decrypt via PKCS1v15: invalid key length")
+	}
 
-	return cek, nil
+	return decrypted, nil
 }
 
-// NewRSAOAEPDecrypt creates a new key decrypter using RSA OAEP
-func NewRSAOAEPDecrypt(alg jwa.KeyEncryptionAlgorithm, privkey *rsa.PrivateKey) (*RSAOAEPDecrypt, error) {
+// NewRSAOAEPDecrypt creates a new key decrypter using RSA OAEP.
+// The private key may be either a *rsa.PrivateKey or a crypto.Decrypter
+// backed by an RSA key (e.g. a key stored in an HSM or a KMS)
+func NewRSAOAEPDecrypt(alg jwa.KeyEncryptionAlgorithm, privkey crypto.Decrypter) (*RSAOAEPDecrypt, error) {
 	switch alg {
 	case jwa.RSA_OAEP, jwa.RSA_OAEP_256:
 	default:
 		return nil, errors.Errorf("invalid RSA OAEP decrypt algorithm (%s)", alg)
 	}
 
+	if _, ok := privkey.Public().(*rsa.PublicKey); !ok {
+		return nil, errors.Errorf("invalid private key for RSA OAEP decrypt: public key must be *rsa.PublicKey, got %T", privkey.Public())
+	}
+
 	return &RSAOAEPDecrypt{
 		alg:     alg,
 		privkey: privkey,
@@ -551,16 +683,16 @@ func (d RSAOAEPDecrypt) Decrypt(enckey []byte) ([]byte, error) {
 	if pdebug.Enabled {
 		pdebug.Printf("START OAEP.Decrypt")
 	}
-	var hash hash.Hash
+	var hash crypto.Hash
 	switch d.alg {
 	case jwa.RSA_OAEP:
-		hash = sha1.New()
+		hash = crypto.SHA1
 	case jwa.RSA_OAEP_256:
-		hash = sha256.New()
+		hash = crypto.SHA256
 	default:
 		return nil, errors.New("failed to generate key encrypter for RSA-OAEP: RSA_OAEP/RSA_OAEP_256 required")
 	}
-	return rsa.DecryptOAEP(hash, rand.Reader, d.privkey, enckey, []byte{})
+	return d.privkey.Decrypt(rand.Reader, enckey, &rsa.OAEPOptions{Hash: hash})
 }
 
 // Decrypt for DirectDecrypt does not do anything other than
@@ -26,6 +26,8 @@ type Ecdhes struct {
 	enc       jwa.ContentEncryptionAlgorithm
 	keysize   int
 	pubkey    *ecdsa.PublicKey
+	apu       []byte
+	apv       []byte
 }
 
 // X25519KeyGenerate generates keys using ECDH-ES algorithm / X25519 curve
@@ -34,6 +36,8 @@ type X25519 struct {
 	enc       jwa.ContentEncryptionAlgorithm
 	keysize   int
 	pubkey    x25519.PublicKey
+	apu       []byte
+	apv       []byte
 }
 
 // ByteKey is a generated key that only has the key's byte buffer
@@ -56,12 +56,14 @@ func (g Random) Generate() (ByteSource, error) {
 }
 
 // NewEcdhes creates a new key generator using ECDH-ES
-func NewEcdhes(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, keysize int, pubkey *ecdsa.PublicKey) (*Ecdhes, error) {
+func NewEcdhes(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, keysize int, pubkey *ecdsa.PublicKey, apu, apv []byte) (*Ecdhes, error) {
 	return &Ecdhes{
 		algorithm: alg,
 		enc:       enc,
 		keysize:   keysize,
 		pubkey:    pubkey,
+		apu:       apu,
+		apv:       apv,
 	}, nil
 }
 
@@ -72,6 +74,10 @@ func (g Ecdhes) Size() int {
 
 // Generate generates new keys using ECDH-ES
 func (g Ecdhes) Generate() (ByteSource, error) {
+	if ecutil.IsSecp256k1(g.pubkey.Curve) {
+		return nil, errors.New("key agreement using secp256k1 keys is not supported")
+	}
+
 	priv, err := ecdsa.GenerateKey(g.pubkey.Curve, rand.Reader)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate key for ECDH-ES")
@@ -90,7 +96,7 @@ func (g Ecdhes) Generate() (ByteSource, error) {
 	z, _ := priv.PublicKey.Curve.ScalarMult(g.pubkey.X, g.pubkey.Y, priv.D.Bytes())
 	zBytes := ecutil.AllocECPointBuffer(z, priv.PublicKey.Curve)
 	defer ecutil.ReleaseECPointBuffer(zBytes)
-	kdf := concatkdf.New(crypto.SHA256, []byte(algorithm), zBytes, []byte{}, []byte{}, pubinfo, []byte{})
+	kdf := concatkdf.New(crypto.SHA256, []byte(algorithm), zBytes, g.apu, g.apv, pubinfo, []byte{})
 	kek := make([]byte, g.keysize)
 	if _, err := kdf.Read(kek); err != nil {
 		return nil, errors.Wrap(err, "failed to read kdf")
@@ -103,12 +109,14 @@ func (g Ecdhes) Generate() (ByteSource, error) {
 }
 
 // NewX25519 creates a new key generator using ECDH-ES
-func NewX25519(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, keysize int, pubkey x25519.PublicKey) (*X25519, error) {
+func NewX25519(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, keysize int, pubkey x25519.PublicKey, apu, apv []byte) (*X25519, error) {
 	return &X25519{
 		algorithm: alg,
 		enc:       enc,
 		keysize:   keysize,
 		pubkey:    pubkey,
+		apu:       apu,
+		apv:       apv,
 	}, nil
 }
 
@@ -138,7 +146,7 @@ func (g X25519) Generate() (ByteSource, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to compute Z")
 	}
-	kdf := concatkdf.New(crypto.SHA256, []byte(algorithm), zBytes, []byte{}, []byte{}, pubinfo, []byte{})
+	kdf := concatkdf.New(crypto.SHA256, []byte(algorithm), zBytes, g.apu, g.apv, pubinfo, []byte{})
 	kek := make([]byte, g.keysize)
 	if _, err := kdf.Read(kek); err != nil {
 		return nil, errors.Wrap(err, "failed to read kdf")
@@ -5,6 +5,7 @@ package jwe
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
 
@@ -16,23 +17,254 @@ import (
 	"github.com/lestrrat-go/jwx/jwe/internal/content_crypt"
 	"github.com/lestrrat-go/jwx/jwe/internal/keyenc"
 	"github.com/lestrrat-go/jwx/jwe/internal/keygen"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/x25519"
 	"github.com/lestrrat-go/pdebug/v3"
 	"github.com/pkg/errors"
 )
 
 // Encrypt takes the plaintext payload and encrypts it in JWE compact format.
-func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm) ([]byte, error) {
+//
+// It accepts either a raw key (e.g. []byte, *rsa.PublicKey, etc) or a
+// jwk.Key. If the key is a jwk.Key, its key ID ("kid") is stored in the
+// header, and if `keyalg` is empty, the algorithm declared by the key
+// ("alg") is used.
+//
+// If you would like to pass custom protected headers (e.g. "typ", "cty",
+// or "apu"/"apv" for ECDH-ES), use the WithProtectedHeaders option.
+func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...Option) ([]byte, error) {
 	if pdebug.Enabled {
 		g := pdebug.FuncMarker()
 		defer g.End()
 	}
 
+	var protected Headers
+	ctx := context.Background()
+	for _, option := range options {
+		switch option.Ident() {
+		case identContext{}:
+			ctx = option.Value().(context.Context)
+		case identProtectedHeaders{}:
+			protected = option.Value().(Headers)
+		}
+	}
+
+	msg, err := encrypt(ctx, payload, contentalg, compressalg, protected, []*recipientSpec{{alg: keyalg, key: key}}, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt payload")
+	}
+
+	return Compact(msg)
+}
+
+// EncryptMulti encrypts the plaintext payload for multiple recipients,
+// and returns the result in JWE JSON serialization format.
+//
+// By default the general JSON serialization format is used. Use
+// `jwe.WithSerialization()` to specify the flattened JSON or the
+// compact serialization format. Both of these formats require
+// exactly one recipient.
+//
+// A single content encryption key is generated for the payload, and it is
+// encrypted once for each of the recipients specified via `jwe.WithRecipient()`.
+// Because of this, key encryption algorithms that do not produce an
+// encrypted key (`dir` and `ECDH-ES`) cannot be used when more than one
+// recipient is specified.
+//
+// Headers that should be shared by all recipients can be specified
+// using the WithProtectedHeaders option. The per-recipient headers given
+// to `jwe.WithRecipient()` are stored unprotected, except when the compact
+// serialization format is used: as it has no room for unprotected headers,
+// they are merged into the protected header instead.
+func EncryptMulti(payload []byte, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...Option) ([]byte, error) {
+	if pdebug.Enabled {
+		g := pdebug.FuncMarker()
+		defer g.End()
+	}
+
+	var recipients []*recipientSpec
+	var protected Headers
+	serialization := SerializationGeneralJSON
+	ctx := context.Background()
+	for _, option := range options {
+		switch option.Ident() {
+		case identContext{}:
+			ctx = option.Value().(context.Context)
+		case identRecipient{}:
+			recipients = append(recipients, option.Value().(*recipientSpec))
+		case identSerialization{}:
+			serialization = option.Value().(Serialization)
+		case identProtectedHeaders{}:
+			protected = option.Value().(Headers)
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil, errors.New(`no recipients provided`)
+	}
+
+	msg, err := encrypt(ctx, payload, contentalg, compressalg, protected, recipients, serialization == SerializationCompact)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt payload")
+	}
+
+	switch serialization {
+	case SerializationCompact:
+		return Compact(msg)
+	case SerializationGeneralJSON, SerializationFlattenedJSON:
+		return JSON(msg, WithSerialization(serialization))
+	default:
+		return nil, errors.Errorf(`invalid serialization format %d`, serialization)
+	}
+}
+
+func encrypt(ctx context.Context, payload []byte, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, protected Headers, recipients []*recipientSpec, compact bool) (*Message, error) {
 	contentcrypt, err := content_crypt.NewGeneric(contentalg)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to create AES encrypter`)
 	}
 
+	if protected == nil {
+		protected = NewHeaders()
+	}
+
+	encrypters := make([]keyenc.Encrypter, len(recipients))
+	headers := make([]Headers, len(recipients))
+	for i, recipient := range recipients {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, `context error while encrypting payload`)
+		}
+
+		keyalg, key, hdrs, err := materializeRecipient(recipient.alg, recipient.key, protected, recipient.headers)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to process key for recipient #%d`, i)
+		}
+
+		// ECDH-ES may use the agreement party information ("apu"/"apv")
+		// from either the protected or the per-recipient headers
+		merged, err := protected.Merge(ctx, hdrs)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to merge headers for recipient #%d`, i)
+		}
+
+		enc, err := buildKeyEncrypter(keyalg, key, contentalg, contentcrypt.KeySize(), merged.AgreementPartyUInfo().Bytes(), merged.AgreementPartyVInfo().Bytes())
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create key encrypter for recipient #%d`, i)
+		}
+		encrypters[i] = enc
+		headers[i] = hdrs
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `context error while encrypting payload`)
+	}
+
+	keysize := contentcrypt.KeySize()
+	if pdebug.Enabled {
+		pdebug.Printf("Encrypt: keysize = %d", keysize)
+	}
+	encctx := getEncryptCtx()
+	defer releaseEncryptCtx(encctx)
+
+	encctx.contentEncrypter = contentcrypt
+	encctx.generator = keygen.NewRandom(keysize)
+	encctx.keyEncrypters = encrypters
+	encctx.protected = protected
+	encctx.recipientHeaders = headers
+	encctx.compress = compressalg
+	encctx.compact = compact
+	msg, err := encctx.Encrypt(payload)
+	if err != nil {
+		if pdebug.Enabled {
+			pdebug.Printf("Encrypt: failed to encrypt: %s", err)
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// materializeRecipient converts a jwk.Key into a raw key. The key ID of
+// the jwk.Key is added to a copy of the headers unless either the protected
+// or the per-recipient headers already contain one, and the algorithm
+// declared by the key is used if `keyalg` is not specified.
+func materializeRecipient(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, protected, hdrs Headers) (jwa.KeyEncryptionAlgorithm, interface{}, Headers, error) {
+	jwkKey, ok := key.(jwk.Key)
+	if !ok {
+		return keyalg, key, hdrs, nil
+	}
+
+	keyalg, err := algorithmForKey(keyalg, jwkKey)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var rawkey interface{}
+	if err := jwkKey.Raw(&rawkey); err != nil {
+		return "", nil, nil, errors.Wrap(err, `failed to get raw key from jwk.Key`)
+	}
+
+	if kid := jwkKey.KeyID(); kid != "" && protected.KeyID() == "" && (hdrs == nil || hdrs.KeyID() == "") {
+		if hdrs == nil {
+			hdrs = NewHeaders()
+		} else {
+			hdrs, err = hdrs.Clone(context.TODO())
+			if err != nil {
+				return "", nil, nil, errors.Wrap(err, `failed to copy headers`)
+			}
+		}
+		if err := hdrs.Set(KeyIDKey, kid); err != nil {
+			return "", nil, nil, errors.Wrap(err, `failed to set key ID from jwk.Key`)
+		}
+	}
+	return keyalg, rawkey, hdrs, nil
+}
+
+// algorithmForKey reconciles the key encryption algorithm specified by
+// the user with the algorithm declared by the jwk.Key
+func algorithmForKey(keyalg jwa.KeyEncryptionAlgorithm, key jwk.Key) (jwa.KeyEncryptionAlgorithm, error) {
+	declared := key.Algorithm()
+	if declared == "" {
+		if keyalg == "" {
+			return "", errors.New(`key encryption algorithm was not specified, and the key does not declare one`)
+		}
+		return keyalg, nil
+	}
+
+	var v jwa.KeyEncryptionAlgorithm
+	if err := v.Accept(declared); err != nil {
+		return "", errors.Wrapf(err, `key declares an algorithm that can not be used for key encryption (%s)`, declared)
+	}
+
+	if keyalg != "" && keyalg != v {
+		return "", errors.Errorf(`key encryption algorithm %s does not match the algorithm declared by the key (%s)`, keyalg, v)
+	}
+	return v, nil
+}
+
+// isKeyCompatible returns true if the jwk.Key may be used to decrypt
+// a content encryption key that was encrypted using `alg`: the key type
+// must be suitable for the algorithm, and if the key declares an
+// algorithm, it must be the same
+func isKeyCompatible(alg jwa.KeyEncryptionAlgorithm, key jwk.Key) bool {
+	if declared := key.Algorithm(); declared != "" && declared != alg.String() {
+		return false
+	}
+
+	switch kty := key.KeyType(); alg {
+	case jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256:
+		return kty == jwa.RSA
+	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
+		return kty == jwa.EC || kty == jwa.OKP
+	case jwa.A128KW, jwa.A192KW, jwa.A256KW, jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW,
+		jwa.DIRECT, jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
+		return kty == jwa.OctetSeq
+	default:
+		return false
+	}
+}
+
+func buildKeyEncrypter(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, contentKeySize int, apu, apv []byte) (keyenc.Encrypter, error) {
+	var err error
 	var enc keyenc.Encrypter
 	switch keyalg {
 	case jwa.RSA1_5:
@@ -84,7 +316,7 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 			// https://tools.ietf.org/html/rfc7518#page-15
 			// In Direct Key Agreement mode, the output of the Concat KDF MUST be a
 			// key of the same length as that used by the "enc" algorithm.
-			keysize = contentcrypt.KeySize()
+			keysize = contentKeySize
 		case jwa.ECDH_ES_A128KW:
 			keysize = 16
 		case jwa.ECDH_ES_A192KW:
@@ -95,13 +327,13 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 
 		switch key := key.(type) {
 		case x25519.PublicKey:
-			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, key)
+			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, key, apu, apv)
 		default:
 			var pubkey ecdsa.PublicKey
 			if err := keyconv.ECDSAPublicKey(&pubkey, key); err != nil {
 				return nil, errors.Errorf("failed to build %s key encrypter", keyalg)
 			}
-			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, &pubkey)
+			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, &pubkey, apu, apv)
 		}
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create ECDHS key wrap encrypter")
@@ -116,41 +348,52 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 		if pdebug.Enabled {
 			pdebug.Printf("Encrypt: unknown key encryption algorithm: %s", keyalg)
 		}
-		return nil, errors.Errorf(`invalid key encryption algorithm (%s)`, keyalg)
-	}
-
-	keysize := contentcrypt.KeySize()
-	if pdebug.Enabled {
-		pdebug.Printf("Encrypt: keysize = %d", keysize)
-	}
-	encctx := getEncryptCtx()
-	defer releaseEncryptCtx(encctx)
-
-	encctx.contentEncrypter = contentcrypt
-	encctx.generator = keygen.NewRandom(keysize)
-	encctx.keyEncrypters = []keyenc.Encrypter{enc}
-	encctx.compress = compressalg
-	msg, err := encctx.Encrypt(payload)
-	if err != nil {
-		if pdebug.Enabled {
-			pdebug.Printf("Encrypt: failed to encrypt: %s", err)
-		}
-		return nil, errors.Wrap(err, "failed to encrypt payload")
+		return nil, &jwa.UnsupportedAlgorithmError{Algorithm: keyalg.String(), Usage: `key encryption`}
 	}
 
-	return Compact(msg)
+	return enc, nil
 }
 
 // Decrypt takes the key encryption algorithm and the corresponding
 // key to decrypt the JWE message, and returns the decrypted payload.
 // The JWE message can be either compact or full JSON format.
-func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]byte, error) {
+//
+// The key may be either a raw key or a jwk.Key. If it is a jwk.Key and
+// `alg` is empty, the algorithm declared by the key is used. For
+// RSA1_5, RSA-OAEP and RSA-OAEP-256, the key may also be a
+// crypto.Decrypter backed by an RSA key (e.g. a key stored in an HSM
+// or a KMS).
+//
+// See the various `WithXXX` functions that return a DecryptOption
+// for optional parameters that control the decryption process.
+func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, options ...DecryptOption) ([]byte, error) {
 	msg, err := Parse(buf)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse buffer for Decrypt")
 	}
 
-	return msg.Decrypt(alg, key)
+	return msg.Decrypt(alg, key, options...)
+}
+
+// DecryptWithKeySet decrypts the JWE message using a key from the given
+// key set. The key to be used is chosen by matching the key ID ("kid")
+// of each recipient against the IDs of the keys in the set, and the
+// key encryption algorithm is taken from the recipient's headers.
+// For recipients without a key ID, every key in the set whose type
+// is suitable for the recipient's algorithm is tried.
+//
+// If no key matches the key ID of any recipient, the returned error
+// is a `*jwk.KeyNotFoundError` containing the key ID that was looked up.
+//
+// If a key in the set declares an algorithm ("alg"), it must match the
+// algorithm specified in the recipient's headers.
+func DecryptWithKeySet(buf []byte, set *jwk.Set, options ...DecryptOption) ([]byte, error) {
+	msg, err := Parse(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse buffer for DecryptWithKeySet")
+	}
+
+	return msg.DecryptWithKeySet(set, options...)
 }
 
 // Parse parses the JWE message into a Message object. The JWE message
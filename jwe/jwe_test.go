@@ -1,21 +1,26 @@
 package jwe_test
 
 import (
+	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
+	"io"
 	"strings"
 	"testing"
 
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
+	"github.com/lestrrat-go/jwx/buffer"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -368,6 +373,734 @@ func TestEncode_A128KW_A128CBC_HS256(t *testing.T) {
 	}
 }
 
+func TestEncode_PBES2(t *testing.T) {
+	plaintext := []byte("Lorem ipsum")
+	password := []byte("correct horse battery staple")
+
+	algorithms := []jwa.KeyEncryptionAlgorithm{
+		jwa.PBES2_HS256_A128KW,
+		jwa.PBES2_HS384_A192KW,
+		jwa.PBES2_HS512_A256KW,
+	}
+
+	for _, alg := range algorithms {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			encrypted, err := jwe.Encrypt(plaintext, alg, password, jwa.A128CBC_HS256, jwa.NoCompress)
+			if !assert.NoError(t, err, "Encrypt succeeds") {
+				return
+			}
+
+			decrypted, err := jwe.Decrypt(encrypted, alg, password)
+			if !assert.NoError(t, err, "Decrypt succeeds") {
+				return
+			}
+
+			if !assert.Equal(t, plaintext, decrypted, "Decrypted correct plaintext") {
+				return
+			}
+
+			_, err = jwe.Decrypt(encrypted, alg, []byte("wrong password"))
+			if !assert.Error(t, err, "Decrypt with wrong password should fail") {
+				return
+			}
+
+			_, err = jwe.Decrypt(encrypted, alg, password, jwe.WithMaxPBES2Count(1000))
+			if !assert.Error(t, err, "Decrypt with p2c above the maximum should fail") {
+				return
+			}
+		})
+	}
+}
+
+func TestEncode_AESGCMKW(t *testing.T) {
+	plaintext := []byte("Lorem ipsum")
+
+	var testcases = []struct {
+		Algorithm jwa.KeyEncryptionAlgorithm
+		KeySize   int
+	}{
+		{jwa.A128GCMKW, 16},
+		{jwa.A192GCMKW, 24},
+		{jwa.A256GCMKW, 32},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Algorithm.String(), func(t *testing.T) {
+			key := make([]byte, tc.KeySize)
+			_, err := rand.Read(key)
+			if !assert.NoError(t, err, "Key generation succeeds") {
+				return
+			}
+
+			encrypted, err := jwe.Encrypt(plaintext, tc.Algorithm, key, jwa.A256GCM, jwa.NoCompress)
+			if !assert.NoError(t, err, "Encrypt succeeds") {
+				return
+			}
+
+			msg, err := jwe.Parse(encrypted)
+			if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+				return
+			}
+
+			decrypted, err := msg.Decrypt(tc.Algorithm, key)
+			if !assert.NoError(t, err, "Decrypt succeeds") {
+				return
+			}
+
+			if !assert.Equal(t, plaintext, decrypted, "Decrypted correct plaintext") {
+				return
+			}
+
+			// Check that the key decrypter can be built via the low-level API, too
+			hdrs := msg.ProtectedHeaders()
+			iv, _ := hdrs.Get(jwe.InitializationVectorKey)
+			tag, _ := hdrs.Get(jwe.TagKey)
+			var ivbuf, tagbuf buffer.Buffer
+			if !assert.NoError(t, ivbuf.Base64Decode([]byte(iv.(string))), `decoding iv should succeed`) {
+				return
+			}
+			if !assert.NoError(t, tagbuf.Base64Decode([]byte(tag.(string))), `decoding tag should succeed`) {
+				return
+			}
+
+			kd, err := jwe.NewDecrypter(tc.Algorithm, jwa.A256GCM, key).
+				KeyInitializationVector(ivbuf).
+				KeyTag(tagbuf).
+				BuildKeyDecrypter()
+			if !assert.NoError(t, err, `BuildKeyDecrypter should succeed`) {
+				return
+			}
+
+			cek, err := kd.Decrypt(msg.Recipients()[0].EncryptedKey().Bytes())
+			if !assert.NoError(t, err, `key decryption should succeed`) {
+				return
+			}
+			if !assert.Len(t, cek, 32, `cek should be 32 bytes`) {
+				return
+			}
+		})
+	}
+}
+
+func TestEncryptMulti(t *testing.T) {
+	ecprivkey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+		return
+	}
+
+	aeskey := make([]byte, 16)
+	if _, err := rand.Read(aeskey); !assert.NoError(t, err, `rand.Read should succeed`) {
+		return
+	}
+
+	password := []byte("correct horse battery staple")
+
+	var testcases = []struct {
+		Algorithm  jwa.KeyEncryptionAlgorithm
+		PublicKey  interface{}
+		PrivateKey interface{}
+		KeyID      string
+	}{
+		{jwa.RSA_OAEP, &rsaPrivKey.PublicKey, &rsaPrivKey, "rsa"},
+		{jwa.ECDH_ES_A128KW, &ecprivkey.PublicKey, ecprivkey, "ec"},
+		{jwa.A128KW, aeskey, aeskey, "aes"},
+		{jwa.A128GCMKW, aeskey, aeskey, "aesgcm"},
+		{jwa.PBES2_HS256_A128KW, password, password, "password"},
+	}
+
+	var options []jwe.Option
+	for _, tc := range testcases {
+		h := jwe.NewHeaders()
+		_ = h.Set(jwe.KeyIDKey, tc.KeyID)
+		options = append(options, jwe.WithRecipient(tc.Algorithm, tc.PublicKey, h))
+	}
+
+	payload := []byte(examplePayload)
+	encrypted, err := jwe.EncryptMulti(payload, jwa.A256GCM, jwa.NoCompress, options...)
+	if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+		return
+	}
+
+	var raw map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(encrypted, &raw), `result should be valid JSON`) {
+		return
+	}
+	if !assert.Len(t, raw["recipients"], len(testcases), `result should contain all recipients`) {
+		return
+	}
+
+	msg, err := jwe.Parse(encrypted)
+	if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+		return
+	}
+
+	for i, tc := range testcases {
+		tc := tc
+		recipient := msg.Recipients()[i]
+		if !assert.Equal(t, tc.Algorithm, recipient.Headers().Algorithm(), `"alg" should match`) {
+			return
+		}
+		if !assert.Equal(t, tc.KeyID, recipient.Headers().KeyID(), `"kid" should match`) {
+			return
+		}
+
+		t.Run(tc.Algorithm.String(), func(t *testing.T) {
+			decrypted, err := jwe.Decrypt(encrypted, tc.Algorithm, tc.PrivateKey)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := jwe.EncryptMulti(payload, jwa.A256GCM, jwa.NoCompress)
+		if !assert.Error(t, err, `jwe.EncryptMulti without recipients should fail`) {
+			return
+		}
+
+		_, err = jwe.EncryptMulti(payload, jwa.A256GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.DIRECT, aeskey, nil),
+			jwe.WithRecipient(jwa.A128KW, aeskey, nil),
+		)
+		if !assert.Error(t, err, `jwe.EncryptMulti with "dir" and other recipients should fail`) {
+			return
+		}
+	})
+}
+
+func TestDecrypt_MultipleRecipients(t *testing.T) {
+	payload := []byte(examplePayload)
+
+	var options []jwe.Option
+	var privkeys []interface{}
+	for _, kid := range []string{"first", "second", "third"} {
+		privkey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+			return
+		}
+		privkeys = append(privkeys, privkey)
+
+		h := jwe.NewHeaders()
+		_ = h.Set(jwe.KeyIDKey, kid)
+		options = append(options, jwe.WithRecipient(jwa.ECDH_ES_A128KW, &privkey.PublicKey, h))
+	}
+
+	encrypted, err := jwe.EncryptMulti(payload, jwa.A128GCM, jwa.NoCompress, options...)
+	if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+		return
+	}
+
+	t.Run("Try all recipients", func(t *testing.T) {
+		for i, privkey := range privkeys {
+			idx := -1
+			decrypted, err := jwe.Decrypt(encrypted, jwa.ECDH_ES_A128KW, privkey, jwe.WithRecipientIndex(&idx))
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+				return
+			}
+			if !assert.Equal(t, i, idx, `recipient index should match`) {
+				return
+			}
+		}
+	})
+	t.Run("Match by key ID", func(t *testing.T) {
+		idx := -1
+		decrypted, err := jwe.Decrypt(encrypted, jwa.ECDH_ES_A128KW, privkeys[1], jwe.WithKeyID("second"), jwe.WithRecipientIndex(&idx))
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+		if !assert.Equal(t, 1, idx, `recipient index should match`) {
+			return
+		}
+
+		idx = -1
+		_, err = jwe.Decrypt(encrypted, jwa.ECDH_ES_A128KW, privkeys[1], jwe.WithKeyID("first"), jwe.WithRecipientIndex(&idx))
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+		if !assert.Equal(t, -1, idx, `recipient index should be untouched`) {
+			return
+		}
+
+		_, err = jwe.Decrypt(encrypted, jwa.ECDH_ES_A128KW, privkeys[1], jwe.WithKeyID("unknown"))
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+	t.Run("No recipients", func(t *testing.T) {
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		if !assert.NoError(t, msg.Set(jwe.RecipientsKey, []jwe.Recipient{}), `msg.Set should succeed`) {
+			return
+		}
+
+		_, err = msg.Decrypt(jwa.ECDH_ES_A128KW, privkeys[0])
+		if !assert.Error(t, err, `msg.Decrypt should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `does not contain any recipients`, `error should mention missing recipients`) {
+			return
+		}
+
+		_, err = jwe.NewMessage().Decrypt(jwa.ECDH_ES_A128KW, privkeys[0])
+		if !assert.Error(t, err, `msg.Decrypt should fail`) {
+			return
+		}
+	})
+}
+
+func TestEncrypt_JWK(t *testing.T) {
+	payload := []byte(examplePayload)
+
+	rawkey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	privkey, err := jwk.New(rawkey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	pubkey, err := jwk.New(&rawkey.PublicKey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	for _, key := range []jwk.Key{privkey, pubkey} {
+		_ = key.Set(jwk.KeyIDKey, "rsa")
+		_ = key.Set(jwk.AlgorithmKey, jwa.RSA_OAEP)
+	}
+
+	aeskey := make([]byte, 16)
+	if _, err := rand.Read(aeskey); !assert.NoError(t, err, `rand.Read should succeed`) {
+		return
+	}
+	symkey, err := jwk.New(aeskey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	_ = symkey.Set(jwk.KeyIDKey, "symmetric")
+
+	t.Run("Encrypt/Decrypt", func(t *testing.T) {
+		encrypted, err := jwe.Encrypt(payload, "", pubkey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "rsa", msg.ProtectedHeaders().KeyID(), `"kid" should match`) {
+			return
+		}
+		if !assert.Equal(t, jwa.RSA_OAEP, msg.ProtectedHeaders().Algorithm(), `"alg" should match`) {
+			return
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, "", privkey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+	})
+	t.Run("Symmetric key", func(t *testing.T) {
+		encrypted, err := jwe.Encrypt(payload, jwa.A128KW, symkey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "symmetric", msg.ProtectedHeaders().KeyID(), `"kid" should match`) {
+			return
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwa.A128KW, symkey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+
+		_, err = jwe.Encrypt(payload, "", symkey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.Error(t, err, `jwe.Encrypt without an algorithm should fail`) {
+			return
+		}
+	})
+	t.Run("Algorithm mismatch", func(t *testing.T) {
+		_, err := jwe.Encrypt(payload, jwa.RSA1_5, pubkey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.Error(t, err, `jwe.Encrypt should fail`) {
+			return
+		}
+	})
+	t.Run("Key set", func(t *testing.T) {
+		encrypted, err := jwe.EncryptMulti(payload, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.A128KW, symkey, nil),
+			jwe.WithRecipient("", pubkey, nil),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		other, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+			return
+		}
+		_ = other.Set(jwk.KeyIDKey, "other")
+
+		set := jwk.NewSet(other, privkey)
+
+		idx := -1
+		decrypted, err := jwe.DecryptWithKeySet(encrypted, set, jwe.WithRecipientIndex(&idx))
+		if !assert.NoError(t, err, `jwe.DecryptWithKeySet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+		if !assert.Equal(t, 1, idx, `recipient index should match`) {
+			return
+		}
+
+		_, err = jwe.DecryptWithKeySet(encrypted, jwk.NewSet(other))
+		if !assert.Error(t, err, `jwe.DecryptWithKeySet should fail`) {
+			return
+		}
+		var notFound *jwk.KeyNotFoundError
+		if !assert.True(t, errors.As(err, &notFound), `error should be a jwk.KeyNotFoundError`) {
+			return
+		}
+		if !assert.Equal(t, "symmetric", notFound.KeyID, `key ID should match`) {
+			return
+		}
+	})
+	t.Run("Key set without key IDs", func(t *testing.T) {
+		encrypted, err := jwe.EncryptMulti(payload, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.A128KW, aeskey, nil),
+			jwe.WithRecipient(jwa.RSA_OAEP, &rawkey.PublicKey, nil),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		// Neither of these keys can decrypt the message
+		other, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+			return
+		}
+		eckey, err := jwxtest.GenerateEcdsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+			return
+		}
+
+		rsakey, err := jwk.New(rawkey)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+
+		idx := -1
+		decrypted, err := jwe.DecryptWithKeySet(encrypted, jwk.NewSet(eckey, other, rsakey), jwe.WithRecipientIndex(&idx))
+		if !assert.NoError(t, err, `jwe.DecryptWithKeySet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+		if !assert.Equal(t, 1, idx, `recipient index should match`) {
+			return
+		}
+
+		_, err = jwe.DecryptWithKeySet(encrypted, jwk.NewSet(other))
+		if !assert.Error(t, err, `jwe.DecryptWithKeySet should fail`) {
+			return
+		}
+		if !assert.False(t, errors.Is(err, jwk.ErrKeyNotFound), `error should not match jwk.ErrKeyNotFound`) {
+			return
+		}
+
+		_, err = jwe.DecryptWithKeySet(encrypted, jwk.NewSet(eckey))
+		if !assert.True(t, errors.Is(err, jwk.ErrKeyNotFound), `error should match jwk.ErrKeyNotFound`) {
+			return
+		}
+	})
+}
+
+func TestEncrypt_ProtectedHeaders(t *testing.T) {
+	payload := []byte(examplePayload)
+
+	privkey, err := jwxtest.GenerateEcdsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.ECDH_ES, jwa.ECDH_ES_A128KW} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			protected := jwe.NewHeaders()
+			_ = protected.Set(jwe.ContentTypeKey, "JWT")
+			_ = protected.Set(jwe.TypeKey, "JWE")
+			_ = protected.Set(jwe.KeyIDKey, "mykey")
+			_ = protected.Set(jwe.AgreementPartyUInfoKey, buffer.Buffer("Alice"))
+			_ = protected.Set(jwe.AgreementPartyVInfoKey, buffer.Buffer("Bob"))
+			_ = protected.Set("x-private", "foo")
+			_ = protected.Set(jwe.ContentEncryptionKey, jwa.A128CBC_HS256) // should be ignored
+
+			encrypted, err := jwe.Encrypt(payload, alg, &privkey.PublicKey, jwa.A256GCM, jwa.NoCompress, jwe.WithProtectedHeaders(protected))
+			if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+				return
+			}
+
+			msg, err := jwe.Parse(encrypted)
+			if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+				return
+			}
+
+			h := msg.ProtectedHeaders()
+			if !assert.Equal(t, "JWT", h.ContentType(), `"cty" should match`) {
+				return
+			}
+			if !assert.Equal(t, "JWE", h.Type(), `"typ" should match`) {
+				return
+			}
+			if !assert.Equal(t, "mykey", h.KeyID(), `"kid" should match`) {
+				return
+			}
+			if !assert.Equal(t, []byte("Alice"), h.AgreementPartyUInfo().Bytes(), `"apu" should match`) {
+				return
+			}
+			if !assert.Equal(t, []byte("Bob"), h.AgreementPartyVInfo().Bytes(), `"apv" should match`) {
+				return
+			}
+			if !assert.Equal(t, jwa.A256GCM, h.ContentEncryption(), `"enc" should match`) {
+				return
+			}
+			v, ok := h.Get("x-private")
+			if !assert.True(t, ok, `"x-private" should exist`) {
+				return
+			}
+			if !assert.Equal(t, "foo", v, `"x-private" should match`) {
+				return
+			}
+
+			decrypted, err := jwe.Decrypt(encrypted, alg, privkey)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestDecrypt_Critical(t *testing.T) {
+	payload := []byte(examplePayload)
+	sharedkey := []byte("0123456789abcdef")
+
+	encrypt := func(t *testing.T, crit []string, params map[string]interface{}) []byte {
+		t.Helper()
+		protected := jwe.NewHeaders()
+		_ = protected.Set(jwe.CriticalKey, crit)
+		for k, v := range params {
+			_ = protected.Set(k, v)
+		}
+
+		encrypted, err := jwe.Encrypt(payload, jwa.A128KW, sharedkey, jwa.A128GCM, jwa.NoCompress, jwe.WithProtectedHeaders(protected))
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			t.FailNow()
+		}
+		return encrypted
+	}
+
+	t.Run("Unknown extension", func(t *testing.T) {
+		encrypted := encrypt(t, []string{"x-unknown"}, map[string]interface{}{"x-unknown": true})
+		_, err := jwe.Decrypt(encrypted, jwa.A128KW, sharedkey)
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+	t.Run("Extension passed via option", func(t *testing.T) {
+		encrypted := encrypt(t, []string{"x-option"}, map[string]interface{}{"x-option": true})
+		decrypted, err := jwe.Decrypt(encrypted, jwa.A128KW, sharedkey, jwe.WithCriticalExtensions("x-option"))
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+	})
+	t.Run("Registered extension", func(t *testing.T) {
+		jwe.RegisterCriticalExtension("x-registered")
+		encrypted := encrypt(t, []string{"x-registered"}, map[string]interface{}{"x-registered": true})
+		_, err := jwe.Decrypt(encrypted, jwa.A128KW, sharedkey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+	})
+	t.Run("Extension missing from protected headers", func(t *testing.T) {
+		encrypted := encrypt(t, []string{"x-missing"}, nil)
+		_, err := jwe.Decrypt(encrypted, jwa.A128KW, sharedkey, jwe.WithCriticalExtensions("x-missing"))
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+	t.Run("Standard header", func(t *testing.T) {
+		encrypted := encrypt(t, []string{jwe.ContentTypeKey}, map[string]interface{}{jwe.ContentTypeKey: "JWT"})
+		_, err := jwe.Decrypt(encrypted, jwa.A128KW, sharedkey, jwe.WithCriticalExtensions(jwe.ContentTypeKey))
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+}
+
+func TestSerialization(t *testing.T) {
+	payload := []byte(examplePayload)
+	aeskey := make([]byte, 16)
+	if _, err := rand.Read(aeskey); !assert.NoError(t, err, `rand.Read should succeed`) {
+		return
+	}
+
+	hdrs := jwe.NewHeaders()
+	_ = hdrs.Set(jwe.KeyIDKey, "mykey")
+
+	t.Run("Flattened JSON", func(t *testing.T) {
+		encrypted, err := jwe.EncryptMulti(payload, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.A128KW, aeskey, hdrs),
+			jwe.WithSerialization(jwe.SerializationFlattenedJSON),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		var raw map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(encrypted, &raw), `json.Unmarshal should succeed`) {
+			return
+		}
+		for _, field := range []string{"protected", "header", "encrypted_key", "iv", "ciphertext", "tag"} {
+			if !assert.Contains(t, raw, field, `result should contain %#v`, field) {
+				return
+			}
+		}
+		if !assert.NotContains(t, raw, "recipients", `result should not contain "recipients"`) {
+			return
+		}
+
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		if !assert.Len(t, msg.Recipients(), 1, `there should be one recipient`) {
+			return
+		}
+		if !assert.Equal(t, jwa.A128KW, msg.ProtectedHeaders().Algorithm(), `"alg" should be in the protected header`) {
+			return
+		}
+		if !assert.Empty(t, msg.ProtectedHeaders().KeyID(), `"kid" should not be in the protected header`) {
+			return
+		}
+		if !assert.Equal(t, "mykey", msg.Recipients()[0].Headers().KeyID(), `"kid" should be in the per-recipient header`) {
+			return
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwa.A128KW, aeskey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+
+		reserialized, err := jwe.JSON(msg, jwe.WithSerialization(jwe.SerializationFlattenedJSON))
+		if !assert.NoError(t, err, `jwe.JSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, encrypted, reserialized, `serialized messages should match`) {
+			return
+		}
+	})
+	t.Run("General JSON", func(t *testing.T) {
+		encrypted, err := jwe.EncryptMulti(payload, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.A128KW, aeskey, hdrs),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		var raw map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(encrypted, &raw), `json.Unmarshal should succeed`) {
+			return
+		}
+		if !assert.Len(t, raw["recipients"], 1, `result should contain "recipients"`) {
+			return
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwa.A128KW, aeskey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+	})
+	t.Run("Compact", func(t *testing.T) {
+		encrypted, err := jwe.EncryptMulti(payload, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.A128KW, aeskey, hdrs),
+			jwe.WithSerialization(jwe.SerializationCompact),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+		if !assert.Len(t, strings.Split(string(encrypted), "."), 5, `result should be in compact form`) {
+			return
+		}
+
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "mykey", msg.ProtectedHeaders().KeyID(), `"kid" should be merged into the protected header`) {
+			return
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwa.A128KW, aeskey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `decrypted payload should match`) {
+			return
+		}
+	})
+	t.Run("Multiple recipients", func(t *testing.T) {
+		for _, s := range []jwe.Serialization{jwe.SerializationCompact, jwe.SerializationFlattenedJSON} {
+			_, err := jwe.EncryptMulti(payload, jwa.A128GCM, jwa.NoCompress,
+				jwe.WithRecipient(jwa.A128KW, aeskey, nil),
+				jwe.WithRecipient(jwa.A128KW, aeskey, nil),
+				jwe.WithSerialization(s),
+			)
+			if !assert.Error(t, err, `jwe.EncryptMulti should fail`) {
+				return
+			}
+		}
+	})
+}
+
 //nolint:thelper
 func testEncodeECDHWithKey(t *testing.T, privkey interface{}, pubkey interface{}) {
 	plaintext := []byte("Lorem ipsum")
@@ -470,7 +1203,7 @@ func Test_GHIssue207(t *testing.T) {
 			if !assert.NoError(t, err, `jwk.ParseString should succeed`) {
 				return
 			}
-			webKey := webKeys.Keys[0]
+			webKey := webKeys.Keys()[0]
 			thumbprint, err := webKey.Thumbprint(crypto.SHA1)
 			if !assert.NoError(t, err, `jwk.Thumbprint should succeed`) {
 				return
@@ -600,7 +1333,7 @@ func TestDecodePredefined_Direct(t *testing.T) {
 			if !assert.NoError(t, err, `jwk.ParseString should succeed`) {
 				return
 			}
-			webKey := webKeys.Keys[0]
+			webKey := webKeys.Keys()[0]
 			thumbprint, err := webKey.Thumbprint(crypto.SHA1)
 			if !assert.NoError(t, err, `jwk.Thumbprint should succeed`) {
 				return
@@ -661,3 +1394,171 @@ func TestGHIssue230(t *testing.T) {
 		return
 	}
 }
+
+// opaqueDecrypter hides the underlying *rsa.PrivateKey, so that it
+// can only be used through the crypto.Decrypter interface (e.g. like
+// keys stored in an HSM or a KMS)
+type opaqueDecrypter struct {
+	key *rsa.PrivateKey
+}
+
+func (d opaqueDecrypter) Public() crypto.PublicKey {
+	return d.key.Public()
+}
+
+func (d opaqueDecrypter) Decrypt(rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return d.key.Decrypt(rand, ciphertext, opts)
+}
+
+func TestDecrypt_CryptoDecrypter(t *testing.T) {
+	plaintext := []byte("Lorem ipsum")
+	decrypter := opaqueDecrypter{key: &rsaPrivKey}
+
+	for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			encrypted, err := jwe.Encrypt(plaintext, alg, &rsaPrivKey.PublicKey, jwa.A128CBC_HS256, jwa.NoCompress)
+			if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+				return
+			}
+
+			decrypted, err := jwe.Decrypt(encrypted, alg, decrypter)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, plaintext, decrypted, `decrypted payload should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("non-RSA crypto.Decrypter", func(t *testing.T) {
+		encrypted, err := jwe.Encrypt(plaintext, jwa.RSA_OAEP, &rsaPrivKey.PublicKey, jwa.A128CBC_HS256, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		eckey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+			return
+		}
+		_, err = jwe.Decrypt(encrypted, jwa.RSA_OAEP, eckey)
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+}
+
+type ctxKey struct{}
+
+// contextDecrypter records the context that was passed to DecryptContext
+type contextDecrypter struct {
+	opaqueDecrypter
+	seen *context.Context
+}
+
+func (d contextDecrypter) DecryptContext(ctx context.Context, rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	*d.seen = ctx
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.Decrypt(rand, ciphertext, opts)
+}
+
+func TestWithContext(t *testing.T) {
+	plaintext := []byte("Lorem ipsum")
+	encrypted, err := jwe.Encrypt(plaintext, jwa.RSA_OAEP, &rsaPrivKey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+	if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+		return
+	}
+
+	t.Run("context is passed to the key", func(t *testing.T) {
+		var seen context.Context
+		ctx := context.WithValue(context.Background(), ctxKey{}, "foo")
+		key := contextDecrypter{opaqueDecrypter: opaqueDecrypter{key: &rsaPrivKey}, seen: &seen}
+		decrypted, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, key, jwe.WithDecryptContext(ctx))
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, plaintext, decrypted, `decrypted payload should match`) {
+			return
+		}
+		if !assert.NotNil(t, seen, `DecryptContext should have been called`) {
+			return
+		}
+		if !assert.Equal(t, "foo", seen.Value(ctxKey{}), `context should be passed to the key`) {
+			return
+		}
+	})
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := jwe.Encrypt(plaintext, jwa.RSA_OAEP, &rsaPrivKey.PublicKey, jwa.A128GCM, jwa.NoCompress, jwe.WithContext(ctx))
+		if !assert.True(t, errors.Is(err, context.Canceled), `jwe.Encrypt should fail with context.Canceled`) {
+			return
+		}
+
+		_, err = jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsaPrivKey, jwe.WithDecryptContext(ctx))
+		if !assert.True(t, errors.Is(err, context.Canceled), `jwe.Decrypt should fail with context.Canceled`) {
+			return
+		}
+	})
+	t.Run("context canceled during encryption", func(t *testing.T) {
+		pubkey, err := jwk.New(&rsaPrivKey.PublicKey)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+
+		// The context is canceled while the first recipient is processed
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		key := cancelingKey{Key: pubkey, cancel: cancel}
+
+		_, err = jwe.EncryptMulti(plaintext, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.RSA_OAEP, key, nil),
+			jwe.WithRecipient(jwa.RSA_OAEP, &rsaPrivKey.PublicKey, nil),
+			jwe.WithContext(ctx),
+		)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jwe.EncryptMulti should fail with context.Canceled`) {
+			return
+		}
+	})
+}
+
+// cancelingKey cancels a context when the raw key is requested
+type cancelingKey struct {
+	jwk.Key
+	cancel context.CancelFunc
+}
+
+func (k cancelingKey) Raw(v interface{}) error {
+	k.cancel()
+	return k.Key.Raw(v)
+}
+
+func TestErrors(t *testing.T) {
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	other, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	encrypted, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, &key.PublicKey, jwa.A128GCM, jwa.NoCompress)
+	if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+		return
+	}
+
+	_, err = jwe.Decrypt(encrypted, jwa.RSA_OAEP, other)
+	if !assert.True(t, errors.Is(err, jwe.ErrDecryptionFailed), `error should match jwe.ErrDecryptionFailed`) {
+		return
+	}
+
+	_, err = jwe.Encrypt([]byte(examplePayload), jwa.KeyEncryptionAlgorithm("BOGUS"), &key.PublicKey, jwa.A128GCM, jwa.NoCompress)
+	if !assert.True(t, errors.Is(err, jwa.ErrUnsupportedAlgorithm), `error should match jwa.ErrUnsupportedAlgorithm`) {
+		return
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
+	"math"
 
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/jwk"
@@ -58,8 +59,22 @@ func (r *stdRecipient) UnmarshalJSON(buf []byte) error {
 }
 
 func (r *stdRecipient) MarshalJSON() ([]byte, error) {
-	var proxy recipientMarshalProxy
-	proxy.Headers = r.headers
+	// Empty headers are omitted, which is why we can't use
+	// recipientMarshalProxy here
+	var proxy struct {
+		Headers      json.RawMessage `json:"header,omitempty"`
+		EncryptedKey buffer.Buffer   `json:"encrypted_key,omitempty"`
+	}
+
+	if r.headers != nil {
+		buf, err := json.Marshal(r.headers)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to marshal recipient headers`)
+		}
+		if len(buf) > 2 {
+			proxy.Headers = buf
+		}
+	}
 	proxy.EncryptedKey = r.encryptedKey
 
 	return json.Marshal(proxy)
@@ -192,7 +207,18 @@ type messageMarshalProxy struct {
 	EncryptedKey buffer.Buffer   `json:"encrypted_key,omitempty"`
 }
 
+// MarshalJSON serializes the message using the flattened JSON serialization
+// format if it contains exactly one recipient, and the general JSON
+// serialization format otherwise.
 func (m *Message) MarshalJSON() ([]byte, error) {
+	return m.marshalJSON(len(m.Recipients()) == 1)
+}
+
+func (m *Message) marshalJSON(flattened bool) ([]byte, error) {
+	if flattened && len(m.Recipients()) != 1 {
+		return nil, errors.Errorf(`flattened JSON serialization requires exactly one recipient (got %d)`, len(m.Recipients()))
+	}
+
 	// This is slightly convoluted, but we need to encode the
 	// protected headers, so we do it by hand
 	var buf bytes.Buffer
@@ -245,21 +271,26 @@ func (m *Message) MarshalJSON() ([]byte, error) {
 	}
 
 	if recipients := m.Recipients(); len(recipients) > 0 {
-		if wrote {
-			fmt.Fprintf(&buf, `,`)
-		}
-		if len(recipients) == 1 { // Use flattened format
-			fmt.Fprintf(&buf, `%#v:`, HeadersKey)
-			if err := enc.Encode(recipients[0].Headers()); err != nil {
-				return nil, errors.Wrapf(err, `failed to encode %s field`, HeadersKey)
+		if flattened {
+			// The recipient is marshaled as a JSON object, so we just
+			// need to lift its fields to the top level
+			rbuf, err := json.Marshal(recipients[0])
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to encode recipient`)
 			}
-			if ek := recipients[0].EncryptedKey(); ek.Len() > 0 {
-				fmt.Fprintf(&buf, `,%#v:`, EncryptedKeyKey)
-				if err := enc.Encode(ek); err != nil {
-					return nil, errors.Wrapf(err, `failed to encode %s field`, EncryptedKeyKey)
+			rbuf = bytes.TrimSpace(rbuf)
+			if len(rbuf) > 2 {
+				if wrote {
+					fmt.Fprintf(&buf, `,`)
 				}
+				wrote = true
+				buf.Write(rbuf[1 : len(rbuf)-1])
 			}
 		} else {
+			if wrote {
+				fmt.Fprintf(&buf, `,`)
+			}
+			wrote = true
 			fmt.Fprintf(&buf, `%#v:`, RecipientsKey)
 			if err := enc.Encode(recipients); err != nil {
 				return nil, errors.Wrapf(err, `failed to encode %s field`, RecipientsKey)
@@ -271,6 +302,7 @@ func (m *Message) MarshalJSON() ([]byte, error) {
 		if wrote {
 			fmt.Fprintf(&buf, `,`)
 		}
+		wrote = true
 		fmt.Fprintf(&buf, `%#v:`, TagKey)
 		if err := enc.Encode(base64.EncodeToString(tag)); err != nil {
 			return nil, errors.Wrapf(err, `failed to encode %s field`, TagKey)
@@ -284,7 +316,11 @@ func (m *Message) MarshalJSON() ([]byte, error) {
 		}
 
 		if len(unprotected) > 2 {
-			fmt.Fprintf(&buf, `,%#v:%#v`, UnprotectedHeadersKey, string(unprotected))
+			if wrote {
+				fmt.Fprintf(&buf, `,`)
+			}
+			fmt.Fprintf(&buf, `%#v:`, UnprotectedHeadersKey)
+			buf.Write(unprotected)
 		}
 	}
 	fmt.Fprintf(&buf, `}`)
@@ -310,13 +346,18 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 		return errors.Wrap(err, `failed to decode protected headers`)
 	}
 
-	// if this were a flattened message, we would see a "header" and "ciphertext"
-	// field. TODO: do both of these conditions need to meet, or just one?
+	// if this were a flattened message, we would see a "header" and/or
+	// "encrypted_key" field at the top level.
 	if proxy.Headers != nil || len(proxy.EncryptedKey) > 0 {
+		if len(proxy.Recipients) > 0 {
+			return errors.New(`invalid format ("recipients" and "header"/"encrypted_key" keys cannot both be present)`)
+		}
 		recipient := NewRecipient()
 		hdrs := NewHeaders()
-		if err := json.Unmarshal(proxy.Headers, hdrs); err != nil {
-			return errors.Wrap(err, `failed to decode headers field`)
+		if proxy.Headers != nil {
+			if err := json.Unmarshal(proxy.Headers, hdrs); err != nil {
+				return errors.Wrap(err, `failed to decode headers field`)
+			}
 		}
 
 		if err := recipient.SetHeaders(hdrs); err != nil {
@@ -369,6 +410,11 @@ func (m *Message) makeDummyRecipient(enckeybuf buffer.Buffer, protected Headers)
 		return errors.Wrapf(err, "failed to remove %#v from public header", ContentEncryptionKey)
 	}
 
+	// "crit" is only allowed in the protected header
+	if err := hdrs.Remove(CriticalKey); err != nil {
+		return errors.Wrapf(err, "failed to remove %#v from public header", CriticalKey)
+	}
+
 	if err := m.Set(RecipientsKey, []Recipient{
 		&stdRecipient{
 			headers:      hdrs,
@@ -380,20 +426,89 @@ func (m *Message) makeDummyRecipient(enckeybuf buffer.Buffer, protected Headers)
 	return nil
 }
 
-// Decrypt decrypts the message using the specified algorithm and key
-func (m *Message) Decrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]byte, error) {
+// Decrypt decrypts the message using the specified algorithm and key.
+//
+// Each recipient whose "alg" matches `alg` is tried in turn, until one of
+// them can be successfully decrypted using `key`. To only try recipients
+// with a particular "kid", use `jwe.WithKeyID()`. To find out which
+// recipient was used, use `jwe.WithRecipientIndex()`.
+func (m *Message) Decrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}, options ...DecryptOption) ([]byte, error) {
 	if pdebug.Enabled {
 		g := pdebug.FuncMarker()
 		defer g.End()
 	}
 
+	maxPBES2Count := DefaultMaxPBES2Count
+	var keyID string
+	var recipientIndex *int
+	var allowedAlgorithms []jwa.KeyEncryptionAlgorithm
+	var extensions []string
+	ctx := context.Background()
+	for _, option := range options {
+		switch option.Ident() {
+		case identContext{}:
+			ctx = option.Value().(context.Context)
+		case identMaxPBES2Count{}:
+			maxPBES2Count = option.Value().(int)
+		case identKeyID{}:
+			keyID = option.Value().(string)
+		case identRecipientIndex{}:
+			recipientIndex = option.Value().(*int)
+		case identAllowedAlgorithms{}:
+			allowedAlgorithms = option.Value().([]jwa.KeyEncryptionAlgorithm)
+		case identCriticalExtensions{}:
+			extensions = append(extensions, option.Value().([]string)...)
+		}
+	}
+
+	// Parsed messages always have at least one recipient, but messages
+	// that were constructed by hand may not
+	if len(m.recipients) == 0 {
+		return nil, errors.New(`message does not contain any recipients`)
+	}
+
+	unprotected := []Headers{m.unprotectedHeaders}
+	for _, recipient := range m.recipients {
+		unprotected = append(unprotected, recipient.Headers())
+	}
+	if err := checkCritical(m.protectedHeaders, extensions, unprotected...); err != nil {
+		return nil, errors.Wrap(err, `invalid "crit" header`)
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		v, err := algorithmForKey(alg, jwkKey)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to determine key encryption algorithm`)
+		}
+		alg = v
+
+		var rawkey interface{}
+		if err := jwkKey.Raw(&rawkey); err != nil {
+			return nil, errors.Wrap(err, `failed to get raw key from jwk.Key`)
+		}
+		key = rawkey
+	}
+
+	if len(allowedAlgorithms) > 0 {
+		var allowed bool
+		for _, v := range allowedAlgorithms {
+			if v == alg {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, errors.Errorf(`key encryption algorithm %s is not allowed`, alg)
+		}
+	}
+
 	var err error
-	ctx := context.TODO()
-	h, err := m.protectedHeaders.Clone(ctx)
+	hctx := context.TODO()
+	h, err := m.protectedHeaders.Clone(hctx)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to copy protected headers`)
 	}
-	h, err = h.Merge(ctx, m.unprotectedHeaders)
+	h, err = h.Merge(hctx, m.unprotectedHeaders)
 	if err != nil {
 		if pdebug.Enabled {
 			pdebug.Printf("failed to merge unprotected header")
@@ -401,7 +516,6 @@ func (m *Message) Decrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]by
 		return nil, errors.Wrap(err, "failed to merge headers for message decryption")
 	}
 
-	enc := m.protectedHeaders.ContentEncryption()
 	var aad []byte
 	if aadContainer := m.authenticatedData; aadContainer != nil {
 		aad, err = aadContainer.Base64Encode()
@@ -415,43 +529,16 @@ func (m *Message) Decrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]by
 		return nil, errors.Wrap(err, "failed to encode protected headers")
 	}
 
-	ciphertext := m.cipherText.Bytes()
-	iv := m.initializationVector.Bytes()
-	tag := m.tag.Bytes()
-
-	dec := NewDecrypter(alg, enc, key).
-		AuthenticatedData(aad).
-		ComputedAuthenticatedData(computedAad).
-		InitializationVector(iv).
-		Tag(tag)
-
-	var plaintext []byte
 	var lastError error
-
-	// if we have no recipients, pretend like we only have one
-	recipients := m.recipients
-	if len(recipients) == 0 {
-		r := NewRecipient()
-		if err := r.SetHeaders(m.protectedHeaders); err != nil {
-			return nil, errors.Wrap(err, `failed to set headers to recipient`)
-		}
-		recipients = append(recipients, r)
-	}
-
-	for _, recipient := range recipients {
+	for i, recipient := range m.recipients {
 		// strategy: try each recipient. If we fail in one of the steps,
 		// keep looping because there might be another key with the same algo
 
-		if pdebug.Enabled {
-			pdebug.Printf("Attempting to check if we can decode for recipient (alg = %s)", recipient.Headers().Algorithm())
-		}
-
-		if recipient.Headers().Algorithm() != alg {
-			// algorithms don't match
-			continue
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, `context error while decrypting message`)
 		}
 
-		h2, err := h.Clone(ctx)
+		h2, err := h.Clone(hctx)
 		if err != nil {
 			lastError = errors.Wrap(err, `failed to copy headers (1)`)
 			if pdebug.Enabled {
@@ -460,7 +547,7 @@ func (m *Message) Decrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]by
 			continue
 		}
 
-		h2, err = h2.Merge(ctx, recipient.Headers())
+		h2, err = h2.Merge(hctx, recipient.Headers())
 		if err != nil {
 			lastError = errors.Wrap(err, `failed to copy headers (2)`)
 			if pdebug.Enabled {
@@ -469,128 +556,251 @@ func (m *Message) Decrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]by
 			continue
 		}
 
-		switch alg {
-		case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
-			epkif, ok := h2.Get(EphemeralPublicKeyKey)
-			if !ok {
-				return nil, errors.New("failed to get 'epk' field")
-			}
-			switch epk := epkif.(type) {
-			case jwk.ECDSAPublicKey:
-				var pubkey ecdsa.PublicKey
-				if err := epk.Raw(&pubkey); err != nil {
-					return nil, errors.Wrap(err, "failed to get public key")
-				}
-				dec.PublicKey(&pubkey)
-			case jwk.OKPPublicKey:
-				var pubkey interface{}
-				if err := epk.Raw(&pubkey); err != nil {
-					return nil, errors.Wrap(err, "failed to get public key")
-				}
-				dec.PublicKey(pubkey)
-			default:
-				return nil, errors.Errorf("unexpected 'epk' type %T for alg %s", epkif, alg)
-			}
+		// "alg" may be either in the per-recipient header or in the
+		// message-wide headers (e.g. flattened JSON serialization)
+		if pdebug.Enabled {
+			pdebug.Printf("Attempting to check if we can decode for recipient #%d (alg = %s, kid = %s)", i, h2.Algorithm(), h2.KeyID())
+		}
 
-			if apu := h2.AgreementPartyUInfo(); apu.Len() > 0 {
-				dec.AgreementPartyUInfo(apu.Bytes())
-			}
+		if h2.Algorithm() != alg {
+			// algorithms don't match
+			continue
+		}
 
-			if apv := h2.AgreementPartyVInfo(); apv.Len() > 0 {
-				dec.AgreementPartyVInfo(apv.Bytes())
-			}
-		case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
-			ivB64, ok := h2.Get(InitializationVectorKey)
-			if !ok {
-				return nil, errors.New("failed to get 'iv' field")
-			}
-			ivB64Str, ok := ivB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'iv': %T", ivB64)
-			}
-			tagB64, ok := h2.Get(TagKey)
-			if !ok {
-				return nil, errors.New("failed to get 'tag' field")
-			}
-			tagB64Str, ok := tagB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'tag': %T", tagB64)
+		if keyID != "" && h2.KeyID() != keyID {
+			// key IDs don't match
+			continue
+		}
+
+		dec := NewDecrypter(alg, m.protectedHeaders.ContentEncryption(), key).
+			Context(ctx).
+			AuthenticatedData(aad).
+			ComputedAuthenticatedData(computedAad).
+			InitializationVector(m.initializationVector.Bytes()).
+			Tag(m.tag.Bytes())
+
+		plaintext, err := m.decryptFor(dec, h2, recipient, maxPBES2Count)
+		if err != nil {
+			lastError = errors.Wrapf(err, `failed to decrypt for recipient #%d`, i)
+			if pdebug.Enabled {
+				pdebug.Printf(`%s`, lastError)
 			}
-			var iv, tag buffer.Buffer
-			err := iv.Base64Decode([]byte(ivB64Str))
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'iv'")
+			continue
+		}
+
+		if recipientIndex != nil {
+			*recipientIndex = i
+		}
+		return plaintext, nil
+	}
+
+	if lastError != nil {
+		return nil, errors.Wrapf(ErrDecryptionFailed, `failed to find matching recipient to decrypt key (last error = %s)`, lastError)
+	}
+	return nil, errors.Wrap(ErrDecryptionFailed, "failed to find matching recipient")
+}
+
+// DecryptWithKeySet decrypts the message using a key from the given key
+// set. See `jwe.DecryptWithKeySet` for details.
+func (m *Message) DecryptWithKeySet(set *jwk.Set, options ...DecryptOption) ([]byte, error) {
+	if set == nil {
+		return nil, errors.New(`key set must not be nil`)
+	}
+
+	ctx := context.TODO()
+	h, err := m.protectedHeaders.Clone(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to copy protected headers`)
+	}
+	h, err = h.Merge(ctx, m.unprotectedHeaders)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge headers for message decryption")
+	}
+
+	var lastError error
+	var missingKeyID string
+	for i, recipient := range m.recipients {
+		h2, err := h.Merge(ctx, recipient.Headers())
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to merge headers for recipient #%d`, i)
+		}
+
+		alg := h2.Algorithm()
+		kid := h2.KeyID()
+
+		var keys []jwk.Key
+		recipientOptions := options
+		if kid == "" {
+			// Without a key ID, try every key that can be used with the algorithm
+			keys = set.Filter(func(key jwk.Key) bool {
+				return isKeyCompatible(alg, key)
+			}).Keys()
+		} else {
+			keys = set.LookupKeyID(kid)
+			if len(keys) == 0 && missingKeyID == "" {
+				missingKeyID = kid
 			}
-			err = tag.Base64Decode([]byte(tagB64Str))
+			// Only try the recipient(s) that the key was chosen for
+			recipientOptions = append(append([]DecryptOption(nil), options...), WithKeyID(kid))
+		}
+
+		for _, key := range keys {
+			plaintext, err := m.Decrypt(alg, key, recipientOptions...)
 			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'tag'")
-			}
-			dec.KeyInitializationVector(iv)
-			dec.KeyTag(tag)
-		case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
-			saltB64, ok := h2.Get(SaltKey)
-			if !ok {
-				return nil, errors.New("failed to get 'p2s' field")
-			}
-			saltB64Str, ok := saltB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'p2s': %T", saltB64)
+				lastError = err
+				continue
 			}
+			return plaintext, nil
+		}
+	}
 
-			count, ok := h2.Get(CountKey)
-			if !ok {
-				return nil, errors.New("failed to get 'p2c' field")
-			}
-			countFlt, ok := count.(float64)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'p2c': %T", count)
+	if lastError != nil {
+		return nil, errors.Wrap(lastError, `failed to decrypt using any of the keys in the key set`)
+	}
+	return nil, &jwk.KeyNotFoundError{KeyID: missingKeyID}
+}
+
+// decryptFor decrypts the message for a single recipient. `h` must contain
+// the merged headers for the recipient.
+func (m *Message) decryptFor(dec *Decrypter, h Headers, recipient Recipient, maxPBES2Count int) ([]byte, error) {
+	switch alg := h.Algorithm(); alg {
+	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
+		epkif, ok := h.Get(EphemeralPublicKeyKey)
+		if !ok {
+			return nil, errors.New("failed to get 'epk' field")
+		}
+		switch epk := epkif.(type) {
+		case jwk.ECDSAPublicKey:
+			var pubkey ecdsa.PublicKey
+			if err := epk.Raw(&pubkey); err != nil {
+				return nil, errors.Wrap(err, "failed to get public key")
 			}
-			var salt buffer.Buffer
-			err = salt.Base64Decode([]byte(saltB64Str))
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'salt'")
+			dec.PublicKey(&pubkey)
+		case jwk.OKPPublicKey:
+			var pubkey interface{}
+			if err := epk.Raw(&pubkey); err != nil {
+				return nil, errors.Wrap(err, "failed to get public key")
 			}
-			dec.KeySalt(salt)
-			dec.KeyCount(int(countFlt))
+			dec.PublicKey(pubkey)
+		default:
+			return nil, errors.Errorf("unexpected 'epk' type %T for alg %s", epkif, alg)
+		}
+
+		if apu := h.AgreementPartyUInfo(); apu.Len() > 0 {
+			dec.AgreementPartyUInfo(apu.Bytes())
 		}
 
-		plaintext, err = dec.Decrypt(recipient.EncryptedKey().Bytes(), ciphertext)
+		if apv := h.AgreementPartyVInfo(); apv.Len() > 0 {
+			dec.AgreementPartyVInfo(apv.Bytes())
+		}
+	case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
+		iv, err := getBufferHeader(h, InitializationVectorKey)
 		if err != nil {
-			lastError = errors.Wrap(err, `failed to decrypt`)
-			continue
+			return nil, errors.Wrapf(err, `failed to get %s`, InitializationVectorKey)
+		}
+		tag, err := getBufferHeader(h, TagKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to get %s`, TagKey)
+		}
+		dec.KeyInitializationVector(iv)
+		dec.KeyTag(tag)
+	case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
+		salt, err := getBufferHeader(h, SaltKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to get %s`, SaltKey)
 		}
+		count, err := getCountHeader(h, CountKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to get %s`, CountKey)
+		}
+		if count > maxPBES2Count {
+			return nil, errors.Errorf(`'%s' value %d exceeds the maximum allowed count %d`, CountKey, count, maxPBES2Count)
+		}
+		dec.KeySalt(salt)
+		dec.KeyCount(count)
+	}
 
+	plaintext, err := dec.Decrypt(recipient.EncryptedKey().Bytes(), m.cipherText.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decrypt`)
+	}
+
+	if pdebug.Enabled {
+		pdebug.Printf("Successfully decrypted message (len %d). Checking for compression...", len(plaintext))
+	}
+
+	if h.Compression() != jwa.Deflate {
 		if pdebug.Enabled {
-			pdebug.Printf("Successfully decrypted message (len %d). Checking for compression...", len(plaintext))
+			pdebug.Printf("No compression handling necessary.")
 		}
+		return plaintext, nil
+	}
 
-		if h2.Compression() != jwa.Deflate {
-			if pdebug.Enabled {
-				pdebug.Printf("No compression handling necessary.")
-			}
-		} else {
-			if pdebug.Enabled {
-				pdebug.Printf("Uncompressing plaintext")
-			}
-			buf, err := uncompress(plaintext)
-			if err != nil {
-				lastError = errors.Wrap(err, `failed to uncompress payload`)
-				if pdebug.Enabled {
-					pdebug.Printf(`%s`, lastError)
-				}
-				continue
-			}
-			plaintext = buf
+	if pdebug.Enabled {
+		pdebug.Printf("Uncompressing plaintext")
+	}
+	buf, err := uncompress(plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to uncompress payload`)
+	}
+	return buf, nil
+}
+
+// getBufferHeader fetches the value of a header field that holds binary
+// data. Headers that were parsed from JSON contain the base64 encoded
+// string, while headers that were populated during encryption contain
+// the raw buffer.
+func getBufferHeader(h Headers, name string) (buffer.Buffer, error) {
+	v, ok := h.Get(name)
+	if !ok {
+		return nil, errors.Errorf(`failed to get '%s' field`, name)
+	}
+
+	switch v := v.(type) {
+	case buffer.Buffer:
+		return v, nil
+	case string:
+		var buf buffer.Buffer
+		if err := buf.Base64Decode([]byte(v)); err != nil {
+			return nil, errors.Wrapf(err, `failed to b64-decode '%s'`, name)
 		}
-		break
+		return buf, nil
+	default:
+		return nil, errors.Errorf(`unexpected type for '%s': %T`, name, v)
+	}
+}
+
+// getCountHeader fetches the value of a header field that holds a
+// positive integer, such as "p2c"
+func getCountHeader(h Headers, name string) (int, error) {
+	v, ok := h.Get(name)
+	if !ok {
+		return 0, errors.Errorf(`failed to get '%s' field`, name)
 	}
 
-	if plaintext == nil {
-		if lastError != nil {
-			return nil, errors.Errorf(`failed to find matching recipient to decrypt key (last error = %s)`, lastError)
+	var count int64
+	switch v := v.(type) {
+	case int:
+		count = int64(v)
+	case int64:
+		count = v
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt32 {
+			return 0, errors.Errorf(`invalid value for '%s': %v`, name, v)
+		}
+		count = int64(v)
+	case json.Number:
+		i, err := v.Int64()
+		if err != nil {
+			return 0, errors.Wrapf(err, `invalid value for '%s'`, name)
 		}
-		return nil, errors.New("failed to find matching recipient")
+		count = i
+	default:
+		return 0, errors.Errorf(`unexpected type for '%s': %T`, name, v)
 	}
 
-	return plaintext, nil
+	if count < 1 || count > math.MaxInt32 {
+		return 0, errors.Errorf(`invalid value for '%s': %d`, name, count)
+	}
+	return int(count), nil
 }
@@ -1,12 +1,164 @@
 package jwe
 
-import "github.com/lestrrat-go/option"
+import (
+	"context"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/option"
+)
 
 type Option = option.Interface
 type identPrettyJSONFormat struct{}
+type identMaxPBES2Count struct{}
+type identRecipient struct{}
+type identProtectedHeaders struct{}
+type identSerialization struct{}
+type identKeyID struct{}
+type identRecipientIndex struct{}
+type identAllowedAlgorithms struct{}
+type identContext struct{}
+type identCriticalExtensions struct{}
+
+// Serialization describes the format that a JWE message is serialized in
+type Serialization int
+
+const (
+	// SerializationCompact represents the JWE compact serialization format.
+	// It can only be used when there is exactly one recipient.
+	SerializationCompact Serialization = iota + 1
+	// SerializationGeneralJSON represents the general JWE JSON serialization
+	// format, where recipients are stored in the "recipients" array.
+	SerializationGeneralJSON
+	// SerializationFlattenedJSON represents the flattened JWE JSON
+	// serialization format, where the "header" and "encrypted_key" fields
+	// are stored at the top level of the JSON object. It can only be
+	// used when there is exactly one recipient.
+	SerializationFlattenedJSON
+)
+
+// DefaultMaxPBES2Count is the maximum PBES2 iteration count ("p2c")
+// that is accepted during decryption, unless overridden via
+// `jwe.WithMaxPBES2Count`
+const DefaultMaxPBES2Count = 100000
+
+// DecryptOption describes options that can be passed to
+// `jwe.Decrypt` and `(*jwe.Message).Decrypt`
+type DecryptOption interface {
+	Option
+	isDecryptOption()
+}
+
+type decryptOption struct {
+	Option
+}
+
+func newDecryptOption(n interface{}, v interface{}) DecryptOption {
+	return &decryptOption{Option: option.New(n, v)}
+}
+
+func (o *decryptOption) isDecryptOption() {}
 
 // WithPrettyJSONFormat specifies if the `jwe.JSON` serialization tool
 // should generate pretty-formatted output
 func WithPrettyJSONFormat(b bool) Option {
 	return option.New(identPrettyJSONFormat{}, b)
 }
+
+// WithProtectedHeaders specifies headers that should be merged into the
+// protected header of the JWE message generated by `jwe.Encrypt` and
+// `jwe.EncryptMulti`. This can be used to specify values such as "typ",
+// "cty", "kid", private header parameters, or "apu"/"apv" for the
+// ECDH-ES family of key encryption algorithms.
+//
+// The "alg", "enc", and "zip" fields are always set by the library, and
+// values for these fields in `h` are ignored.
+func WithProtectedHeaders(h Headers) Option {
+	return option.New(identProtectedHeaders{}, h)
+}
+
+// WithSerialization specifies the serialization format that should be
+// used by `jwe.JSON` and `jwe.EncryptMulti`.
+func WithSerialization(v Serialization) Option {
+	return option.New(identSerialization{}, v)
+}
+
+// WithMaxPBES2Count specifies the maximum PBES2 iteration count ("p2c")
+// that is accepted when decrypting messages using the PBES2 family of
+// key encryption algorithms. Messages that specify a larger count are
+// rejected before any key derivation is performed, so that a hostile
+// message cannot make us spend an arbitrary amount of CPU time.
+//
+// If unspecified, DefaultMaxPBES2Count is used.
+func WithMaxPBES2Count(n int) DecryptOption {
+	return newDecryptOption(identMaxPBES2Count{}, n)
+}
+
+type recipientSpec struct {
+	alg     jwa.KeyEncryptionAlgorithm
+	key     interface{}
+	headers Headers
+}
+
+// WithRecipient specifies a recipient for `jwe.EncryptMulti`. The content
+// encryption key is encrypted using `alg` and `key`, and the result is
+// stored along with the per-recipient (unprotected) header `headers`,
+// which may be nil. The "alg" header field is always set from `alg`.
+//
+// If the message is serialized in the compact format, which does not
+// support unprotected headers, `headers` is merged into the protected
+// header instead.
+func WithRecipient(alg jwa.KeyEncryptionAlgorithm, key interface{}, headers Headers) Option {
+	return option.New(identRecipient{}, &recipientSpec{
+		alg:     alg,
+		key:     key,
+		headers: headers,
+	})
+}
+
+// WithKeyID specifies that only recipients whose "kid" header matches
+// `kid` should be considered when decrypting a message.
+func WithKeyID(kid string) DecryptOption {
+	return newDecryptOption(identKeyID{}, kid)
+}
+
+// WithRecipientIndex specifies a pointer to an int, which receives the
+// index of the recipient that was used to successfully decrypt the message.
+// The value is left untouched if the decryption fails.
+func WithRecipientIndex(dst *int) DecryptOption {
+	return newDecryptOption(identRecipientIndex{}, dst)
+}
+
+// WithAllowedAlgorithms specifies the list of key encryption algorithms
+// that are acceptable when decrypting a message. If the algorithm used
+// for decryption (either specified explicitly, or taken from the message
+// headers as in `jwe.DecryptWithKeySet`) is not in the list, decryption
+// fails. If unspecified, all algorithms are accepted.
+func WithAllowedAlgorithms(algs ...jwa.KeyEncryptionAlgorithm) DecryptOption {
+	return newDecryptOption(identAllowedAlgorithms{}, algs)
+}
+
+// WithCriticalExtensions specifies the header parameter names that are
+// understood by the caller, in addition to those registered via
+// `jwe.RegisterCriticalExtension()`. Messages that list other names
+// in the "crit" header are rejected.
+func WithCriticalExtensions(names ...string) DecryptOption {
+	return newDecryptOption(identCriticalExtensions{}, names)
+}
+
+// WithContext specifies the context.Context that is used by `jwe.Encrypt()`
+// and `jwe.EncryptMulti()`. The context is checked for cancellation before
+// the content encryption key is encrypted for each recipient, and before
+// the payload is encrypted.
+//
+// To specify the context used for decryption, use `jwe.WithDecryptContext()`.
+func WithContext(ctx context.Context) Option {
+	return option.New(identContext{}, ctx)
+}
+
+// WithDecryptContext specifies the context.Context that is used by
+// `jwe.Decrypt()` and its variants. The context is checked for
+// cancellation before each recipient is decrypted, and it is passed
+// to keys that implement `jwe.ContextDecrypter`.
+func WithDecryptContext(ctx context.Context) DecryptOption {
+	return newDecryptOption(identContext{}, ctx)
+}
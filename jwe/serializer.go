@@ -85,17 +85,44 @@ func Compact(m *Message, _ ...Option) ([]byte, error) {
 }
 
 // JSON encodes the message into a JWE JSON serialization format.
+//
+// By default the flattened JSON serialization format is used when the
+// message contains exactly one recipient, and the general JSON serialization
+// format is used otherwise. Use `jwe.WithSerialization()` to explicitly
+// choose the format.
 func JSON(m *Message, options ...Option) ([]byte, error) {
 	var pretty bool
+	flattened := len(m.recipients) == 1
 	for _, option := range options {
 		switch option.Ident() {
 		case identPrettyJSONFormat{}:
 			pretty = option.Value().(bool)
+		case identSerialization{}:
+			switch v := option.Value().(Serialization); v {
+			case SerializationGeneralJSON:
+				flattened = false
+			case SerializationFlattenedJSON:
+				flattened = true
+			default:
+				return nil, errors.Errorf(`invalid serialization format for JSON: %d`, v)
+			}
 		}
 	}
 
+	f := jsonFormatter{message: m, flattened: flattened}
 	if pretty {
-		return json.MarshalIndent(m, "", "  ")
+		return json.MarshalIndent(f, "", "  ")
 	}
-	return json.Marshal(m)
+	return json.Marshal(f)
+}
+
+// jsonFormatter allows us to choose between the flattened and the general
+// JSON serialization formats while still going through json.Marshal
+type jsonFormatter struct {
+	message   *Message
+	flattened bool
+}
+
+func (f jsonFormatter) MarshalJSON() ([]byte, error) {
+	return f.message.marshalJSON(f.flattened)
 }
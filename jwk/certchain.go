@@ -41,6 +41,9 @@ func (c *CertificateChain) Accept(v interface{}) error {
 	var list []string
 
 	switch x := v.(type) {
+	case CertificateChain:
+		c.certs = append([]*x509.Certificate(nil), x.certs...)
+		return nil
 	case string:
 		list = []string{x}
 	case []interface{}:
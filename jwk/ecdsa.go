@@ -59,13 +59,29 @@ func (k *ecdsaPublicKey) FromRaw(rawKey *ecdsa.PublicKey) error {
 			return errors.Wrap(err, `failed to set header`)
 		}
 	default:
-		return errors.Errorf(`invalid elliptic curve %s`, rawKey.Curve)
+		if !ecutil.IsSecp256k1(rawKey.Curve) {
+			return errors.Errorf(`invalid elliptic curve %s`, rawKey.Curve)
+		}
+		if err := k.Set(ECDSACrvKey, jwa.Secp256k1); err != nil {
+			return errors.Wrap(err, `failed to set header`)
+		}
 	}
 
 	return nil
 }
 
+// errSecp256k1PrivateKey is returned when a secp256k1 private key is
+// imported: the secp256k1 implementation in this module is not
+// constant-time, so it must never be used with private keys
+func errSecp256k1PrivateKey() error {
+	return errors.Wrap(ErrUnsupportedPrivateKey, `secp256k1 keys are only supported for verifying signatures`)
+}
+
 func (k *ecdsaPrivateKey) FromRaw(rawKey *ecdsa.PrivateKey) error {
+	if ecutil.IsSecp256k1(rawKey.Curve) {
+		return errSecp256k1PrivateKey()
+	}
+
 	xbuf := ecutil.AllocECPointBuffer(rawKey.X, rawKey.Curve)
 	ybuf := ecutil.AllocECPointBuffer(rawKey.Y, rawKey.Curve)
 	dbuf := ecutil.AllocECPointBuffer(rawKey.D, rawKey.Curve)
@@ -100,18 +116,27 @@ func (k *ecdsaPrivateKey) FromRaw(rawKey *ecdsa.PrivateKey) error {
 	return nil
 }
 
-func buildECDSAPublicKey(alg jwa.EllipticCurveAlgorithm, xbuf, ybuf []byte) (*ecdsa.PublicKey, error) {
-	var curve elliptic.Curve
+// ellipticCurve returns the elliptic.Curve corresponding to the curve algorithm
+func ellipticCurve(alg jwa.EllipticCurveAlgorithm) (elliptic.Curve, error) {
 	switch alg {
 	case jwa.P256:
-		curve = elliptic.P256()
+		return elliptic.P256(), nil
 	case jwa.P384:
-		curve = elliptic.P384()
+		return elliptic.P384(), nil
 	case jwa.P521:
-		curve = elliptic.P521()
+		return elliptic.P521(), nil
+	case jwa.Secp256k1:
+		return ecutil.Secp256k1(), nil
 	default:
 		return nil, errors.Errorf(`invalid curve algorithm %s`, alg)
 	}
+}
+
+func buildECDSAPublicKey(alg jwa.EllipticCurveAlgorithm, xbuf, ybuf []byte) (*ecdsa.PublicKey, error) {
+	curve, err := ellipticCurve(alg)
+	if err != nil {
+		return nil, err
+	}
 
 	var x, y big.Int
 	x.SetBytes(xbuf)
@@ -131,6 +156,10 @@ func (k *ecdsaPublicKey) Raw(v interface{}) error {
 }
 
 func (k *ecdsaPrivateKey) Raw(v interface{}) error {
+	if k.Crv() == jwa.Secp256k1 {
+		return errSecp256k1PrivateKey()
+	}
+
 	pubk, err := buildECDSAPublicKey(k.Crv(), k.x, k.y)
 	if err != nil {
 		return errors.Wrap(err, `failed to build public key`)
@@ -145,6 +174,9 @@ func (k *ecdsaPrivateKey) Raw(v interface{}) error {
 	return blackmagic.AssignIfCompatible(v, &key)
 }
 
+// PublicKey returns a new key containing only the public key material.
+// Other fields such as "kid" are not carried over: use `jwk.PublicKey()`
+// to preserve them
 func (k *ecdsaPrivateKey) PublicKey() (ECDSAPublicKey, error) {
 	var privk ecdsa.PrivateKey
 	if err := k.Raw(&privk); err != nil {
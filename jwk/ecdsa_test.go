@@ -4,8 +4,13 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/lestrrat-go/jwx/internal/ecutil"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
@@ -32,11 +37,11 @@ func TestECDSA(t *testing.T) {
 			return
 		}
 
-		if !assert.Len(t, set.Keys, 1, `should be 1 key`) {
+		if !assert.Len(t, set.Keys(), 1, `should be 1 key`) {
 			return
 		}
 
-		privKey, ok := set.Keys[0].(jwk.ECDSAPrivateKey)
+		privKey, ok := set.Keys()[0].(jwk.ECDSAPrivateKey)
 		if !assert.True(t, ok, `should be jwk.ECDSAPrivateKey`) {
 			return
 		}
@@ -177,10 +182,10 @@ func TestECDSA(t *testing.T) {
 			return
 		}
 
-		if _, ok := set.Keys[0].(jwk.ECDSAPrivateKey); !assert.True(t, ok, "first key should be ECDSAPrivateKey") {
+		if _, ok := set.Keys()[0].(jwk.ECDSAPrivateKey); !assert.True(t, ok, "first key should be ECDSAPrivateKey") {
 			return
 		}
-		key := set.Keys[0].(jwk.ECDSAPrivateKey)
+		key := set.Keys()[0].(jwk.ECDSAPrivateKey)
 
 		var rawKey ecdsa.PrivateKey
 		if !assert.NoError(t, key.Raw(&rawKey), `materialize should succeed`) {
@@ -244,7 +249,7 @@ func TestECDSA(t *testing.T) {
 		if err != nil {
 			t.Fatal("Failed to parse JWK ECDSA")
 		}
-		ECDSAPrivateKey := set.Keys[0].(jwk.ECDSAPrivateKey)
+		ECDSAPrivateKey := set.Keys()[0].(jwk.ECDSAPrivateKey)
 
 		privKeyBytes, err := json.Marshal(ECDSAPrivateKey)
 		if err != nil {
@@ -305,4 +310,77 @@ func TestECDSA(t *testing.T) {
 			})
 		}
 	})
+	t.Run("secp256k1", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(ecutil.Secp256k1(), rand.Reader)
+		if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+			return
+		}
+
+		// secp256k1 is only supported for verifying signatures, so
+		// private keys are rejected
+		err = jwk.NewECDSAPrivateKey().FromRaw(key)
+		if !assert.True(t, errors.Is(err, jwk.ErrUnsupportedPrivateKey), `privkey.FromRaw should fail`) {
+			return
+		}
+		_, err = jwk.New(key)
+		if !assert.True(t, errors.Is(err, jwk.ErrUnsupportedPrivateKey), `jwk.New should fail`) {
+			return
+		}
+
+		pubkey := jwk.NewECDSAPublicKey()
+		if !assert.NoError(t, pubkey.FromRaw(&key.PublicKey), `pubkey.FromRaw should succeed`) {
+			return
+		}
+
+		if !assert.Equal(t, jwa.Secp256k1, pubkey.Crv(), `crv should be secp256k1`) {
+			return
+		}
+
+		buf, err := json.Marshal(pubkey)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		parsed, err := jwk.ParseKey(buf)
+		if !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+			return
+		}
+		var rawkey ecdsa.PublicKey
+		if !assert.NoError(t, parsed.Raw(&rawkey), `parsed.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, key.X, rawkey.X, `X should match`) {
+			return
+		}
+		if !assert.Equal(t, key.Y, rawkey.Y, `Y should match`) {
+			return
+		}
+
+		var fields map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(buf, &fields), `json.Unmarshal should succeed`) {
+			return
+		}
+		fields[jwk.ECDSADKey] = base64.RawURLEncoding.EncodeToString(key.D.Bytes())
+		buf, err = json.Marshal(fields)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		_, err = jwk.ParseKey(buf)
+		if !assert.True(t, errors.Is(err, jwk.ErrUnsupportedPrivateKey), `jwk.ParseKey should fail for private keys`) {
+			return
+		}
+
+		pubtp, err := pubkey.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `pubkey.Thumbprint should succeed`) {
+			return
+		}
+
+		h := crypto.SHA256.New()
+		fmt.Fprintf(h, `{"crv":"secp256k1","kty":"EC","x":"%s","y":"%s"}`,
+			base64.RawURLEncoding.EncodeToString(pubkey.X()),
+			base64.RawURLEncoding.EncodeToString(pubkey.Y()),
+		)
+		if !assert.Equal(t, h.Sum(nil), pubtp, `Thumbprint should match RFC 7638 computation`) {
+			return
+		}
+	})
 }
@@ -0,0 +1,73 @@
+package jwk
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrKeyNotFound is matched by `errors.Is()` when no key suitable for
+// the requested operation could be found in a key set. To find out which
+// key ID was requested, use `errors.As()` with KeyNotFoundError.
+var ErrKeyNotFound = errors.New(`key not found`)
+
+// KeyNotFoundError is returned when no key suitable for the requested
+// operation could be found in a key set, typically because no key
+// matches the key ID ("kid") specified in the message.
+type KeyNotFoundError struct {
+	// KeyID is the key ID that was requested. It is empty if the
+	// message did not specify a key ID
+	KeyID string
+}
+
+func (e *KeyNotFoundError) Error() string {
+	if e.KeyID == "" {
+		return `failed to find matching key in key set`
+	}
+	return fmt.Sprintf(`failed to find matching key for key ID %#v in key set`, e.KeyID)
+}
+
+// Is returns true if target is ErrKeyNotFound
+func (e *KeyNotFoundError) Is(target error) bool {
+	return target == ErrKeyNotFound
+}
+
+// ErrUnsupportedKeyType is matched by `errors.Is()` when a raw key of
+// an unsupported type is given to functions such as `jwk.New()`
+var ErrUnsupportedKeyType = errors.New(`unsupported key type`)
+
+// ErrDuplicateKeyID is matched by `errors.Is()` when a key is added to
+// a key set that already contains a key with the same key ID
+var ErrDuplicateKeyID = errors.New(`duplicate key ID`)
+
+// ErrUnsupportedPrivateKey is matched by `errors.Is()` when a private key
+// is given for a curve that is only supported for verifying signatures,
+// such as secp256k1. Public keys for these curves can still be used.
+var ErrUnsupportedPrivateKey = errors.New(`unsupported private key`)
+
+// ErrSymmetricKey is matched by `errors.Is()` when a public key is
+// requested for a symmetric key, which does not have one
+var ErrSymmetricKey = errors.New(`symmetric keys do not have a public key`)
+
+// ErrInvalidKey is matched by `errors.Is()` when a key is rejected by
+// `jwk.Validate()`. To find out which field is invalid, use `errors.As()`
+// with ValidationError.
+var ErrInvalidKey = errors.New(`invalid key`)
+
+// ValidationError is returned by `jwk.Validate()` when a key is unsafe,
+// or when its fields are inconsistent with each other
+type ValidationError struct {
+	// Field is the name of the offending field, such as "n" or "key_ops"
+	Field string
+	// Reason describes why the field is invalid
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf(`invalid %#v field: %s`, e.Field, e.Reason)
+}
+
+// Is returns true if target is ErrInvalidKey
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidKey
+}
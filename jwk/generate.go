@@ -0,0 +1,150 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRSABits     = 2048
+	minRSABits         = 2048
+	defaultOctetLength = 32
+	minOctetLength     = 16
+)
+
+// Generate generates a new private key of the given key type, and returns
+// it as a jwk.Key. The key ID ("kid") of the key is set to its thumbprint,
+// as computed by `jwk.AssignKeyID()`.
+//
+// The parameters of the key depend on the key type:
+//
+// * jwa.RSA generates an RSA key, using WithRSABits (default: 2048)
+// * jwa.EC generates an EC key, using WithCurve (default: jwa.P256)
+// * jwa.OKP generates an OKP key, using WithCurve (default: jwa.Ed25519)
+// * jwa.OctetSeq generates a symmetric key, using WithOctetLength (default: 32)
+//
+// RSA keys smaller than 2048 bits and symmetric keys shorter than
+// 16 bytes are rejected. EC keys support the jwa.P256, jwa.P384
+// and jwa.P521 curves, and OKP keys support the jwa.Ed25519 and
+// jwa.X25519 curves. jwa.Secp256k1 keys can not be generated, as
+// secp256k1 is only supported for verifying signatures.
+//
+// The "use", "alg" and "key_ops" fields of the key can be set using the
+// WithKeyUsage, WithKeyAlgorithm and WithKeyOps options. The hash used
+// to compute the key ID can be changed using WithThumbprintHash.
+func Generate(kty jwa.KeyType, options ...Option) (Key, error) {
+	bits := defaultRSABits
+	length := defaultOctetLength
+	var crv jwa.EllipticCurveAlgorithm
+	var usage KeyUsageType
+	var alg string
+	var ops KeyOperationList
+	for _, option := range options {
+		switch option.Ident() {
+		case identRSABits{}:
+			bits = option.Value().(int)
+		case identOctetLength{}:
+			length = option.Value().(int)
+		case identCurve{}:
+			crv = option.Value().(jwa.EllipticCurveAlgorithm)
+		case identKeyUsage{}:
+			usage = option.Value().(KeyUsageType)
+		case identKeyAlgorithm{}:
+			alg = option.Value().(string)
+		case identKeyOps{}:
+			ops = option.Value().(KeyOperationList)
+		}
+	}
+
+	var raw interface{}
+	switch kty {
+	case jwa.RSA:
+		if bits < minRSABits {
+			return nil, errors.Errorf(`RSA key size must be at least %d bits (got %d)`, minRSABits, bits)
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate RSA private key`)
+		}
+		raw = key
+	case jwa.EC:
+		if crv == "" {
+			crv = jwa.P256
+		}
+		if crv == jwa.Secp256k1 {
+			return nil, errors.Errorf(`generating keys for curve %s is not supported`, crv)
+		}
+		curve, err := ellipticCurve(crv)
+		if err != nil {
+			return nil, errors.Wrap(err, `invalid curve for EC key`)
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate ECDSA private key`)
+		}
+		raw = key
+	case jwa.OKP:
+		switch crv {
+		case "", jwa.Ed25519:
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to generate Ed25519 private key`)
+			}
+			raw = key
+		case jwa.X25519:
+			_, key, err := x25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to generate X25519 private key`)
+			}
+			raw = key
+		default:
+			return nil, errors.Errorf(`invalid curve for OKP key: %s`, crv)
+		}
+	case jwa.OctetSeq:
+		if length < minOctetLength {
+			return nil, errors.Errorf(`symmetric key length must be at least %d bytes (got %d)`, minOctetLength, length)
+		}
+		key := make([]byte, length)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrap(err, `failed to generate symmetric key`)
+		}
+		raw = key
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedKeyType, `invalid key type %s for jwk.Generate`, kty)
+	}
+
+	key, err := New(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create jwk.Key`)
+	}
+
+	if usage != "" {
+		if err := key.Set(KeyUsageKey, usage); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %s`, KeyUsageKey)
+		}
+	}
+
+	if alg != "" {
+		if err := key.Set(AlgorithmKey, alg); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %s`, AlgorithmKey)
+		}
+	}
+
+	if len(ops) > 0 {
+		if err := key.Set(KeyOpsKey, ops); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %s`, KeyOpsKey)
+		}
+	}
+
+	if err := AssignKeyID(key, options...); err != nil {
+		return nil, errors.Wrap(err, `failed to assign key ID`)
+	}
+
+	return key, nil
+}
@@ -2,6 +2,7 @@ package jwk
 
 import (
 	"crypto/x509"
+	"sync"
 
 	"github.com/lestrrat-go/iter/arrayiter"
 	"github.com/lestrrat-go/iter/mapiter"
@@ -39,9 +40,14 @@ const (
 )
 
 // Set is a convenience struct to allow generating and parsing
-// JWK sets as opposed to single JWKs
+// JWK sets as opposed to single JWKs. Use `jwk.NewSet()` or
+// `(*jwk.Set).Add()` to populate it, and `(*jwk.Set).Keys()` to
+// access the keys. The zero value is an empty set.
+//
+// The methods of Set are safe to be called concurrently.
 type Set struct {
-	Keys []Key
+	keys []Key
+	mu   sync.RWMutex
 }
 
 type HeaderVisitor = iter.MapVisitor
@@ -56,6 +62,28 @@ type AutoRefreshOption interface {
 	autoRefreshOptionMarker
 }
 
+// ParseOption is an option that can be passed to `jwk.Parse()` and its variants
+type ParseOption interface {
+	Option
+	parseOptionMarker
+}
+
+// ValidateOption is an option that can be passed to `jwk.Validate()`.
+// It can also be passed to `jwk.Parse()` and its variants, where it is
+// used to validate the keys if the WithValidate option is specified
+type ValidateOption interface {
+	ParseOption
+	validateOptionMarker
+}
+
+type validateOptionMarker interface {
+	validateOption() bool
+}
+
+type parseOptionMarker interface {
+	parseOption() bool
+}
+
 type autoRefreshOptionMarker interface {
 	autoRefreshOption() bool
 }
@@ -12,6 +12,7 @@ import (
 	"crypto/rsa"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -109,7 +110,7 @@ func New(key interface{}) (Key, error) {
 		}
 		return k, nil
 	default:
-		return nil, errors.Errorf(`invalid key type '%T' for jwk.New`, key)
+		return nil, errors.Wrapf(ErrUnsupportedKeyType, `invalid key type '%T' for jwk.New`, key)
 	}
 }
 
@@ -169,8 +170,109 @@ func PublicKeyOf(v interface{}) (interface{}, error) {
 	}
 }
 
-// Fetch fetches a JWK resource specified by a URL
+// privateKeyParams lists the fields that hold private key material. Note
+// that "d" is shared by RSA, EC and OKP keys
+var privateKeyParams = map[string]struct{}{
+	RSADKey:  {},
+	RSAPKey:  {},
+	RSAQKey:  {},
+	RSADPKey: {},
+	RSADQKey: {},
+	RSAQIKey: {},
+	"oth":    {},
+}
+
+// publicKeyOps maps the operations of a private key to the corresponding
+// operations of its public key. Operations that require the private key
+// and have no public counterpart, such as "deriveKey", are dropped
+var publicKeyOps = map[KeyOperation]KeyOperation{
+	KeyOpSign:      KeyOpVerify,
+	KeyOpVerify:    KeyOpVerify,
+	KeyOpDecrypt:   KeyOpEncrypt,
+	KeyOpEncrypt:   KeyOpEncrypt,
+	KeyOpUnwrapKey: KeyOpWrapKey,
+	KeyOpWrapKey:   KeyOpWrapKey,
+}
+
+// PublicKey creates the public key counterpart of the given key. Unlike
+// `jwk.PublicKeyOf()`, which works on raw keys, the fields of the key such
+// as "kid", "use", "alg", "x5c" and private parameters are carried over to
+// the new key, while the private key material (e.g. "d") is not. "key_ops"
+// is converted to the corresponding public operations (e.g. "sign" becomes
+// "verify"). If the key is already a public key, a copy is returned.
+//
+// Symmetric keys do not have a public counterpart, and an error that
+// matches ErrSymmetricKey is returned.
+func PublicKey(key Key) (Key, error) {
+	if key.KeyType() == jwa.OctetSeq {
+		return nil, errors.Wrap(ErrSymmetricKey, `failed to create public key`)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, errors.Wrap(err, `failed to get raw key`)
+	}
+
+	rawpub, err := PublicKeyOf(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to get public key`)
+	}
+
+	pubkey, err := New(rawpub)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create public key`)
+	}
+
+	ctx := context.Background()
+	for iter := key.Iterate(ctx); iter.Next(ctx); {
+		pair := iter.Pair()
+		name := pair.Key.(string)
+		if name == KeyTypeKey {
+			continue
+		}
+		if _, ok := privateKeyParams[name]; ok {
+			continue
+		}
+		if _, ok := pubkey.Get(name); ok {
+			// key material, which has already been set by New()
+			continue
+		}
+
+		value := pair.Value
+		if name == KeyOpsKey {
+			var ops []KeyOperation
+			seen := make(map[KeyOperation]struct{})
+			for _, op := range pair.Value.(KeyOperationList) {
+				v, ok := publicKeyOps[op]
+				if !ok {
+					continue
+				}
+				if _, ok := seen[v]; ok {
+					continue
+				}
+				seen[v] = struct{}{}
+				ops = append(ops, v)
+			}
+			if len(ops) == 0 {
+				continue
+			}
+			value = ops
+		}
+
+		if err := pubkey.Set(name, value); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %s`, name)
+		}
+	}
+	return pubkey, nil
+}
+
+// Fetch wraps FetchWithContext using the background context.
 func Fetch(urlstring string, options ...Option) (*Set, error) {
+	return FetchWithContext(context.Background(), urlstring, options...)
+}
+
+// FetchWithContext fetches a JWK resource specified by a URL
+func FetchWithContext(ctx context.Context, urlstring string, options ...Option) (*Set, error) {
 	u, err := url.Parse(urlstring)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to parse url`)
@@ -178,7 +280,7 @@ func Fetch(urlstring string, options ...Option) (*Set, error) {
 
 	switch u.Scheme {
 	case "http", "https":
-		return FetchHTTP(urlstring, options...)
+		return FetchHTTPWithContext(ctx, urlstring, options...)
 	case "file":
 		f, err := os.Open(u.Path)
 		if err != nil {
@@ -228,8 +330,8 @@ func FetchHTTPWithContext(ctx context.Context, jwkurl string, options ...Option)
 // and assigns the "raw" key to the given parameter. The key must either be
 // a pointer to an empty interface, or a pointer to the actual raw key type
 // such as *rsa.PrivateKey, *ecdsa.PublicKey, *[]byte, etc.
-func ParseRawKey(data []byte, rawkey interface{}) error {
-	key, err := ParseKey(data)
+func ParseRawKey(data []byte, rawkey interface{}, options ...ParseOption) error {
+	key, err := ParseKey(data, options...)
 	if err != nil {
 		return errors.Wrap(err, `failed to parse key`)
 	}
@@ -244,7 +346,65 @@ func ParseRawKey(data []byte, rawkey interface{}) error {
 // ParseKey parses a single key JWK. This method will report failure for
 // JWK with multiple keys, even if the JWK is valid: You must specify a single
 // key only.
-func ParseKey(data []byte) (Key, error) {
+//
+// If the WithPEM option is specified, the data is parsed as PEM instead,
+// and it must contain exactly one key or certificate chain.
+//
+// If the WithValidate option is specified, the key is validated using
+// `jwk.Validate()`.
+func ParseKey(data []byte, options ...ParseOption) (Key, error) {
+	key, err := parseKey(data, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if validate, validateOptions := validateConfig(options); validate {
+		if err := Validate(key, validateOptions...); err != nil {
+			return nil, errors.Wrap(err, `failed to validate key`)
+		}
+	}
+	return key, nil
+}
+
+// validateConfig extracts the WithValidate option and the ValidateOptions
+// from the options given to the parse functions
+func validateConfig(options []ParseOption) (bool, []ValidateOption) {
+	var validate bool
+	var validateOptions []ValidateOption
+	for _, option := range options {
+		if v, ok := option.(ValidateOption); ok {
+			validateOptions = append(validateOptions, v)
+			continue
+		}
+
+		switch option.Ident() {
+		case identValidate{}:
+			validate = option.Value().(bool)
+		}
+	}
+	return validate, validateOptions
+}
+
+func parseKey(data []byte, options ...ParseOption) (Key, error) {
+	var usePEM bool
+	for _, option := range options {
+		switch option.Ident() {
+		case identPEM{}:
+			usePEM = option.Value().(bool)
+		}
+	}
+
+	if usePEM {
+		keys, err := parsePEM(data)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse PEM encoded key`)
+		}
+		if len(keys) != 1 {
+			return nil, errors.Errorf(`expected exactly one key in PEM data, got %d`, len(keys))
+		}
+		return keys[0], nil
+	}
+
 	var hint struct {
 		Kty string          `json:"kty"`
 		D   json.RawMessage `json:"d"`
@@ -284,10 +444,17 @@ func ParseKey(data []byte) (Key, error) {
 		return nil, errors.Wrapf(err, `failed to unmarshal JSON into key (%T)`, key)
 	}
 
+	if privkey, ok := key.(*ecdsaPrivateKey); ok && privkey.Crv() == jwa.Secp256k1 {
+		return nil, errSecp256k1PrivateKey()
+	}
+
 	return key, nil
 }
 
 func (s *Set) UnmarshalJSON(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	var proxy struct {
 		Keys []json.RawMessage `json:"keys"`
 	}
@@ -301,14 +468,14 @@ func (s *Set) UnmarshalJSON(data []byte) error {
 		if err != nil {
 			return errors.Wrap(err, `failed to unmarshal key from JSON headers`)
 		}
-		s.Keys = append(s.Keys, k)
+		s.keys = append(s.keys, k)
 	} else {
 		for i, buf := range proxy.Keys {
 			k, err := ParseKey([]byte(buf))
 			if err != nil {
 				return errors.Wrapf(err, `failed to unmarshal key #%d (total %d) from multi-key JWK set`, i+1, len(proxy.Keys))
 			}
-			s.Keys = append(s.Keys, k)
+			s.keys = append(s.keys, k)
 		}
 	}
 	return nil
@@ -319,8 +486,48 @@ func (s *Set) UnmarshalJSON(data []byte) error {
 // format the incoming data is in, you might want to consider using
 // "github.com/lestrrat-go/jwx/internal/json" directly
 //
-// Note that a successful parsing does NOT guarantee a valid key
-func Parse(in io.Reader) (*Set, error) {
+// If the WithPEM option is specified, the data is parsed as PEM instead.
+//
+// Note that a successful parsing does NOT guarantee a valid key, unless
+// the WithValidate option is specified, in which case each key is
+// validated using `jwk.Validate()`.
+func Parse(in io.Reader, options ...ParseOption) (*Set, error) {
+	set, err := parse(in, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if validate, validateOptions := validateConfig(options); validate {
+		for i, key := range set.Keys() {
+			if err := Validate(key, validateOptions...); err != nil {
+				return nil, errors.Wrapf(err, `failed to validate key #%d`, i+1)
+			}
+		}
+	}
+	return set, nil
+}
+
+func parse(in io.Reader, options ...ParseOption) (*Set, error) {
+	var usePEM bool
+	for _, option := range options {
+		switch option.Ident() {
+		case identPEM{}:
+			usePEM = option.Value().(bool)
+		}
+	}
+
+	if usePEM {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to read PEM data`)
+		}
+		keys, err := parsePEM(data)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse PEM data`)
+		}
+		return NewSet(keys...), nil
+	}
+
 	var s Set
 	if err := json.NewDecoder(in).Decode(&s); err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal JWK")
@@ -330,25 +537,25 @@ func Parse(in io.Reader) (*Set, error) {
 
 // ParseBytes parses JWK from the incoming byte buffer.
 //
-// Note that a successful parsing does NOT guarantee a valid key
-func ParseBytes(buf []byte) (*Set, error) {
-	return Parse(bytes.NewReader(buf))
+// Note that a successful parsing does NOT guarantee a valid key, unless
+// the WithValidate option is specified
+func ParseBytes(buf []byte, options ...ParseOption) (*Set, error) {
+	return Parse(bytes.NewReader(buf), options...)
 }
 
 // ParseString parses JWK from the incoming string.
 //
-// Note that a successful parsing does NOT guarantee a valid key
-func ParseString(s string) (*Set, error) {
-	return Parse(strings.NewReader(s))
+// Note that a successful parsing does NOT guarantee a valid key, unless
+// the WithValidate option is specified
+func ParseString(s string, options ...ParseOption) (*Set, error) {
+	return Parse(strings.NewReader(s), options...)
 }
 
 // LookupKeyID looks for keys matching the given key id. Note that the
 // Set *may* contain multiple keys with the same key id
-func (s Set) LookupKeyID(kid string) []Key {
+func (s *Set) LookupKeyID(kid string) []Key {
 	var keys []Key
-	for iter := s.Iterate(context.TODO()); iter.Next(context.TODO()); {
-		pair := iter.Pair()
-		key := pair.Value.(Key)
+	for _, key := range s.Keys() {
 		if key.KeyID() == kid {
 			keys = append(keys, key)
 		}
@@ -357,12 +564,15 @@ func (s Set) LookupKeyID(kid string) []Key {
 }
 
 func (s *Set) Len() int {
-	return len(s.Keys)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
 }
 
 func (s *Set) Iterate(ctx context.Context) KeyIterator {
-	ch := make(chan *KeyPair, s.Len())
-	go iterate(ctx, s.Keys, ch)
+	keys := s.Keys()
+	ch := make(chan *KeyPair, len(keys))
+	go iterate(ctx, keys, ch)
 	return arrayiter.New(ch)
 }
 
@@ -25,7 +25,7 @@ func Example() {
 	}
 
 	// If you KNOW you have exactly one key, you can just
-	// use set.Keys[0]
+	// use set.Keys()[0]
 	keys := set.LookupKeyID("mykey")
 	if len(keys) == 0 {
 		log.Printf("failed to lookup key: %s", err)
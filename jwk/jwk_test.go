@@ -5,10 +5,16 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
@@ -41,10 +47,10 @@ func TestParse(t *testing.T) {
 				return
 			}
 
-			if !assert.True(t, len(set.Keys) > 0, "set.Keys should be greater than 0") {
+			if !assert.True(t, set.Len() > 0, "set should contain at least one key") {
 				return
 			}
-			for _, key := range set.Keys {
+			for _, key := range set.Keys() {
 				if !assert.True(t, reflect.TypeOf(key).AssignableTo(expected), "key should be a %s", expected) {
 					return
 				}
@@ -381,10 +387,12 @@ func TestRoundtrip(t *testing.T) {
 		if !assert.NoError(t, err, `tc.generate should succeed`) {
 			return
 		}
-		ks1.Keys = append(ks1.Keys, key)
+		if !assert.NoError(t, ks1.Add(key), `ks1.Add should succeed`) {
+			return
+		}
 	}
 
-	buf, err := json.MarshalIndent(ks1, "", "  ")
+	buf, err := json.MarshalIndent(&ks1, "", "  ")
 	if !assert.NoError(t, err, "JSON marshal succeeded") {
 		return
 	}
@@ -628,3 +636,279 @@ func TestIssue270(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	testcases := []struct {
+		Name     string
+		KeyType  jwa.KeyType
+		Options  []jwk.Option
+		Expected interface{}
+		Check    func(*testing.T, interface{})
+	}{
+		{
+			Name:     "RSA",
+			KeyType:  jwa.RSA,
+			Options:  []jwk.Option{jwk.WithRSABits(3072)},
+			Expected: (*jwk.RSAPrivateKey)(nil),
+			Check: func(t *testing.T, raw interface{}) {
+				assert.Equal(t, 3072, raw.(*rsa.PrivateKey).N.BitLen(), `modulus should be 3072 bits`)
+			},
+		},
+		{
+			Name:     "EC (default curve)",
+			KeyType:  jwa.EC,
+			Expected: (*jwk.ECDSAPrivateKey)(nil),
+			Check: func(t *testing.T, raw interface{}) {
+				assert.Equal(t, "P-256", raw.(*ecdsa.PrivateKey).Curve.Params().Name, `curve should be P-256`)
+			},
+		},
+		{
+			Name:     "OKP (default curve)",
+			KeyType:  jwa.OKP,
+			Expected: (*jwk.OKPPrivateKey)(nil),
+			Check: func(t *testing.T, raw interface{}) {
+				assert.IsType(t, ed25519.PrivateKey(nil), raw, `raw key should be ed25519.PrivateKey`)
+			},
+		},
+		{
+			Name:     "OKP (X25519)",
+			KeyType:  jwa.OKP,
+			Options:  []jwk.Option{jwk.WithCurve(jwa.X25519)},
+			Expected: (*jwk.OKPPrivateKey)(nil),
+			Check: func(t *testing.T, raw interface{}) {
+				assert.IsType(t, x25519.PrivateKey(nil), raw, `raw key should be x25519.PrivateKey`)
+			},
+		},
+		{
+			Name:     "oct",
+			KeyType:  jwa.OctetSeq,
+			Options:  []jwk.Option{jwk.WithOctetLength(64)},
+			Expected: (*jwk.SymmetricKey)(nil),
+			Check: func(t *testing.T, raw interface{}) {
+				assert.Len(t, raw, 64, `key should be 64 bytes`)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			key, err := jwk.Generate(tc.KeyType, tc.Options...)
+			if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+				return
+			}
+			if !assert.Implements(t, tc.Expected, key, `key should implement %T`, tc.Expected) {
+				return
+			}
+			if !assert.Equal(t, tc.KeyType, key.KeyType(), `key type should match`) {
+				return
+			}
+
+			thumbprint, err := key.Thumbprint(crypto.SHA256)
+			if !assert.NoError(t, err, `Thumbprint should succeed`) {
+				return
+			}
+			if !assert.Equal(t, base64.EncodeToString(thumbprint), key.KeyID(), `key ID should be the thumbprint`) {
+				return
+			}
+
+			if tc.Check != nil {
+				var raw interface{}
+				if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+					return
+				}
+				tc.Check(t, raw)
+			}
+		})
+	}
+
+	t.Run("parameters", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwk.Generate(jwa.EC,
+			jwk.WithCurve(jwa.P384),
+			jwk.WithKeyUsage(jwk.ForSignature),
+			jwk.WithKeyAlgorithm(jwa.ES384),
+			jwk.WithKeyOps(jwk.KeyOpSign, jwk.KeyOpVerify),
+		)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "sig", key.KeyUsage(), `"use" should match`) {
+			return
+		}
+		if !assert.Equal(t, "ES384", key.Algorithm(), `"alg" should match`) {
+			return
+		}
+		if !assert.Equal(t, jwk.KeyOperationList{jwk.KeyOpSign, jwk.KeyOpVerify}, key.KeyOps(), `"key_ops" should match`) {
+			return
+		}
+	})
+	t.Run("invalid parameters", func(t *testing.T) {
+		t.Parallel()
+		invalid := []struct {
+			KeyType jwa.KeyType
+			Options []jwk.Option
+		}{
+			{KeyType: jwa.RSA, Options: []jwk.Option{jwk.WithRSABits(1024)}},
+			{KeyType: jwa.EC, Options: []jwk.Option{jwk.WithCurve(jwa.X25519)}},
+			{KeyType: jwa.EC, Options: []jwk.Option{jwk.WithCurve(jwa.Secp256k1)}},
+			{KeyType: jwa.OKP, Options: []jwk.Option{jwk.WithCurve(jwa.P256)}},
+			{KeyType: jwa.OctetSeq, Options: []jwk.Option{jwk.WithOctetLength(8)}},
+			{KeyType: jwa.InvalidKeyType},
+		}
+		for _, tc := range invalid {
+			_, err := jwk.Generate(tc.KeyType, tc.Options...)
+			if !assert.Error(t, err, `jwk.Generate should fail for %s`, tc.KeyType) {
+				return
+			}
+		}
+	})
+}
+
+func TestPublicKey(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name     string
+		KeyType  jwa.KeyType
+		Options  []jwk.Option
+		Expected interface{}
+	}{
+		{Name: "RSA", KeyType: jwa.RSA, Options: []jwk.Option{jwk.WithKeyAlgorithm(jwa.RS256)}, Expected: (*jwk.RSAPublicKey)(nil)},
+		{Name: "EC", KeyType: jwa.EC, Options: []jwk.Option{jwk.WithKeyAlgorithm(jwa.ES256)}, Expected: (*jwk.ECDSAPublicKey)(nil)},
+		{Name: "OKP (Ed25519)", KeyType: jwa.OKP, Options: []jwk.Option{jwk.WithKeyAlgorithm(jwa.EdDSA)}, Expected: (*jwk.OKPPublicKey)(nil)},
+		{Name: "OKP (X25519)", KeyType: jwa.OKP, Options: []jwk.Option{jwk.WithCurve(jwa.X25519), jwk.WithKeyAlgorithm(jwa.ECDH_ES)}, Expected: (*jwk.OKPPublicKey)(nil)},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			options := append([]jwk.Option{jwk.WithKeyOps(jwk.KeyOpSign, jwk.KeyOpDecrypt)}, tc.Options...)
+			key, err := jwk.Generate(tc.KeyType, options...)
+			if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+				return
+			}
+			if !assert.NoError(t, key.Set(`private-param`, `value`), `key.Set should succeed`) {
+				return
+			}
+
+			pubkey, err := jwk.PublicKey(key)
+			if !assert.NoError(t, err, `jwk.PublicKey should succeed`) {
+				return
+			}
+			if !assert.Implements(t, tc.Expected, pubkey, `key should implement %T`, tc.Expected) {
+				return
+			}
+
+			if !assert.Equal(t, key.KeyID(), pubkey.KeyID(), `"kid" should be preserved`) {
+				return
+			}
+			if !assert.Equal(t, key.Algorithm(), pubkey.Algorithm(), `"alg" should be preserved`) {
+				return
+			}
+			if !assert.Equal(t, jwk.KeyOperationList{jwk.KeyOpVerify, jwk.KeyOpEncrypt}, pubkey.KeyOps(), `"key_ops" should be converted`) {
+				return
+			}
+			if v, ok := pubkey.Get(`private-param`); !assert.True(t, ok, `private params should be preserved`) || !assert.Equal(t, `value`, v, `private params should be preserved`) {
+				return
+			}
+
+			tp1, err := key.Thumbprint(crypto.SHA256)
+			if !assert.NoError(t, err, `Thumbprint should succeed`) {
+				return
+			}
+			tp2, err := pubkey.Thumbprint(crypto.SHA256)
+			if !assert.NoError(t, err, `Thumbprint should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tp1, tp2, `thumbprints should match`) {
+				return
+			}
+
+			buf, err := json.Marshal(pubkey)
+			if !assert.NoError(t, err, `json.Marshal should succeed`) {
+				return
+			}
+			var fields map[string]interface{}
+			if !assert.NoError(t, json.Unmarshal(buf, &fields), `json.Unmarshal should succeed`) {
+				return
+			}
+			if !assert.NotContains(t, fields, `d`, `"d" should not be present`) {
+				return
+			}
+
+			// public keys can be converted as well
+			again, err := jwk.PublicKey(pubkey)
+			if !assert.NoError(t, err, `jwk.PublicKey should succeed`) {
+				return
+			}
+			if !assert.Equal(t, pubkey.KeyID(), again.KeyID(), `"kid" should be preserved`) {
+				return
+			}
+		})
+	}
+
+	t.Run("x5c", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwk.Generate(jwa.EC, jwk.WithKeyUsage(jwk.ForSignature))
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+		var raw ecdsa.PrivateKey
+		if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+			return
+		}
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "jwx"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &raw.PublicKey, &raw)
+		if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Set(jwk.X509CertChainKey, []string{base64.EncodeToString(der)}), `key.Set should succeed`) {
+			return
+		}
+
+		pubkey, err := jwk.PublicKey(key)
+		if !assert.NoError(t, err, `jwk.PublicKey should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "sig", pubkey.KeyUsage(), `"use" should be preserved`) {
+			return
+		}
+		if !assert.Len(t, pubkey.X509CertChain(), 1, `"x5c" should be preserved`) {
+			return
+		}
+		if !assert.Equal(t, der, pubkey.X509CertChain()[0].Raw, `"x5c" should be preserved`) {
+			return
+		}
+	})
+	t.Run("symmetric", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwk.Generate(jwa.OctetSeq)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+
+		_, err = jwk.PublicKey(key)
+		if !assert.True(t, errors.Is(err, jwk.ErrSymmetricKey), `error should match ErrSymmetricKey`) {
+			return
+		}
+
+		var set jwk.Set
+		if !assert.NoError(t, set.Add(key), `set.Add should succeed`) {
+			return
+		}
+		_, err = set.PublicSet()
+		if !assert.True(t, errors.Is(err, jwk.ErrSymmetricKey), `error should match ErrSymmetricKey`) {
+			return
+		}
+	})
+}
@@ -125,6 +125,9 @@ func (k *okpPrivateKey) Raw(v interface{}) error {
 	return blackmagic.AssignIfCompatible(v, privk)
 }
 
+// PublicKey returns a new key containing only the public key material.
+// Other fields such as "kid" are not carried over: use `jwk.PublicKey()`
+// to preserve them
 func (k *okpPrivateKey) PublicKey() (OKPPublicKey, error) {
 	newKey := NewOKPPublicKey()
 	switch k.Crv() {
@@ -2,10 +2,13 @@ package jwk
 
 import (
 	"crypto"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/lestrrat-go/backoff/v2"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/option"
 )
 
@@ -16,6 +19,17 @@ type identThumbprintHash struct{}
 type identRefreshInterval struct{}
 type identMinRefreshInterval struct{}
 type identRefreshBackoff struct{}
+type identSetParser struct{}
+type identRSABits struct{}
+type identCurve struct{}
+type identOctetLength struct{}
+type identKeyUsage struct{}
+type identKeyAlgorithm struct{}
+type identKeyOps struct{}
+type identPEM struct{}
+type identPKCS8 struct{}
+type identValidate struct{}
+type identMinRSABits struct{}
 
 // WithHTTPClient allows users to specify the "net/http".Client object that
 // is used when fetching *jwk.Set objects.
@@ -86,3 +100,110 @@ func WithRefreshBackoff(v backoff.Policy) AutoRefreshOption {
 		option.New(identRefreshBackoff{}, v),
 	}
 }
+
+// SetParser parses the resource fetched by jwk.AutoRefresh into a *jwk.Set
+type SetParser func(io.Reader) (*Set, error)
+
+// WithSetParser specifies the function that is used by jwk.AutoRefresh to
+// parse the resource that it fetches. This allows resources that are not
+// JWKS (for example PEM encoded certificate chains referred to by "x5u"
+// headers) to be fetched, cached and refreshed. By default `jwk.Parse`
+// is used.
+func WithSetParser(p SetParser) AutoRefreshOption {
+	return &autoRefreshOption{
+		option.New(identSetParser{}, p),
+	}
+}
+
+// WithRSABits specifies the size of the modulus in bits of RSA keys
+// generated by `jwk.Generate()`
+func WithRSABits(bits int) Option {
+	return option.New(identRSABits{}, bits)
+}
+
+// WithCurve specifies the curve of EC and OKP keys generated by
+// `jwk.Generate()`
+func WithCurve(crv jwa.EllipticCurveAlgorithm) Option {
+	return option.New(identCurve{}, crv)
+}
+
+// WithOctetLength specifies the length in bytes of symmetric keys
+// generated by `jwk.Generate()`
+func WithOctetLength(n int) Option {
+	return option.New(identOctetLength{}, n)
+}
+
+// WithKeyUsage specifies the value of the "use" field of keys
+// generated by `jwk.Generate()`
+func WithKeyUsage(v KeyUsageType) Option {
+	return option.New(identKeyUsage{}, v)
+}
+
+// WithKeyAlgorithm specifies the value of the "alg" field of keys
+// generated by `jwk.Generate()`, such as jwa.RS256 or jwa.RSA_OAEP
+func WithKeyAlgorithm(alg fmt.Stringer) Option {
+	return option.New(identKeyAlgorithm{}, alg.String())
+}
+
+// WithKeyOps specifies the value of the "key_ops" field of keys
+// generated by `jwk.Generate()`
+func WithKeyOps(ops ...KeyOperation) Option {
+	return option.New(identKeyOps{}, KeyOperationList(ops))
+}
+
+type parseOption struct {
+	Option
+}
+
+func (*parseOption) parseOption() bool {
+	return true
+}
+
+// WithPEM specifies that the data given to `jwk.Parse()` and its variants
+// is PEM encoded. Keys may be encoded in PKCS#8, PKCS#1 (RSA) or SEC1 (EC)
+// format for private keys, and SPKI or PKCS#1 (RSA) format for public
+// keys. The data may contain multiple PEM blocks, each of which yields a
+// key. Consecutive certificates are treated as a certificate chain, and
+// yield the public key of the first certificate, with the chain stored
+// in its "x5c" field. Encrypted PEM blocks are not supported.
+func WithPEM(v bool) ParseOption {
+	return &parseOption{
+		option.New(identPEM{}, v),
+	}
+}
+
+// WithPKCS8 specifies that `jwk.EncodePEM()` and its variants should
+// encode private keys in PKCS#8 format, regardless of their type
+func WithPKCS8(v bool) Option {
+	return option.New(identPKCS8{}, v)
+}
+
+type validateOption struct {
+	Option
+}
+
+func (*validateOption) parseOption() bool {
+	return true
+}
+
+func (*validateOption) validateOption() bool {
+	return true
+}
+
+// WithValidate specifies that `jwk.Parse()` and its variants should
+// validate the keys using `jwk.Validate()`, and report an error if any
+// of them is invalid. ValidateOptions such as WithMinRSABits that are
+// passed to the same function are used for the validation.
+func WithValidate(v bool) ParseOption {
+	return &parseOption{
+		option.New(identValidate{}, v),
+	}
+}
+
+// WithMinRSABits specifies the minimum size of the modulus of RSA keys
+// accepted by `jwk.Validate()`. The default is 2048 bits.
+func WithMinRSABits(v int) ValidateOption {
+	return &validateOption{
+		option.New(identMinRSABits{}, v),
+	}
+}
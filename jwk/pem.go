@@ -0,0 +1,250 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/pkg/errors"
+)
+
+const (
+	pemCertificate  = "CERTIFICATE"
+	pemPrivateKey   = "PRIVATE KEY"
+	pemPublicKey    = "PUBLIC KEY"
+	pemRSAPrivate   = "RSA PRIVATE KEY"
+	pemRSAPublic    = "RSA PUBLIC KEY"
+	pemECPrivateKey = "EC PRIVATE KEY"
+)
+
+// parsePEM parses the PEM encoded keys and certificates in src. Each key
+// block yields a key. Consecutive certificate blocks are treated as a
+// certificate chain, and yield the public key of the first certificate,
+// with the chain stored in its "x5c" field.
+func parsePEM(src []byte) ([]Key, error) {
+	var keys []Key
+	var chain []*x509.Certificate
+
+	// flush turns the pending certificate chain into a key
+	flush := func() error {
+		if len(chain) == 0 {
+			return nil
+		}
+		key, err := keyFromCertificateChain(chain)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		chain = nil
+		return nil
+	}
+
+	for i := 1; ; i++ {
+		var block *pem.Block
+		block, src = pem.Decode(src)
+		if block == nil {
+			break
+		}
+
+		if block.Type == pemCertificate {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to parse certificate in PEM block #%d`, i)
+			}
+			chain = append(chain, cert)
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return nil, errors.Wrapf(err, `failed to create key from certificate chain before PEM block #%d`, i)
+		}
+
+		if _, ok := block.Headers["Proc-Type"]; ok {
+			return nil, errors.Errorf(`encrypted PEM block #%d is not supported`, i)
+		}
+
+		raw, err := parsePEMBlock(block)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to parse PEM block #%d`, i)
+		}
+
+		key, err := New(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create key from PEM block #%d`, i)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := flush(); err != nil {
+		return nil, errors.Wrap(err, `failed to create key from certificate chain`)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New(`no PEM blocks found`)
+	}
+
+	if len(bytes.TrimSpace(src)) > 0 {
+		return nil, errors.New(`extra data found after PEM blocks`)
+	}
+	return keys, nil
+}
+
+func parsePEMBlock(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case pemRSAPrivate:
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case pemRSAPublic:
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case pemECPrivateKey:
+		return x509.ParseECPrivateKey(block.Bytes)
+	case pemPrivateKey:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case pemPublicKey:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, errors.Errorf(`unsupported PEM block type %s`, block.Type)
+	}
+}
+
+// keyFromCertificateChain creates a key from the public key of the first
+// certificate in the chain, and stores the chain in its "x5c" field
+func keyFromCertificateChain(chain []*x509.Certificate) (Key, error) {
+	key, err := New(chain[0].PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create key from certificate`)
+	}
+
+	encoded := make([]string, len(chain))
+	for i, cert := range chain {
+		encoded[i] = base64.EncodeToStringStd(cert.Raw)
+	}
+	if err := key.Set(X509CertChainKey, encoded); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, X509CertChainKey)
+	}
+	return key, nil
+}
+
+// ParseDER parses a single DER encoded key or certificate. Private keys
+// may be encoded in PKCS#8, PKCS#1 (RSA) or SEC1 (EC) format, and
+// public keys may be encoded in SPKI or PKCS#1 (RSA) format. If the data
+// is a certificate, the public key of the certificate is returned, with
+// the certificate stored in its "x5c" field.
+func ParseDER(der []byte) (Key, error) {
+	parsers := []func([]byte) (interface{}, error){
+		x509.ParsePKCS8PrivateKey,
+		func(b []byte) (interface{}, error) { return x509.ParsePKCS1PrivateKey(b) },
+		func(b []byte) (interface{}, error) { return x509.ParseECPrivateKey(b) },
+		x509.ParsePKIXPublicKey,
+		func(b []byte) (interface{}, error) { return x509.ParsePKCS1PublicKey(b) },
+	}
+	for _, parser := range parsers {
+		raw, err := parser(der)
+		if err != nil {
+			continue
+		}
+		return New(raw)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.New(`failed to parse DER encoded data as a key or a certificate`)
+	}
+	return keyFromCertificateChain([]*x509.Certificate{cert})
+}
+
+// EncodePEM encodes the key in PEM format. By default, RSA private keys
+// are encoded in PKCS#1 format ("RSA PRIVATE KEY"), EC private keys are
+// encoded in SEC1 format ("EC PRIVATE KEY"), and OKP private keys are
+// encoded in PKCS#8 format ("PRIVATE KEY"). Use the WithPKCS8 option to
+// encode all private keys in PKCS#8 format. Public keys are encoded in
+// SPKI format ("PUBLIC KEY").
+//
+// Symmetric keys, X25519 keys and keys on curves that are not supported
+// by "crypto/x509" (such as secp256k1) can not be encoded.
+func EncodePEM(key Key, options ...Option) ([]byte, error) {
+	block, err := encodePEMBlock(key, options...)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// EncodeDER is the same as EncodePEM, but returns the DER encoded key
+// without the PEM armor.
+func EncodeDER(key Key, options ...Option) ([]byte, error) {
+	block, err := encodePEMBlock(key, options...)
+	if err != nil {
+		return nil, err
+	}
+	return block.Bytes, nil
+}
+
+// EncodeSetPEM encodes all keys in the set in PEM format, as
+// described in EncodePEM, and concatenates the results.
+func EncodeSetPEM(set *Set, options ...Option) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, key := range set.Keys() {
+		block, err := encodePEMBlock(key, options...)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to encode key #%d`, i+1)
+		}
+		if err := pem.Encode(&buf, block); err != nil {
+			return nil, errors.Wrapf(err, `failed to encode key #%d`, i+1)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func encodePEMBlock(key Key, options ...Option) (*pem.Block, error) {
+	var pkcs8 bool
+	for _, option := range options {
+		switch option.Ident() {
+		case identPKCS8{}:
+			pkcs8 = option.Value().(bool)
+		}
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, errors.Wrap(err, `failed to get raw key`)
+	}
+
+	var typ string
+	var der []byte
+	var err error
+	switch raw := raw.(type) {
+	case *rsa.PrivateKey:
+		if pkcs8 {
+			typ = pemPrivateKey
+			der, err = x509.MarshalPKCS8PrivateKey(raw)
+		} else {
+			typ = pemRSAPrivate
+			der = x509.MarshalPKCS1PrivateKey(raw)
+		}
+	case *ecdsa.PrivateKey:
+		if pkcs8 {
+			typ = pemPrivateKey
+			der, err = x509.MarshalPKCS8PrivateKey(raw)
+		} else {
+			typ = pemECPrivateKey
+			der, err = x509.MarshalECPrivateKey(raw)
+		}
+	case ed25519.PrivateKey:
+		typ = pemPrivateKey
+		der, err = x509.MarshalPKCS8PrivateKey(raw)
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		typ = pemPublicKey
+		der, err = x509.MarshalPKIXPublicKey(raw)
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedKeyType, `key type %T can not be encoded in PEM format`, raw)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to marshal %T`, raw)
+	}
+
+	return &pem.Block{Type: typ, Bytes: der}, nil
+}
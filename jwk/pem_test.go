@@ -0,0 +1,242 @@
+package jwk_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPEM(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwk.Generate(jwa.RSA)
+	if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+		return
+	}
+	eckey, err := jwk.Generate(jwa.EC, jwk.WithCurve(jwa.P384))
+	if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+		return
+	}
+	okpkey, err := jwk.Generate(jwa.OKP)
+	if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+		return
+	}
+
+	publicKeyOf := func(t *testing.T, key jwk.Key) jwk.Key {
+		t.Helper()
+		var raw interface{}
+		if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+			t.FailNow()
+		}
+		pubraw, err := jwk.PublicKeyOf(raw)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			t.FailNow()
+		}
+		pubkey, err := jwk.New(pubraw)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			t.FailNow()
+		}
+		return pubkey
+	}
+
+	sameKey := func(t *testing.T, expected, actual jwk.Key) bool {
+		t.Helper()
+		if !assert.Equal(t, expected.KeyType(), actual.KeyType(), `key types should match`) {
+			return false
+		}
+		tp1, err := expected.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `Thumbprint should succeed`) {
+			return false
+		}
+		tp2, err := actual.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `Thumbprint should succeed`) {
+			return false
+		}
+		return assert.Equal(t, tp1, tp2, `thumbprints should match`)
+	}
+
+	t.Run("Roundtrip", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name      string
+			Key       jwk.Key
+			Options   []jwk.Option
+			BlockType string
+		}{
+			{Name: "RSA private key (PKCS#1)", Key: rsakey, BlockType: "RSA PRIVATE KEY"},
+			{Name: "RSA private key (PKCS#8)", Key: rsakey, Options: []jwk.Option{jwk.WithPKCS8(true)}, BlockType: "PRIVATE KEY"},
+			{Name: "RSA public key", Key: publicKeyOf(t, rsakey), BlockType: "PUBLIC KEY"},
+			{Name: "EC private key (SEC1)", Key: eckey, BlockType: "EC PRIVATE KEY"},
+			{Name: "EC private key (PKCS#8)", Key: eckey, Options: []jwk.Option{jwk.WithPKCS8(true)}, BlockType: "PRIVATE KEY"},
+			{Name: "EC public key", Key: publicKeyOf(t, eckey), BlockType: "PUBLIC KEY"},
+			{Name: "Ed25519 private key", Key: okpkey, BlockType: "PRIVATE KEY"},
+			{Name: "Ed25519 public key", Key: publicKeyOf(t, okpkey), BlockType: "PUBLIC KEY"},
+		}
+
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				encoded, err := jwk.EncodePEM(tc.Key, tc.Options...)
+				if !assert.NoError(t, err, `jwk.EncodePEM should succeed`) {
+					return
+				}
+
+				block, _ := pem.Decode(encoded)
+				if !assert.NotNil(t, block, `pem.Decode should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.BlockType, block.Type, `block types should match`) {
+					return
+				}
+
+				parsed, err := jwk.ParseKey(encoded, jwk.WithPEM(true))
+				if !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+					return
+				}
+				if !sameKey(t, tc.Key, parsed) {
+					return
+				}
+
+				der, err := jwk.EncodeDER(tc.Key, tc.Options...)
+				if !assert.NoError(t, err, `jwk.EncodeDER should succeed`) {
+					return
+				}
+				if !assert.Equal(t, block.Bytes, der, `DER should match the PEM block`) {
+					return
+				}
+
+				parsed, err = jwk.ParseDER(der)
+				if !assert.NoError(t, err, `jwk.ParseDER should succeed`) {
+					return
+				}
+				if !sameKey(t, tc.Key, parsed) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Multiple blocks", func(t *testing.T) {
+		t.Parallel()
+		cakey, err := jwxtest.GenerateEcdsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+			return
+		}
+		leafkey, err := jwxtest.GenerateEcdsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+			return
+		}
+
+		ca := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "Test CA"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &cakey.PublicKey, cakey)
+		if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+			return
+		}
+		leaf := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "leaf"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &leafkey.PublicKey, cakey)
+		if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+			return
+		}
+
+		var buf bytes.Buffer
+		encoded, err := jwk.EncodeSetPEM(jwk.NewSet(rsakey, publicKeyOf(t, eckey)))
+		if !assert.NoError(t, err, `jwk.EncodeSetPEM should succeed`) {
+			return
+		}
+		buf.Write(encoded)
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+
+		set, err := jwk.ParseBytes(buf.Bytes(), jwk.WithPEM(true))
+		if !assert.NoError(t, err, `jwk.ParseBytes should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 3, set.Len(), `set should contain 3 keys`) {
+			return
+		}
+		if !sameKey(t, rsakey, set.Keys()[0]) {
+			return
+		}
+		if !sameKey(t, publicKeyOf(t, eckey), set.Keys()[1]) {
+			return
+		}
+
+		certkey := set.Keys()[2]
+		if !assert.Implements(t, (*jwk.ECDSAPublicKey)(nil), certkey, `key should be jwk.ECDSAPublicKey`) {
+			return
+		}
+		chain := certkey.X509CertChain()
+		if !assert.Len(t, chain, 2, `"x5c" should contain 2 certificates`) {
+			return
+		}
+		if !assert.Equal(t, leafDER, chain[0].Raw, `first certificate should be the leaf`) {
+			return
+		}
+		if !assert.Equal(t, caDER, chain[1].Raw, `second certificate should be the CA`) {
+			return
+		}
+
+		_, err = jwk.ParseKey(buf.Bytes(), jwk.WithPEM(true))
+		if !assert.Error(t, err, `jwk.ParseKey should fail for multiple keys`) {
+			return
+		}
+
+		key, err := jwk.ParseDER(leafDER)
+		if !assert.NoError(t, err, `jwk.ParseDER should succeed`) {
+			return
+		}
+		if !assert.Len(t, key.X509CertChain(), 1, `"x5c" should contain the certificate`) {
+			return
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		symmetric, err := jwk.Generate(jwa.OctetSeq)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+		_, err = jwk.EncodePEM(symmetric)
+		if !assert.Error(t, err, `jwk.EncodePEM should fail for symmetric keys`) {
+			return
+		}
+
+		invalid := [][]byte{
+			[]byte(`not PEM`),
+			pem.EncodeToMemory(&pem.Block{Type: "UNKNOWN", Bytes: []byte{0}}),
+			pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Headers: map[string]string{"Proc-Type": "4,ENCRYPTED"}, Bytes: []byte{0}}),
+		}
+		for _, data := range invalid {
+			_, err := jwk.ParseBytes(data, jwk.WithPEM(true))
+			if !assert.Error(t, err, `jwk.ParseBytes should fail`) {
+				return
+			}
+		}
+
+		_, err = jwk.ParseDER([]byte{0})
+		if !assert.Error(t, err, `jwk.ParseDER should fail`) {
+			return
+		}
+	})
+}
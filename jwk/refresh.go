@@ -2,6 +2,7 @@ package jwk
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"reflect"
 	"sync"
@@ -71,6 +72,10 @@ type target struct {
 	refreshInterval    *time.Duration
 	minRefreshInterval time.Duration
 
+	// The function to parse the fetched resource with. By default
+	// the resource is parsed as a JWKS using jwk.Parse
+	parser SetParser
+
 	url string
 
 	// The timer for refreshing the keyset. should not be set by anyone
@@ -142,6 +147,9 @@ func (af *AutoRefresh) getCached(url string) (*Set, bool) {
 // The other unspecified options, including the HTTP client, is set to
 // their default values.
 //
+// The exception is the parser specified by `jwk.WithSetParser`, which is
+// fixed when the url is first registered. It is ignored in subsequent calls.
+//
 // Configuration must propagate between goroutines, and therefore are
 // not atomic (But changes should be felt "soon enough" for practical
 // purposes)
@@ -151,8 +159,13 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 	var refreshInterval time.Duration
 	minRefreshInterval := time.Hour
 	bo := backoff.Null()
+	parser := SetParser(func(src io.Reader) (*Set, error) {
+		return Parse(src)
+	})
 	for _, option := range options {
 		switch option.Ident() {
+		case identSetParser{}:
+			parser = option.Value().(SetParser)
 		case identRefreshBackoff{}:
 			bo = option.Value().(backoff.Policy)
 		case identRefreshInterval{}:
@@ -169,6 +182,9 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 	af.muRegistry.Lock()
 	t, ok := af.registry[url]
 	if ok {
+		// The parser is fixed when the url is first registered, so that
+		// consumers of the same url can not switch it to another format
+		// (e.g. from a JWKS to a PEM encoded certificate chain)
 		if t.httpcl != httpcl {
 			t.httpcl = httpcl
 			doReconfigure = true
@@ -198,6 +214,7 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 			backoff:            bo,
 			httpcl:             httpcl,
 			minRefreshInterval: minRefreshInterval,
+			parser:             parser,
 			url:                url,
 			sem:                make(chan struct{}, 1),
 			// This is a placeholder timer so we can call Reset() on it later
@@ -236,6 +253,13 @@ func (af *AutoRefresh) releaseFetching(url string) {
 	af.muFetching.Unlock()
 }
 
+// IsRegistered returns true if the url has been configured using
+// `Configure()`
+func (af *AutoRefresh) IsRegistered(url string) bool {
+	_, ok := af.getRegistered(url)
+	return ok
+}
+
 func (af *AutoRefresh) getRegistered(url string) (*target, bool) {
 	af.muRegistry.RLock()
 	t, ok := af.registry[url]
@@ -426,8 +450,23 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 		return errors.Wrap(err, "failed to new request to remote JWK")
 	}
 
+	// Configure() may modify the target concurrently, so take a copy of
+	// the values that we need while holding the lock
 	af.muRegistry.RLock()
 	t, ok := af.registry[url]
+	var httpcl *http.Client
+	var parser SetParser
+	var refreshInterval *time.Duration
+	var minRefreshInterval time.Duration
+	if ok {
+		httpcl = t.httpcl
+		parser = t.parser
+		if t.refreshInterval != nil {
+			v := *t.refreshInterval
+			refreshInterval = &v
+		}
+		minRefreshInterval = t.minRefreshInterval
+	}
 	af.muRegistry.RUnlock()
 
 	if !ok {
@@ -444,7 +483,7 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 	}
 	var lastError error
 	for backoff.Continue(b) {
-		res, err := t.httpcl.Do(req.WithContext(ctx))
+		res, err := httpcl.Do(req.WithContext(ctx))
 		if err != nil {
 			lastError = errors.Wrap(err, "failed to fetch remote JWK")
 			continue
@@ -456,7 +495,7 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 			continue
 		}
 
-		keyset, err := Parse(res.Body)
+		keyset, err := parser(res.Body)
 		if err != nil {
 			// We don't delete the old key. We persist the old key set, even if it may be stale.
 			// so the user has something to work with
@@ -470,7 +509,7 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 		af.muCache.Lock()
 		af.cache[url] = keyset
 		af.muCache.Unlock()
-		nextInterval := calculateRefreshDuration(res, t.refreshInterval, t.minRefreshInterval)
+		nextInterval := calculateRefreshDuration(res, refreshInterval, minRefreshInterval)
 		af.resetTimerCh <- &resetTimerReq{
 			t: t,
 			d: nextInterval,
@@ -485,7 +524,7 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 		// If we failed to get a single time, then queue another fetch in the future.
 		af.resetTimerCh <- &resetTimerReq{
 			t: t,
-			d: t.minRefreshInterval,
+			d: minRefreshInterval,
 		}
 	}
 
@@ -2,6 +2,8 @@ package jwk_test
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -219,6 +221,34 @@ func TestAutoRefresh(t *testing.T) {
 			return
 		}
 	})
+	t.Run("Parser is fixed on first Configure", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(`Content-Type`, `application/json`)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"kty": "oct",
+				"k":   "c2VjcmV0",
+			})
+		}))
+		defer srv.Close()
+
+		af := jwk.NewAutoRefresh(ctx)
+		af.Configure(srv.URL)
+		af.Configure(srv.URL, jwk.WithSetParser(func(io.Reader) (*jwk.Set, error) {
+			return nil, errors.New(`this parser should not be used`)
+		}))
+
+		ks, err := af.Refresh(ctx, srv.URL)
+		if !assert.NoError(t, err, `af.Refresh should use the original parser`) {
+			return
+		}
+		if !assert.Equal(t, 1, ks.Len(), `key set should contain 1 key`) {
+			return
+		}
+	})
 }
 
 func TestRefreshSnapshot(t *testing.T) {
@@ -148,6 +148,9 @@ func (k *rsaPublicKey) Raw(v interface{}) error {
 	return blackmagic.AssignIfCompatible(v, &key)
 }
 
+// PublicKey returns a new key containing only the public key material.
+// Other fields such as "kid" are not carried over: use `jwk.PublicKey()`
+// to preserve them
 func (k rsaPrivateKey) PublicKey() (RSAPublicKey, error) {
 	var key rsa.PrivateKey
 	if err := k.Raw(&key); err != nil {
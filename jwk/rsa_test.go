@@ -100,7 +100,7 @@ func TestRSA(t *testing.T) {
 			return
 		}
 
-		rsakey, ok := set.Keys[0].(jwk.RSAPrivateKey)
+		rsakey, ok := set.Keys()[0].(jwk.RSAPrivateKey)
 		if !assert.True(t, ok, "Type assertion for RSAPrivateKey is successful") {
 			return
 		}
@@ -0,0 +1,219 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// KeyFilter is used to select keys from a Set using `(*jwk.Set).Filter()`.
+// It should return true if the key should be included in the result
+type KeyFilter func(Key) bool
+
+// FilterKeyType returns a KeyFilter that selects keys of the given key type
+func FilterKeyType(kty jwa.KeyType) KeyFilter {
+	return func(key Key) bool {
+		return key.KeyType() == kty
+	}
+}
+
+// FilterKeyUsage returns a KeyFilter that selects keys whose "use" field
+// matches the given value. Keys without a "use" field are not selected
+func FilterKeyUsage(use KeyUsageType) KeyFilter {
+	return func(key Key) bool {
+		return key.KeyUsage() == use.String()
+	}
+}
+
+// FilterAlgorithm returns a KeyFilter that selects keys whose "alg" field
+// matches the given algorithm, such as jwa.RS256 or jwa.RSA_OAEP. Keys
+// without an "alg" field are not selected
+func FilterAlgorithm(alg fmt.Stringer) KeyFilter {
+	return func(key Key) bool {
+		return key.Algorithm() == alg.String()
+	}
+}
+
+// FilterKeyOps returns a KeyFilter that selects keys whose "key_ops" field
+// contains all of the given operations. Keys without a "key_ops" field
+// are not selected
+func FilterKeyOps(ops ...KeyOperation) KeyFilter {
+	return func(key Key) bool {
+		list := key.KeyOps()
+		if len(list) == 0 {
+			return false
+		}
+
+		for _, op := range ops {
+			var found bool
+			for _, v := range list {
+				if v == op {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// NewSet creates a new set containing the given keys. Unlike Add, it
+// does not check for duplicate key IDs.
+func NewSet(keys ...Key) *Set {
+	return &Set{keys: append([]Key(nil), keys...)}
+}
+
+// Keys returns a copy of the list of keys in the set. Modifying the
+// returned slice does not affect the set, but the keys are shared.
+func (s *Set) Keys() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Key(nil), s.keys...)
+}
+
+// Add adds a key to the set. If the key has a key ID ("kid"), and the
+// set already contains a key with the same key ID, an error that
+// matches ErrDuplicateKeyID is returned and the set is left unchanged.
+func (s *Set) Add(key Key) error {
+	if key == nil {
+		return errors.New(`key must not be nil`)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if kid := key.KeyID(); kid != "" {
+		for _, v := range s.keys {
+			if v.KeyID() == kid {
+				return errors.Wrapf(ErrDuplicateKeyID, `key ID %#v already exists in the set`, kid)
+			}
+		}
+	}
+
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// RemoveByKeyID removes all keys with the given key ID from the set,
+// and returns the number of keys that were removed
+func (s *Set) RemoveByKeyID(kid string) int {
+	return s.remove(func(key Key) bool {
+		return key.KeyID() == kid
+	})
+}
+
+// RemoveByThumbprint removes all keys whose JWK thumbprint (RFC 7638),
+// computed using the given hash, matches the given thumbprint. It returns
+// the number of keys that were removed.
+func (s *Set) RemoveByThumbprint(hash crypto.Hash, thumbprint []byte) int {
+	return s.remove(func(key Key) bool {
+		computed, err := key.Thumbprint(hash)
+		if err != nil {
+			return false
+		}
+		return bytes.Equal(computed, thumbprint)
+	})
+}
+
+func (s *Set) remove(match func(Key) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		if match(key) {
+			removed++
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	if removed > 0 {
+		s.keys = keys
+	}
+	return removed
+}
+
+// Filter returns a new set containing the keys that match all of the
+// given filters, such as `jwk.FilterKeyType(jwa.EC)` or
+// `jwk.FilterKeyUsage(jwk.ForSignature)`. The keys are shared between
+// the two sets: use Clone if you need to modify them independently.
+func (s *Set) Filter(filters ...KeyFilter) *Set {
+	var set Set
+	for _, key := range s.Keys() {
+		include := true
+		for _, filter := range filters {
+			if !filter(key) {
+				include = false
+				break
+			}
+		}
+		if include {
+			set.keys = append(set.keys, key)
+		}
+	}
+	return &set
+}
+
+// Clone returns a deep copy of the set. Modifying the keys in the
+// returned set does not affect the original set
+func (s *Set) Clone() (*Set, error) {
+	var set Set
+	for i, key := range s.Keys() {
+		cloned, err := cloneKey(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to clone key #%d`, i+1)
+		}
+		set.keys = append(set.keys, cloned)
+	}
+	return &set, nil
+}
+
+// PublicSet returns a new set containing the public keys of the keys in
+// the set, so that the set can be published as a JWKS (e.g. from a
+// "jwks_uri" endpoint). Private keys are converted to their public
+// counterparts using `jwk.PublicKey()`, while public keys are copied as
+// is.
+//
+// Symmetric keys do not have a public counterpart, and must never be
+// published, so an error that matches ErrSymmetricKey is returned if
+// the set contains any. Use Filter to exclude them beforehand.
+func (s *Set) PublicSet() (*Set, error) {
+	var set Set
+	for i, key := range s.Keys() {
+		pubkey, err := PublicKey(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create public key for key #%d`, i+1)
+		}
+		set.keys = append(set.keys, pubkey)
+	}
+	return &set, nil
+}
+
+// MarshalJSON serializes the set as a JWK set, i.e. a JSON object with
+// the list of keys in the "keys" field
+func (s *Set) MarshalJSON() ([]byte, error) {
+	keys := s.Keys()
+	if keys == nil {
+		keys = []Key{}
+	}
+	return json.Marshal(struct {
+		Keys []Key `json:"keys"`
+	}{Keys: keys})
+}
+
+func cloneKey(key Key) (Key, error) {
+	buf, err := json.Marshal(key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal key`)
+	}
+	return ParseKey(buf)
+}
@@ -0,0 +1,274 @@
+package jwk_test
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	newSet := func(t *testing.T) *jwk.Set {
+		t.Helper()
+
+		rsakey, err := jwk.Generate(jwa.RSA, jwk.WithKeyUsage(jwk.ForSignature), jwk.WithKeyAlgorithm(jwa.RS256), jwk.WithKeyOps(jwk.KeyOpSign))
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			t.FailNow()
+		}
+		_ = rsakey.Set(`custom`, `value`)
+
+		eckey, err := jwk.Generate(jwa.EC, jwk.WithKeyUsage(jwk.ForEncryption), jwk.WithKeyOps(jwk.KeyOpDecrypt, jwk.KeyOpDeriveKey))
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			t.FailNow()
+		}
+		octkey, err := jwk.Generate(jwa.OctetSeq, jwk.WithKeyUsage(jwk.ForSignature))
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			t.FailNow()
+		}
+
+		var set jwk.Set
+		for _, key := range []jwk.Key{rsakey, eckey, octkey} {
+			if !assert.NoError(t, set.Add(key), `set.Add should succeed`) {
+				t.FailNow()
+			}
+		}
+		return &set
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		t.Parallel()
+		set := newSet(t)
+
+		key, err := jwk.Generate(jwa.OKP)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Set(jwk.KeyIDKey, set.Keys()[0].KeyID()), `key.Set should succeed`) {
+			return
+		}
+
+		err = set.Add(key)
+		if !assert.Error(t, err, `set.Add should fail for duplicate key IDs`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwk.ErrDuplicateKeyID), `error should match ErrDuplicateKeyID`) {
+			return
+		}
+		if !assert.Equal(t, 3, set.Len(), `set should not be modified`) {
+			return
+		}
+
+		key, err = jwk.New([]byte(`secret`))
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		if !assert.NoError(t, set.Add(key), `set.Add should succeed for keys without key IDs`) {
+			return
+		}
+		if !assert.NoError(t, set.Add(key), `set.Add should succeed for keys without key IDs`) {
+			return
+		}
+		if !assert.Equal(t, 5, set.Len(), `set should contain 5 keys`) {
+			return
+		}
+	})
+	t.Run("Remove", func(t *testing.T) {
+		t.Parallel()
+		set := newSet(t)
+
+		if !assert.Equal(t, 0, set.RemoveByKeyID(`nonexistent`), `no keys should be removed`) {
+			return
+		}
+		if !assert.Equal(t, 1, set.RemoveByKeyID(set.Keys()[0].KeyID()), `1 key should be removed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.EC, set.Keys()[0].KeyType(), `first key should be EC key`) {
+			return
+		}
+
+		tp, err := set.Keys()[0].Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `Thumbprint should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 0, set.RemoveByThumbprint(crypto.SHA1, tp), `no keys should be removed with a different hash`) {
+			return
+		}
+		if !assert.Equal(t, 1, set.RemoveByThumbprint(crypto.SHA256, tp), `1 key should be removed`) {
+			return
+		}
+		if !assert.Equal(t, 1, set.Len(), `set should contain 1 key`) {
+			return
+		}
+	})
+	t.Run("Filter", func(t *testing.T) {
+		t.Parallel()
+		set := newSet(t)
+
+		testcases := []struct {
+			Name     string
+			Filters  []jwk.KeyFilter
+			Expected []jwa.KeyType
+		}{
+			{Name: "no filters", Expected: []jwa.KeyType{jwa.RSA, jwa.EC, jwa.OctetSeq}},
+			{Name: "kty", Filters: []jwk.KeyFilter{jwk.FilterKeyType(jwa.EC)}, Expected: []jwa.KeyType{jwa.EC}},
+			{Name: "use", Filters: []jwk.KeyFilter{jwk.FilterKeyUsage(jwk.ForSignature)}, Expected: []jwa.KeyType{jwa.RSA, jwa.OctetSeq}},
+			{Name: "alg", Filters: []jwk.KeyFilter{jwk.FilterAlgorithm(jwa.RS256)}, Expected: []jwa.KeyType{jwa.RSA}},
+			{Name: "key_ops", Filters: []jwk.KeyFilter{jwk.FilterKeyOps(jwk.KeyOpDecrypt, jwk.KeyOpDeriveKey)}, Expected: []jwa.KeyType{jwa.EC}},
+			{Name: "key_ops (partial match)", Filters: []jwk.KeyFilter{jwk.FilterKeyOps(jwk.KeyOpDecrypt, jwk.KeyOpSign)}},
+			{Name: "multiple filters", Filters: []jwk.KeyFilter{jwk.FilterKeyUsage(jwk.ForSignature), jwk.FilterKeyType(jwa.OctetSeq)}, Expected: []jwa.KeyType{jwa.OctetSeq}},
+		}
+
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				filtered := set.Filter(tc.Filters...)
+				var types []jwa.KeyType
+				for _, key := range filtered.Keys() {
+					types = append(types, key.KeyType())
+				}
+				if !assert.Equal(t, tc.Expected, types, `filtered key types should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Clone", func(t *testing.T) {
+		t.Parallel()
+		set := newSet(t)
+
+		cloned, err := set.Clone()
+		if !assert.NoError(t, err, `set.Clone should succeed`) {
+			return
+		}
+		if !assert.Equal(t, set.Len(), cloned.Len(), `cloned set should have the same number of keys`) {
+			return
+		}
+
+		for i, key := range set.Keys() {
+			expected, _ := json.Marshal(key)
+			actual, _ := json.Marshal(cloned.Keys()[i])
+			if !assert.JSONEq(t, string(expected), string(actual), `cloned key should be identical`) {
+				return
+			}
+		}
+
+		_ = cloned.Keys()[0].Set(jwk.KeyIDKey, `modified`)
+		if !assert.NotEqual(t, `modified`, set.Keys()[0].KeyID(), `modifying the clone should not affect the original`) {
+			return
+		}
+	})
+	t.Run("PublicSet", func(t *testing.T) {
+		t.Parallel()
+		set := newSet(t)
+
+		_, err := set.PublicSet()
+		if !assert.True(t, errors.Is(err, jwk.ErrSymmetricKey), `set.PublicSet should fail for symmetric keys`) {
+			return
+		}
+
+		set = set.Filter(func(key jwk.Key) bool {
+			return key.KeyType() != jwa.OctetSeq
+		})
+		pubset, err := set.PublicSet()
+		if !assert.NoError(t, err, `set.PublicSet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, pubset.Len(), `set should contain 2 keys`) {
+			return
+		}
+
+		if !assert.Implements(t, (*jwk.RSAPublicKey)(nil), pubset.Keys()[0], `key should be a public key`) {
+			return
+		}
+		if !assert.Implements(t, (*jwk.ECDSAPublicKey)(nil), pubset.Keys()[1], `key should be a public key`) {
+			return
+		}
+
+		rsakey := pubset.Keys()[0]
+		if !assert.Equal(t, set.Keys()[0].KeyID(), rsakey.KeyID(), `kid should be preserved`) {
+			return
+		}
+		if !assert.Equal(t, jwk.ForSignature.String(), rsakey.KeyUsage(), `use should be preserved`) {
+			return
+		}
+		if !assert.Equal(t, jwa.RS256.String(), rsakey.Algorithm(), `alg should be preserved`) {
+			return
+		}
+		if !assert.Equal(t, jwk.KeyOperationList{jwk.KeyOpVerify}, rsakey.KeyOps(), `key_ops should be converted`) {
+			return
+		}
+		if v, ok := rsakey.Get(`custom`); !assert.True(t, ok, `private params should be preserved`) || !assert.Equal(t, `value`, v, `private params should be preserved`) {
+			return
+		}
+		if !assert.Equal(t, jwk.KeyOperationList{jwk.KeyOpEncrypt}, pubset.Keys()[1].KeyOps(), `key_ops should be converted`) {
+			return
+		}
+
+		buf, err := json.Marshal(pubset)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+
+		var raw struct {
+			Keys []map[string]interface{} `json:"keys"`
+		}
+		if !assert.NoError(t, json.Unmarshal(buf, &raw), `json.Unmarshal should succeed`) {
+			return
+		}
+		if !assert.Len(t, raw.Keys, 2, `JWKS should contain 2 keys`) {
+			return
+		}
+		for _, key := range raw.Keys {
+			for _, name := range []string{`d`, `p`, `q`, `dp`, `dq`, `qi`, `k`} {
+				if !assert.NotContains(t, key, name, `JWKS should not contain private key material`) {
+					return
+				}
+			}
+		}
+	})
+	t.Run("Concurrency", func(t *testing.T) {
+		t.Parallel()
+
+		key, err := jwk.Generate(jwa.OctetSeq)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+
+		var set jwk.Set
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				k, _ := jwk.New([]byte(fmt.Sprintf(`secret-%d`, i)))
+				_ = k.Set(jwk.KeyIDKey, fmt.Sprintf(`key-%d`, i))
+				_ = set.Add(k)
+				_ = set.Add(key)
+				set.RemoveByKeyID(key.KeyID())
+			}(i)
+			go func() {
+				defer wg.Done()
+				set.LookupKeyID(`key-0`)
+				set.Filter(jwk.FilterKeyType(jwa.OctetSeq))
+				_ = set.Keys()
+				_, _ = json.Marshal(&set)
+				for iter := set.Iterate(context.TODO()); iter.Next(context.TODO()); {
+				}
+			}()
+		}
+		wg.Wait()
+
+		if !assert.Equal(t, 10, set.Len(), `set should contain 10 keys`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,406 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
+)
+
+// algorithmConstraint describes the keys that can be used with an algorithm
+type algorithmConstraint struct {
+	kty   jwa.KeyType
+	usage KeyUsageType
+	// curves lists the curves that can be used for EC and OKP keys
+	curves []jwa.EllipticCurveAlgorithm
+	// octets is the exact length of symmetric keys, if non-zero
+	octets int
+	// minOctets is the minimum length of symmetric keys, if non-zero
+	minOctets int
+}
+
+var algorithmConstraints = map[string][]algorithmConstraint{
+	jwa.RS256.String(): {{kty: jwa.RSA, usage: ForSignature}},
+	jwa.RS384.String(): {{kty: jwa.RSA, usage: ForSignature}},
+	jwa.RS512.String(): {{kty: jwa.RSA, usage: ForSignature}},
+	jwa.PS256.String(): {{kty: jwa.RSA, usage: ForSignature}},
+	jwa.PS384.String(): {{kty: jwa.RSA, usage: ForSignature}},
+	jwa.PS512.String(): {{kty: jwa.RSA, usage: ForSignature}},
+
+	jwa.ES256.String():  {{kty: jwa.EC, usage: ForSignature, curves: []jwa.EllipticCurveAlgorithm{jwa.P256}}},
+	jwa.ES384.String():  {{kty: jwa.EC, usage: ForSignature, curves: []jwa.EllipticCurveAlgorithm{jwa.P384}}},
+	jwa.ES512.String():  {{kty: jwa.EC, usage: ForSignature, curves: []jwa.EllipticCurveAlgorithm{jwa.P521}}},
+	jwa.ES256K.String(): {{kty: jwa.EC, usage: ForSignature, curves: []jwa.EllipticCurveAlgorithm{jwa.Secp256k1}}},
+	jwa.EdDSA.String():  {{kty: jwa.OKP, usage: ForSignature, curves: []jwa.EllipticCurveAlgorithm{jwa.Ed25519}}},
+
+	jwa.HS256.String(): {{kty: jwa.OctetSeq, usage: ForSignature, minOctets: 32}},
+	jwa.HS384.String(): {{kty: jwa.OctetSeq, usage: ForSignature, minOctets: 48}},
+	jwa.HS512.String(): {{kty: jwa.OctetSeq, usage: ForSignature, minOctets: 64}},
+
+	jwa.RSA1_5.String():       {{kty: jwa.RSA, usage: ForEncryption}},
+	jwa.RSA_OAEP.String():     {{kty: jwa.RSA, usage: ForEncryption}},
+	jwa.RSA_OAEP_256.String(): {{kty: jwa.RSA, usage: ForEncryption}},
+
+	jwa.ECDH_ES.String():        ecdhConstraints,
+	jwa.ECDH_ES_A128KW.String(): ecdhConstraints,
+	jwa.ECDH_ES_A192KW.String(): ecdhConstraints,
+	jwa.ECDH_ES_A256KW.String(): ecdhConstraints,
+
+	jwa.A128KW.String():    {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 16}},
+	jwa.A192KW.String():    {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 24}},
+	jwa.A256KW.String():    {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 32}},
+	jwa.A128GCMKW.String(): {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 16}},
+	jwa.A192GCMKW.String(): {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 24}},
+	jwa.A256GCMKW.String(): {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 32}},
+
+	jwa.PBES2_HS256_A128KW.String(): {{kty: jwa.OctetSeq, usage: ForEncryption}},
+	jwa.PBES2_HS384_A192KW.String(): {{kty: jwa.OctetSeq, usage: ForEncryption}},
+	jwa.PBES2_HS512_A256KW.String(): {{kty: jwa.OctetSeq, usage: ForEncryption}},
+	jwa.DIRECT.String():             {{kty: jwa.OctetSeq, usage: ForEncryption}},
+
+	jwa.A128GCM.String():       {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 16}},
+	jwa.A192GCM.String():       {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 24}},
+	jwa.A256GCM.String():       {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 32}},
+	jwa.A128CBC_HS256.String(): {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 32}},
+	jwa.A192CBC_HS384.String(): {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 48}},
+	jwa.A256CBC_HS512.String(): {{kty: jwa.OctetSeq, usage: ForEncryption, octets: 64}},
+}
+
+var ecdhConstraints = []algorithmConstraint{
+	{kty: jwa.EC, usage: ForEncryption, curves: []jwa.EllipticCurveAlgorithm{jwa.P256, jwa.P384, jwa.P521}},
+	{kty: jwa.OKP, usage: ForEncryption, curves: []jwa.EllipticCurveAlgorithm{jwa.X25519}},
+}
+
+// keyOpsUsage maps key operations to the "use" value they are compatible with
+var keyOpsUsage = map[KeyOperation]KeyUsageType{
+	KeyOpSign:       ForSignature,
+	KeyOpVerify:     ForSignature,
+	KeyOpEncrypt:    ForEncryption,
+	KeyOpDecrypt:    ForEncryption,
+	KeyOpWrapKey:    ForEncryption,
+	KeyOpUnwrapKey:  ForEncryption,
+	KeyOpDeriveKey:  ForEncryption,
+	KeyOpDeriveBits: ForEncryption,
+}
+
+// Validate checks that the key is structurally and cryptographically
+// sound, and that its fields are consistent with each other. While
+// `jwk.Parse()` and its variants only check that the JSON representation
+// can be decoded, Validate rejects keys such as:
+//
+// * RSA keys with a modulus smaller than WithMinRSABits (default: 2048)
+// * RSA private keys without the CRT parameters ("p", "q", "dp", "dq" and "qi"), or with inconsistent parameters
+// * EC keys whose point is not on the curve given in "crv", or whose private key does not match the point
+// * OKP keys with "x" or "d" of the wrong length, or whose private key does not match "x"
+// * Symmetric keys whose length does not fit "alg"
+// * Keys whose "use" conflicts with "key_ops", or with duplicate "key_ops"
+// * Keys whose "alg" does not fit their type, curve or "use"
+// * Keys whose "x5c" leaf certificate contains a different public key
+//
+// If the key is invalid, an error that matches ErrInvalidKey is returned.
+// Use `errors.As()` with ValidationError to find out which field is invalid.
+func Validate(key Key, options ...ValidateOption) error {
+	minBits := minRSABits
+	for _, option := range options {
+		switch option.Ident() {
+		case identMinRSABits{}:
+			minBits = option.Value().(int)
+		}
+	}
+
+	var err error
+	switch key := key.(type) {
+	case RSAPrivateKey:
+		err = validateRSAPrivateKey(key, minBits)
+	case RSAPublicKey:
+		err = validateRSAPublicKey(key.N(), key.E(), minBits)
+	case ECDSAPrivateKey:
+		err = validateECDSAKey(key.Crv(), key.X(), key.Y(), key.D())
+	case ECDSAPublicKey:
+		err = validateECDSAKey(key.Crv(), key.X(), key.Y(), nil)
+	case OKPPrivateKey:
+		err = validateOKPKey(key.Crv(), key.X(), key.D())
+	case OKPPublicKey:
+		err = validateOKPKey(key.Crv(), key.X(), nil)
+	case SymmetricKey:
+		if len(key.Octets()) == 0 {
+			err = &ValidationError{Field: SymmetricOctetsKey, Reason: `key must not be empty`}
+		}
+	default:
+		return errors.Errorf(`invalid key type %T`, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := validateKeyOps(key); err != nil {
+		return err
+	}
+
+	if err := validateAlgorithm(key); err != nil {
+		return err
+	}
+
+	return validateCertificateChain(key)
+}
+
+func validateRSAPublicKey(n, e []byte, minBits int) error {
+	if len(n) == 0 {
+		return &ValidationError{Field: RSANKey, Reason: `required field is missing`}
+	}
+	if len(e) == 0 {
+		return &ValidationError{Field: RSAEKey, Reason: `required field is missing`}
+	}
+
+	if bits := new(big.Int).SetBytes(n).BitLen(); bits < minBits {
+		return &ValidationError{Field: RSANKey, Reason: fmt.Sprintf(`modulus must be at least %d bits, got %d`, minBits, bits)}
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if exponent.Cmp(big.NewInt(3)) < 0 || exponent.Bit(0) == 0 || exponent.BitLen() > 31 {
+		return &ValidationError{Field: RSAEKey, Reason: `invalid public exponent`}
+	}
+	return nil
+}
+
+func validateRSAPrivateKey(key RSAPrivateKey, minBits int) error {
+	if err := validateRSAPublicKey(key.N(), key.E(), minBits); err != nil {
+		return err
+	}
+
+	fields := []struct {
+		name  string
+		value []byte
+	}{
+		{RSADKey, key.D()},
+		{RSAPKey, key.P()},
+		{RSAQKey, key.Q()},
+		{RSADPKey, key.DP()},
+		{RSADQKey, key.DQ()},
+		{RSAQIKey, key.QI()},
+	}
+	for _, field := range fields {
+		if len(field.value) == 0 {
+			return &ValidationError{Field: field.name, Reason: `required field is missing`}
+		}
+	}
+
+	d := new(big.Int).SetBytes(key.D())
+	p := new(big.Int).SetBytes(key.P())
+	q := new(big.Int).SetBytes(key.Q())
+	privkey := rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: new(big.Int).SetBytes(key.N()),
+			E: int(new(big.Int).SetBytes(key.E()).Int64()),
+		},
+		D:      d,
+		Primes: []*big.Int{p, q},
+	}
+	if err := privkey.Validate(); err != nil {
+		return &ValidationError{Field: RSADKey, Reason: err.Error()}
+	}
+
+	one := big.NewInt(1)
+	expected := []struct {
+		name  string
+		value *big.Int
+	}{
+		{RSADPKey, new(big.Int).Mod(d, new(big.Int).Sub(p, one))},
+		{RSADQKey, new(big.Int).Mod(d, new(big.Int).Sub(q, one))},
+		{RSAQIKey, new(big.Int).ModInverse(q, p)},
+	}
+	for i, v := range expected {
+		actual := new(big.Int).SetBytes(fields[i+3].value)
+		if v.value == nil || v.value.Cmp(actual) != 0 {
+			return &ValidationError{Field: v.name, Reason: `value does not match the private key`}
+		}
+	}
+	return nil
+}
+
+func validateECDSAKey(crv jwa.EllipticCurveAlgorithm, xbuf, ybuf, dbuf []byte) error {
+	curve, err := ellipticCurve(crv)
+	if err != nil {
+		return &ValidationError{Field: ECDSACrvKey, Reason: err.Error()}
+	}
+
+	params := curve.Params()
+	size := (params.BitSize + 7) / 8
+	if len(xbuf) != size {
+		return &ValidationError{Field: ECDSAXKey, Reason: fmt.Sprintf(`expected %d bytes, got %d`, size, len(xbuf))}
+	}
+	if len(ybuf) != size {
+		return &ValidationError{Field: ECDSAYKey, Reason: fmt.Sprintf(`expected %d bytes, got %d`, size, len(ybuf))}
+	}
+
+	x := new(big.Int).SetBytes(xbuf)
+	y := new(big.Int).SetBytes(ybuf)
+	if x.Cmp(params.P) >= 0 || y.Cmp(params.P) >= 0 || !curve.IsOnCurve(x, y) {
+		return &ValidationError{Field: ECDSAXKey, Reason: fmt.Sprintf(`point is not on curve %s`, crv)}
+	}
+
+	if dbuf == nil {
+		return nil
+	}
+
+	if crv == jwa.Secp256k1 {
+		return &ValidationError{Field: ECDSADKey, Reason: `secp256k1 keys are only supported for verifying signatures`}
+	}
+
+	if len(dbuf) != size {
+		return &ValidationError{Field: ECDSADKey, Reason: fmt.Sprintf(`expected %d bytes, got %d`, size, len(dbuf))}
+	}
+	d := new(big.Int).SetBytes(dbuf)
+	if d.Sign() == 0 || d.Cmp(params.N) >= 0 {
+		return &ValidationError{Field: ECDSADKey, Reason: `private key is out of range`}
+	}
+
+	px, py := curve.ScalarBaseMult(dbuf)
+	if px.Cmp(x) != 0 || py.Cmp(y) != 0 {
+		return &ValidationError{Field: ECDSADKey, Reason: `private key does not match the public key`}
+	}
+	return nil
+}
+
+func validateOKPKey(crv jwa.EllipticCurveAlgorithm, x, d []byte) error {
+	var size int
+	switch crv {
+	case jwa.Ed25519:
+		size = ed25519.PublicKeySize
+	case jwa.X25519:
+		size = x25519.PublicKeySize
+	default:
+		return &ValidationError{Field: OKPCrvKey, Reason: fmt.Sprintf(`invalid curve algorithm %s`, crv)}
+	}
+
+	if len(x) != size {
+		return &ValidationError{Field: OKPXKey, Reason: fmt.Sprintf(`expected %d bytes, got %d`, size, len(x))}
+	}
+
+	if d == nil {
+		return nil
+	}
+
+	if len(d) != size {
+		return &ValidationError{Field: OKPDKey, Reason: fmt.Sprintf(`expected %d bytes, got %d`, size, len(d))}
+	}
+	if _, err := buildOKPPrivateKey(crv, x, d); err != nil {
+		return &ValidationError{Field: OKPDKey, Reason: `private key does not match the public key`}
+	}
+	return nil
+}
+
+func validateKeyOps(key Key) error {
+	use := KeyUsageType(key.KeyUsage())
+	seen := make(map[KeyOperation]struct{})
+	for _, op := range key.KeyOps() {
+		if _, ok := seen[op]; ok {
+			return &ValidationError{Field: KeyOpsKey, Reason: fmt.Sprintf(`duplicate key operation %s`, op)}
+		}
+		seen[op] = struct{}{}
+
+		if use != ForSignature && use != ForEncryption {
+			continue
+		}
+		if keyOpsUsage[op] != use {
+			return &ValidationError{Field: KeyOpsKey, Reason: fmt.Sprintf(`key operation %s conflicts with "use" value %s`, op, use)}
+		}
+	}
+	return nil
+}
+
+func validateAlgorithm(key Key) error {
+	alg := key.Algorithm()
+	if alg == "" {
+		return nil
+	}
+
+	constraints, ok := algorithmConstraints[alg]
+	if !ok {
+		return &ValidationError{Field: AlgorithmKey, Reason: fmt.Sprintf(`unsupported algorithm %s`, alg)}
+	}
+
+	var constraint *algorithmConstraint
+	for i, c := range constraints {
+		if c.kty == key.KeyType() {
+			constraint = &constraints[i]
+			break
+		}
+	}
+	if constraint == nil {
+		return &ValidationError{Field: AlgorithmKey, Reason: fmt.Sprintf(`algorithm %s cannot be used with key type %s`, alg, key.KeyType())}
+	}
+
+	if use := KeyUsageType(key.KeyUsage()); (use == ForSignature || use == ForEncryption) && use != constraint.usage {
+		return &ValidationError{Field: AlgorithmKey, Reason: fmt.Sprintf(`algorithm %s conflicts with "use" value %s`, alg, use)}
+	}
+
+	if len(constraint.curves) > 0 {
+		var crv jwa.EllipticCurveAlgorithm
+		switch key := key.(type) {
+		case ECDSAPrivateKey:
+			crv = key.Crv()
+		case ECDSAPublicKey:
+			crv = key.Crv()
+		case OKPPrivateKey:
+			crv = key.Crv()
+		case OKPPublicKey:
+			crv = key.Crv()
+		}
+
+		var found bool
+		for _, c := range constraint.curves {
+			if c == crv {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &ValidationError{Field: AlgorithmKey, Reason: fmt.Sprintf(`algorithm %s cannot be used with curve %s`, alg, crv)}
+		}
+	}
+
+	if key, ok := key.(SymmetricKey); ok {
+		length := len(key.Octets())
+		if constraint.octets > 0 && length != constraint.octets {
+			return &ValidationError{Field: SymmetricOctetsKey, Reason: fmt.Sprintf(`algorithm %s requires a %d byte key, got %d`, alg, constraint.octets, length)}
+		}
+		if constraint.minOctets > 0 && length < constraint.minOctets {
+			return &ValidationError{Field: SymmetricOctetsKey, Reason: fmt.Sprintf(`algorithm %s requires a key of at least %d bytes, got %d`, alg, constraint.minOctets, length)}
+		}
+	}
+	return nil
+}
+
+func validateCertificateChain(key Key) error {
+	certs := key.X509CertChain()
+	if len(certs) == 0 {
+		return nil
+	}
+
+	leaf, err := New(certs[0].PublicKey)
+	if err != nil {
+		return &ValidationError{Field: X509CertChainKey, Reason: fmt.Sprintf(`failed to create key from leaf certificate: %s`, err)}
+	}
+
+	if leaf.KeyType() == key.KeyType() {
+		expected, err := leaf.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return &ValidationError{Field: X509CertChainKey, Reason: fmt.Sprintf(`failed to compute thumbprint: %s`, err)}
+		}
+		actual, err := key.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return errors.Wrap(err, `failed to compute thumbprint`)
+		}
+		if bytes.Equal(expected, actual) {
+			return nil
+		}
+	}
+	return &ValidationError{Field: X509CertChainKey, Reason: `public key of the leaf certificate does not match the key`}
+}
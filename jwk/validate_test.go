@@ -0,0 +1,284 @@
+package jwk_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	generate := func(t *testing.T, kty jwa.KeyType, options ...jwk.Option) jwk.Key {
+		t.Helper()
+		key, err := jwk.Generate(kty, options...)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			t.FailNow()
+		}
+		return key
+	}
+
+	// modify creates a copy of the key with its JSON representation
+	// modified by the given function
+	modify := func(t *testing.T, key jwk.Key, fn func(map[string]interface{})) jwk.Key {
+		t.Helper()
+		buf, err := json.Marshal(key)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			t.FailNow()
+		}
+		var fields map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(buf, &fields), `json.Unmarshal should succeed`) {
+			t.FailNow()
+		}
+		fn(fields)
+		buf, err = json.Marshal(fields)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			t.FailNow()
+		}
+		modified, err := jwk.ParseKey(buf)
+		if !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+			t.FailNow()
+		}
+		return modified
+	}
+
+	certificateFor := func(t *testing.T, key jwk.Key) string {
+		t.Helper()
+		var raw ecdsa.PrivateKey
+		if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+			t.FailNow()
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "jwx"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &raw.PublicKey, &raw)
+		if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+			t.FailNow()
+		}
+		return base64.EncodeToString(der)
+	}
+
+	rsakey := generate(t, jwa.RSA, jwk.WithKeyAlgorithm(jwa.RS256), jwk.WithKeyUsage(jwk.ForSignature))
+	eckey := generate(t, jwa.EC, jwk.WithKeyAlgorithm(jwa.ES256), jwk.WithKeyOps(jwk.KeyOpSign, jwk.KeyOpVerify))
+	eckey2 := generate(t, jwa.EC)
+	edkey := generate(t, jwa.OKP, jwk.WithKeyAlgorithm(jwa.EdDSA))
+	xkey := generate(t, jwa.OKP, jwk.WithCurve(jwa.X25519), jwk.WithKeyAlgorithm(jwa.ECDH_ES))
+	octkey := generate(t, jwa.OctetSeq, jwk.WithKeyAlgorithm(jwa.HS256))
+
+	rsapub, err := jwk.PublicKey(rsakey)
+	if !assert.NoError(t, err, `jwk.PublicKey should succeed`) {
+		return
+	}
+	ecpub, err := jwk.PublicKey(eckey)
+	if !assert.NoError(t, err, `jwk.PublicKey should succeed`) {
+		return
+	}
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if !assert.NoError(t, err, `rsa.GenerateKey should succeed`) {
+		return
+	}
+	smallkey, err := jwk.New(small)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+
+	t.Run("valid keys", func(t *testing.T) {
+		t.Parallel()
+		cert := certificateFor(t, eckey2)
+		withCert := modify(t, eckey2, func(m map[string]interface{}) {
+			m[jwk.X509CertChainKey] = []string{cert}
+		})
+		for _, key := range []jwk.Key{rsakey, rsapub, eckey, ecpub, edkey, xkey, octkey, withCert} {
+			if !assert.NoError(t, jwk.Validate(key), `jwk.Validate should succeed for %s key`, key.KeyType()) {
+				return
+			}
+		}
+		if !assert.NoError(t, jwk.Validate(smallkey, jwk.WithMinRSABits(1024)), `jwk.Validate should succeed with WithMinRSABits`) {
+			return
+		}
+	})
+	t.Run("invalid keys", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name  string
+			Key   jwk.Key
+			Field string
+		}{
+			{
+				Name:  "RSA modulus too small",
+				Key:   smallkey,
+				Field: jwk.RSANKey,
+			},
+			{
+				Name: "RSA missing CRT parameter",
+				Key: modify(t, rsakey, func(m map[string]interface{}) {
+					delete(m, jwk.RSADPKey)
+				}),
+				Field: jwk.RSADPKey,
+			},
+			{
+				Name: "RSA inconsistent CRT parameter",
+				Key: modify(t, rsakey, func(m map[string]interface{}) {
+					m[jwk.RSAQIKey] = m[jwk.RSADPKey]
+				}),
+				Field: jwk.RSAQIKey,
+			},
+			{
+				Name: "EC point not on curve",
+				Key: modify(t, ecpub, func(m map[string]interface{}) {
+					m[jwk.ECDSAYKey] = m[jwk.ECDSAXKey]
+				}),
+				Field: jwk.ECDSAXKey,
+			},
+			{
+				Name: "EC private key does not match",
+				Key: modify(t, eckey, func(m map[string]interface{}) {
+					m[jwk.ECDSADKey] = base64.EncodeToString(eckey2.(jwk.ECDSAPrivateKey).D())
+				}),
+				Field: jwk.ECDSADKey,
+			},
+			{
+				Name: "OKP wrong length",
+				Key: modify(t, edkey, func(m map[string]interface{}) {
+					delete(m, jwk.OKPDKey)
+					m[jwk.OKPXKey] = base64.EncodeToString(make([]byte, 31))
+				}),
+				Field: jwk.OKPXKey,
+			},
+			{
+				Name: "oct too short for alg",
+				Key: modify(t, octkey, func(m map[string]interface{}) {
+					m[jwk.SymmetricOctetsKey] = base64.EncodeToString(make([]byte, 16))
+				}),
+				Field: jwk.SymmetricOctetsKey,
+			},
+			{
+				Name: "oct wrong length for alg",
+				Key: modify(t, octkey, func(m map[string]interface{}) {
+					m[jwk.AlgorithmKey] = jwa.A128KW.String()
+				}),
+				Field: jwk.SymmetricOctetsKey,
+			},
+			{
+				Name: "use conflicts with key_ops",
+				Key: modify(t, eckey, func(m map[string]interface{}) {
+					m[jwk.KeyUsageKey] = jwk.ForEncryption.String()
+					delete(m, jwk.AlgorithmKey)
+				}),
+				Field: jwk.KeyOpsKey,
+			},
+			{
+				Name: "duplicate key_ops",
+				Key: modify(t, eckey, func(m map[string]interface{}) {
+					m[jwk.KeyOpsKey] = []string{"sign", "sign"}
+				}),
+				Field: jwk.KeyOpsKey,
+			},
+			{
+				Name: "alg does not fit kty",
+				Key: modify(t, rsakey, func(m map[string]interface{}) {
+					m[jwk.AlgorithmKey] = jwa.ES256.String()
+				}),
+				Field: jwk.AlgorithmKey,
+			},
+			{
+				Name: "alg does not fit crv",
+				Key: modify(t, eckey, func(m map[string]interface{}) {
+					m[jwk.AlgorithmKey] = jwa.ES384.String()
+				}),
+				Field: jwk.AlgorithmKey,
+			},
+			{
+				Name: "alg conflicts with use",
+				Key: modify(t, rsakey, func(m map[string]interface{}) {
+					m[jwk.AlgorithmKey] = jwa.RSA_OAEP.String()
+				}),
+				Field: jwk.AlgorithmKey,
+			},
+			{
+				Name: "unsupported alg",
+				Key: modify(t, rsakey, func(m map[string]interface{}) {
+					m[jwk.AlgorithmKey] = jwa.NoSignature.String()
+				}),
+				Field: jwk.AlgorithmKey,
+			},
+			{
+				Name: "x5c does not match",
+				Key: modify(t, eckey, func(m map[string]interface{}) {
+					m[jwk.X509CertChainKey] = []string{certificateFor(t, eckey2)}
+				}),
+				Field: jwk.X509CertChainKey,
+			},
+		}
+
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				err := jwk.Validate(tc.Key)
+				if !assert.Error(t, err, `jwk.Validate should fail`) {
+					return
+				}
+				if !assert.True(t, errors.Is(err, jwk.ErrInvalidKey), `error should match ErrInvalidKey`) {
+					return
+				}
+				var verr *jwk.ValidationError
+				if !assert.True(t, errors.As(err, &verr), `error should be ValidationError`) {
+					return
+				}
+				if !assert.Equal(t, tc.Field, verr.Field, `field should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("WithValidate", func(t *testing.T) {
+		t.Parallel()
+		var set jwk.Set
+		if !assert.NoError(t, set.Add(octkey), `set.Add should succeed`) {
+			return
+		}
+		if !assert.NoError(t, set.Add(smallkey), `set.Add should succeed`) {
+			return
+		}
+		buf, err := json.Marshal(&set)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+
+		if _, err := jwk.ParseBytes(buf); !assert.NoError(t, err, `jwk.ParseBytes should succeed without WithValidate`) {
+			return
+		}
+		_, err = jwk.ParseBytes(buf, jwk.WithValidate(true))
+		if !assert.True(t, errors.Is(err, jwk.ErrInvalidKey), `jwk.ParseBytes should fail with WithValidate`) {
+			return
+		}
+		if _, err := jwk.ParseBytes(buf, jwk.WithValidate(true), jwk.WithMinRSABits(1024)); !assert.NoError(t, err, `jwk.ParseBytes should succeed with WithMinRSABits`) {
+			return
+		}
+
+		keybuf, err := json.Marshal(smallkey)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		_, err = jwk.ParseKey(keybuf, jwk.WithValidate(true))
+		if !assert.True(t, errors.Is(err, jwk.ErrInvalidKey), `jwk.ParseKey should fail with WithValidate`) {
+			return
+		}
+	})
+}
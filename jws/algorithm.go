@@ -0,0 +1,88 @@
+package jws
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// noneVerifier "verifies" unsecured JWS messages (RFC 7518 Section 3.6),
+// which must have an empty signature. It is only used when the user
+// explicitly allowed the "none" algorithm
+type noneVerifier struct{}
+
+func (noneVerifier) Verify(_, signature []byte, _ interface{}) error {
+	if len(signature) > 0 {
+		return errors.New(`signature must be empty for algorithm "none"`)
+	}
+	return nil
+}
+
+// keyTypeForAlgorithm returns the type of key that can be used to
+// create and verify signatures using the given algorithm. The key type
+// is not known for algorithms that were registered by the user
+func keyTypeForAlgorithm(alg jwa.SignatureAlgorithm) (jwa.KeyType, bool) {
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		return jwa.RSA, true
+	case jwa.ES256, jwa.ES384, jwa.ES512, jwa.ES256K:
+		return jwa.EC, true
+	case jwa.HS256, jwa.HS384, jwa.HS512:
+		return jwa.OctetSeq, true
+	case jwa.EdDSA:
+		return jwa.OKP, true
+	default:
+		return jwa.InvalidKeyType, false
+	}
+}
+
+// checkKeyAlgorithm makes sure that the jwk.Key can be used with
+// the given algorithm: the key type must match the algorithm, and
+// if the key declares an algorithm ("alg"), it must be the same
+func checkKeyAlgorithm(alg jwa.SignatureAlgorithm, key jwk.Key) error {
+	if alg == "" {
+		return errors.New(`signature algorithm was not specified`)
+	}
+
+	if kty, ok := keyTypeForAlgorithm(alg); ok && key.KeyType() != kty {
+		return errors.Errorf(`key type %s can not be used with algorithm %s`, key.KeyType(), alg)
+	}
+
+	if declared := key.Algorithm(); declared != "" && declared != alg.String() {
+		return errors.Errorf(`algorithm %s does not match the algorithm declared by the key (%s)`, alg, declared)
+	}
+	return nil
+}
+
+// checkAllowedAlgorithm makes sure that alg is one of the allowed
+// algorithms. The "none" algorithm must always be explicitly allowed
+func checkAllowedAlgorithm(alg jwa.SignatureAlgorithm, allowed []jwa.SignatureAlgorithm) error {
+	if len(allowed) == 0 {
+		if alg == jwa.NoSignature {
+			return errors.Errorf(`algorithm %s must be explicitly allowed using jws.WithAllowedAlgorithms()`, alg)
+		}
+		return nil
+	}
+
+	for _, v := range allowed {
+		if v == alg {
+			return nil
+		}
+	}
+	return errors.Errorf(`algorithm %s is not allowed`, alg)
+}
+
+// headerAlgorithm returns the "alg" header of the signature. It is
+// usually stored in the protected headers, but it may be found in the
+// unprotected headers of JSON serialized messages
+func headerAlgorithm(protected, public Headers) jwa.SignatureAlgorithm {
+	if protected != nil {
+		if alg := protected.Algorithm(); alg != "" {
+			return alg
+		}
+	}
+	if public != nil {
+		return public.Algorithm()
+	}
+	return ""
+}
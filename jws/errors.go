@@ -0,0 +1,13 @@
+package jws
+
+import "github.com/pkg/errors"
+
+// ErrSignatureInvalid is matched by `errors.Is()` when a JWS message
+// could not be verified, i.e. none of its signatures (or, when the
+// WithRequireAllSignatures option is used, not all of them) could be
+// verified using the given keys.
+//
+// Errors caused by malformed messages or by the cancellation of the
+// context do not match ErrSignatureInvalid. If no key matching the
+// message could be found, the error matches `jwk.ErrKeyNotFound` instead.
+var ErrSignatureInvalid = errors.New(`invalid signature`)
@@ -2,9 +2,11 @@ package jws
 
 import (
 	"context"
+	"sync"
 
 	"github.com/lestrrat-go/iter/mapiter"
 	"github.com/lestrrat-go/jwx/internal/iter"
+	"github.com/pkg/errors"
 )
 
 // Iterate returns a channel that successively returns all the
@@ -22,3 +24,172 @@ func (h *stdHeaders) Walk(ctx context.Context, visitor Visitor) error {
 func (h *stdHeaders) AsMap(ctx context.Context) (map[string]interface{}, error) {
 	return iter.AsMap(ctx, h)
 }
+
+// cloneHeaders creates a copy of h, so that headers passed by the user
+// are not modified while signing
+func cloneHeaders(ctx context.Context, h Headers) (Headers, error) {
+	dst := NewHeaders()
+	for iter := h.Iterate(ctx); iter.Next(ctx); {
+		pair := iter.Pair()
+		if err := dst.Set(pair.Key.(string), pair.Value); err != nil {
+			return nil, errors.Wrapf(err, `failed to set header %q`, pair.Key)
+		}
+	}
+	return dst, nil
+}
+
+// B64Key is the name of the header parameter defined in RFC 7797, which
+// specifies if the payload is base64url encoded. It is not part of
+// the standard header parameters, so it is stored as a private parameter.
+const B64Key = "b64"
+
+// isBase64Encoded returns false if the given headers specify that the
+// payload is not base64url encoded (i.e. "b64": false, RFC 7797)
+func isBase64Encoded(h Headers) (bool, error) {
+	if h == nil {
+		return true, nil
+	}
+
+	v, ok := h.Get(B64Key)
+	if !ok {
+		return true, nil
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.Errorf(`invalid value for %q header: expected bool, got %T`, B64Key, v)
+	}
+	return b, nil
+}
+
+// isCritical returns true if the name is listed in the "crit" header
+func isCritical(h Headers, name string) bool {
+	if h == nil {
+		return false
+	}
+
+	for _, v := range h.Critical() {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addCritical adds the name to the "crit" header, if it's not already there
+func addCritical(h Headers, name string) error {
+	if isCritical(h, name) {
+		return nil
+	}
+
+	crit := h.Critical()
+	list := make([]string, 0, len(crit)+1)
+	list = append(list, crit...)
+	list = append(list, name)
+	return h.Set(CriticalKey, list)
+}
+
+// signatureIsBase64Encoded checks the "b64" header of the signature, and
+// reports whether the payload is expected to be base64url encoded. Per
+// RFC 7797, "b64" must be in the protected headers, and must be listed
+// in the "crit" header
+func signatureIsBase64Encoded(protected, public Headers) (bool, error) {
+	if public != nil {
+		if _, ok := public.Get(B64Key); ok {
+			return false, errors.Errorf(`%q header must be in the protected headers`, B64Key)
+		}
+	}
+
+	b64, err := isBase64Encoded(protected)
+	if err != nil {
+		return false, err
+	}
+
+	if !b64 && !isCritical(protected, B64Key) {
+		return false, errors.Errorf(`%q header must be listed in the "crit" header`, B64Key)
+	}
+	return b64, nil
+}
+
+var muCriticalExtensions sync.RWMutex
+var criticalExtensions = map[string]struct{}{
+	B64Key: {},
+}
+
+// RegisterCriticalExtension registers header parameter names that the
+// application understands and processes. By default, `jws.Verify` rejects
+// messages that list header parameters other than "b64" in the "crit"
+// header. Once registered, these names are accepted globally.
+//
+// If you only need to accept extensions for a particular call,
+// use the WithCriticalExtensions option instead.
+func RegisterCriticalExtension(names ...string) {
+	muCriticalExtensions.Lock()
+	for _, name := range names {
+		criticalExtensions[name] = struct{}{}
+	}
+	muCriticalExtensions.Unlock()
+}
+
+func isStandardHeader(name string) bool {
+	switch name {
+	case AlgorithmKey, ContentTypeKey, CriticalKey, JWKKey, JWKSetURLKey, KeyIDKey, TypeKey,
+		X509CertChainKey, X509CertThumbprintKey, X509CertThumbprintS256Key, X509URLKey:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnderstoodExtension(name string, extra []string) bool {
+	for _, v := range extra {
+		if v == name {
+			return true
+		}
+	}
+
+	muCriticalExtensions.RLock()
+	_, ok := criticalExtensions[name]
+	muCriticalExtensions.RUnlock()
+	return ok
+}
+
+// checkCritical validates the "crit" header as described in
+// RFC 7515 Section 4.1.11: it must only appear in the protected headers,
+// must not be empty, must not list standard header parameters, and each
+// of the listed names must be present in the protected headers and be
+// understood by us (i.e. either built-in, registered via
+// RegisterCriticalExtension, or passed in `extra`)
+func checkCritical(protected, public Headers, extra []string) error {
+	if public != nil && public.Critical() != nil {
+		return errors.Errorf(`%q header must be in the protected headers`, CriticalKey)
+	}
+
+	if protected == nil {
+		return nil
+	}
+
+	crit := protected.Critical()
+	if crit == nil {
+		return nil
+	}
+
+	if len(crit) == 0 {
+		return errors.Errorf(`%q header must not be empty`, CriticalKey)
+	}
+
+	for _, name := range crit {
+		if isStandardHeader(name) {
+			return errors.Errorf(`%q header must not contain standard header %q`, CriticalKey, name)
+		}
+
+		if _, ok := protected.Get(name); !ok {
+			return errors.Errorf(`critical header %q is not present in the protected headers`, name)
+		}
+
+		if !isUnderstoodExtension(name, extra) {
+			return errors.Errorf(`critical header %q is not supported`, name)
+		}
+	}
+	return nil
+}
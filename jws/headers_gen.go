@@ -360,7 +360,7 @@ func (h *stdHeaders) UnmarshalJSON(buf []byte) error {
 		if err != nil {
 			return errors.Wrap(err, `failed to parse jwk field`)
 		}
-		h.jwk = set.Keys[0]
+		h.jwk = set.Keys()[0]
 	}
 	h.algorithm = proxy.Xalgorithm
 	h.contentType = proxy.XcontentType
@@ -17,7 +17,8 @@ type PayloadSigner interface {
 
 // Message represents a full JWS encoded message. Flattened serialization
 // is not supported as a struct, but rather it's represented as a
-// Message struct with only one `signature` element.
+// Message struct with only one `signature` element. When marshaled
+// into JSON, a Message always uses the general JSON serialization format.
 //
 // Do not expect to use the Message object to verify or construct a
 // signed payload with. You should only use this when you want to actually
@@ -33,6 +34,34 @@ type Signature struct {
 	headers   Headers // Unprotected Headers
 	protected Headers // Protected Headers
 	signature []byte  // Signature
+
+	// encodedProtected holds the protected headers as they appeared in
+	// the original message, so that the signature can be verified against
+	// the exact bytes that were signed
+	encodedProtected string
+}
+
+// VerifyResult describes the outcome of a successful verification. Use the
+// WithVerifyResult option to obtain it from `jws.Verify()` and its variants.
+type VerifyResult struct {
+	payload    []byte
+	signatures []*VerifiedSignature
+
+	// count holds the number of signatures in the message
+	count int
+
+	// rejected holds the reasons why signatures were not verified,
+	// keyed by their position in the message
+	rejected map[int]error
+}
+
+// VerifiedSignature describes a signature in a JWS message that was
+// successfully verified, along with the key and algorithm that verified it.
+type VerifiedSignature struct {
+	index     int
+	signature *Signature
+	algorithm jwa.SignatureAlgorithm
+	key       jwk.Key
 }
 
 // JWKAcceptor decides which keys can be accepted
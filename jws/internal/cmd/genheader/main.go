@@ -347,7 +347,7 @@ func generateHeaders() error {
 	fmt.Fprintf(&buf, "\n if err != nil {")
 	fmt.Fprintf(&buf, "\nreturn errors.Wrap(err, `failed to parse jwk field`)")
 	fmt.Fprintf(&buf, "\n}")
-	fmt.Fprintf(&buf, "\nh.jwk = set.Keys[0]")
+	fmt.Fprintf(&buf, "\nh.jwk = set.Keys()[0]")
 	fmt.Fprintf(&buf, "\n}")
 
 	for _, f := range fields {
@@ -50,6 +50,44 @@ func (s *payloadSigner) Sign(payload []byte) ([]byte, error) {
 	return s.signer.Sign(payload, s.key)
 }
 
+func (s *payloadSigner) SignContext(ctx context.Context, payload []byte) ([]byte, error) {
+	return signWithContext(ctx, s.signer, payload, s.key)
+}
+
+// contextPayloadSigner is implemented by PayloadSigners that can take
+// a context.Context
+type contextPayloadSigner interface {
+	SignContext(context.Context, []byte) ([]byte, error)
+}
+
+// signWithContext checks the context for cancellation, and then signs
+// the payload, passing the context to the signer if it is a
+// sign.ContextSigner
+func signWithContext(ctx context.Context, signer sign.Signer, payload []byte, key interface{}) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `context error while signing payload`)
+	}
+
+	if v, ok := signer.(sign.ContextSigner); ok {
+		return v.SignContext(ctx, payload, key)
+	}
+	return signer.Sign(payload, key)
+}
+
+// verifyWithContext checks the context for cancellation, and then
+// verifies the signature, passing the context to the verifier if it
+// is a verify.ContextVerifier
+func verifyWithContext(ctx context.Context, verifier verify.Verifier, payload, signature []byte, key interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, `context error while verifying payload`)
+	}
+
+	if v, ok := verifier.(verify.ContextVerifier); ok {
+		return v.VerifyContext(ctx, payload, signature, key)
+	}
+	return verifier.Verify(payload, signature, key)
+}
+
 func (s *payloadSigner) Algorithm() jwa.SignatureAlgorithm {
 	return s.signer.Algorithm()
 }
@@ -73,21 +111,49 @@ func (s *payloadSigner) PublicHeader() Headers {
 // If the key is a jwk.Key and the key contains a key ID (`kid` field),
 // then it is added to the protected header generated by the signature
 //
+// For RSA, ECDSA and EdDSA algorithms, the key may also be a crypto.Signer
+// (e.g. a key stored in an HSM or a KMS). ECDSA signatures produced by
+// the crypto.Signer are converted to the R||S form required by JWS.
+//
 // The algorithm specified in the `alg` parameter must be able to support
 // the type of key you provided, otherwise an error is returned.
 //
 // If you would like to pass custom headers, use the WithHeaders option.
+//
+// If you would like to create a JWS message with a detached payload,
+// pass a nil payload and use the WithDetachedPayload option.
+//
+// If the headers contain "b64": false (RFC 7797), the payload is signed
+// as is, without being base64url encoded first. In this case "b64" is
+// automatically added to the "crit" header.
 func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...Option) ([]byte, error) {
 	var hdrs Headers
+	var detached bool
+	ctx := context.Background()
 	for _, o := range options {
 		switch o.Ident() {
+		case identContext{}:
+			ctx = o.Value().(context.Context)
 		case identHeaders{}:
 			hdrs = o.Value().(Headers)
+		case identDetachedPayload{}:
+			if payload != nil {
+				return nil, errors.New(`payload must be nil when jws.WithDetachedPayload() is specified`)
+			}
+			payload = o.Value().([]byte)
+			detached = true
 		}
 	}
 
 	if hdrs == nil {
 		hdrs = NewHeaders()
+	} else {
+		// Work on a copy, as "alg", "kid" and "crit" are set below
+		cloned, err := cloneHeaders(ctx, hdrs)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to copy headers`)
+		}
+		hdrs = cloned
 	}
 
 	// If the key is a jwk.Key instance, obtain the raw key
@@ -115,6 +181,22 @@ func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options .
 		return nil, errors.Wrap(err, `failed to set header`)
 	}
 
+	b64, err := isBase64Encoded(hdrs)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to check "b64" header`)
+	}
+
+	if !b64 {
+		// RFC 7797 requires that "b64" be understood by the recipient
+		if err := addCritical(hdrs, B64Key); err != nil {
+			return nil, errors.Wrap(err, `failed to set "crit" header`)
+		}
+
+		if !detached && bytes.IndexByte(payload, '.') >= 0 {
+			return nil, errors.New(`unencoded payload in compact serialization must not contain '.'`)
+		}
+	}
+
 	hdrbuf, err := json.Marshal(hdrs)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to marshal headers`)
@@ -125,13 +207,21 @@ func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options .
 
 	buf.WriteString(base64.EncodeToString(hdrbuf))
 	buf.WriteByte('.')
-	buf.WriteString(base64.EncodeToString(payload))
+	if b64 {
+		buf.WriteString(base64.EncodeToString(payload))
+	} else {
+		buf.Write(payload)
+	}
 
-	signature, err := signer.Sign(buf.Bytes(), key)
+	signature, err := signWithContext(ctx, signer, buf.Bytes(), key)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to sign payload`)
 	}
 
+	if detached {
+		// Remove the payload, leaving an empty segment
+		buf.Truncate(bytes.IndexByte(buf.Bytes(), '.') + 1)
+	}
 	buf.WriteByte('.')
 	buf.WriteString(base64.EncodeToString(signature))
 
@@ -173,12 +263,33 @@ func SignLiteral(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, he
 // SignMulti accepts multiple signers via the options parameter,
 // and creates a JWS in JSON serialization format that contains
 // signatures from applying aforementioned signers.
+//
+// By default the general JSON serialization format is used. Use
+// `jws.WithSerialization()` to specify the flattened JSON or the
+// compact serialization format. Both of these formats require
+// exactly one signer.
+//
+// If you would like to create a JWS message with a detached payload,
+// pass a nil payload and use the WithDetachedPayload option.
 func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 	var signers []PayloadSigner
+	var detached bool
+	ctx := context.Background()
+	serialization := SerializationGeneralJSON
 	for _, o := range options {
 		switch o.Ident() {
+		case identContext{}:
+			ctx = o.Value().(context.Context)
 		case identPayloadSigner{}:
 			signers = append(signers, o.Value().(PayloadSigner))
+		case identSerialization{}:
+			serialization = o.Value().(Serialization)
+		case identDetachedPayload{}:
+			if payload != nil {
+				return nil, errors.New(`payload must be nil when jws.WithDetachedPayload() is specified`)
+			}
+			payload = o.Value().([]byte)
+			detached = true
 		}
 	}
 
@@ -189,20 +300,49 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 	var result Message
 
 	result.payload = payload
-	encodedPayload := base64.EncodeToString(payload)
 
+	var encodePayload bool
+	var encodedPayload []byte
 	buf := pool.GetBytesBuffer()
 	defer pool.ReleaseBytesBuffer(buf)
-	for _, signer := range signers {
+	for i, signer := range signers {
 		protected := signer.ProtectedHeader()
 		if protected == nil {
 			protected = NewHeaders()
+		} else {
+			cloned, err := cloneHeaders(ctx, protected)
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to copy protected headers for signature #%d`, i+1)
+			}
+			protected = cloned
 		}
 
 		if err := protected.Set(AlgorithmKey, signer.Algorithm()); err != nil {
 			return nil, errors.Wrap(err, `failed to set header`)
 		}
 
+		b64, err := isBase64Encoded(protected)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to check "b64" header for signature #%d`, i+1)
+		}
+		if !b64 {
+			if err := addCritical(protected, B64Key); err != nil {
+				return nil, errors.Wrap(err, `failed to set "crit" header`)
+			}
+		}
+
+		// All signatures must agree on whether the payload is encoded
+		if i == 0 {
+			encodePayload = b64
+			if b64 {
+				encodedPayload = []byte(base64.EncodeToString(payload))
+			} else {
+				encodedPayload = payload
+			}
+		} else if b64 != encodePayload {
+			return nil, errors.Errorf(`"b64" header for signature #%d does not match previous signatures`, i+1)
+		}
+
 		hdrbuf, err := json.Marshal(protected)
 		if err != nil {
 			return nil, errors.Wrap(err, `failed to marshal headers`)
@@ -212,20 +352,39 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 		buf.Reset()
 		buf.WriteString(encodedHeader)
 		buf.WriteByte('.')
-		buf.WriteString(encodedPayload)
-		signature, err := signer.Sign(buf.Bytes())
+		buf.Write(encodedPayload)
+		var signature []byte
+		if v, ok := signer.(contextPayloadSigner); ok {
+			signature, err = v.SignContext(ctx, buf.Bytes())
+		} else if err = ctx.Err(); err == nil {
+			signature, err = signer.Sign(buf.Bytes())
+		}
 		if err != nil {
 			return nil, errors.Wrap(err, `failed to sign payload`)
 		}
 
 		result.signatures = append(result.signatures, &Signature{
-			headers:   signer.PublicHeader(),
-			protected: protected,
-			signature: signature,
+			headers:          signer.PublicHeader(),
+			protected:        protected,
+			signature:        signature,
+			encodedProtected: encodedHeader,
 		})
 	}
 
-	return json.Marshal(result)
+	if detached {
+		result.payload = nil
+	}
+
+	switch serialization {
+	case SerializationGeneralJSON:
+		return result.marshalGeneral()
+	case SerializationFlattenedJSON:
+		return result.marshalFlattened()
+	case SerializationCompact:
+		return result.marshalCompact()
+	default:
+		return nil, errors.Errorf(`invalid serialization format %d`, serialization)
+	}
 }
 
 // Verify checks if the given JWS message is verifiable using `alg` and `key`.
@@ -235,10 +394,131 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 // `Verifier` in `verify` subpackage, and call `Verify` method on it.
 // If you need to access signatures and JOSE headers in a JWS message,
 // use `Parse` function to get `Message` object.
-func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}) (ret []byte, err error) {
-	verifier, err := verify.New(alg)
+//
+// If the message has a detached payload, use the WithDetachedPayload option
+// to specify the payload that should be verified.
+//
+// Messages with unencoded payloads (RFC 7797) are supported, as long as
+// "b64" is specified in the protected headers and is listed in "crit".
+//
+// The key may be either a raw key or a jwk.Key. If it's a jwk.Key, its
+// key type must be suitable for `alg`, and if it declares an algorithm,
+// it must match `alg`. The "alg" header of the message must also match
+// `alg`. To restrict the algorithms that may be used, use the
+// WithAllowedAlgorithms option. Unsecured messages (i.e. "alg": "none")
+// are rejected, unless jwa.NoSignature is explicitly allowed using
+// the same option.
+//
+// Messages that list header parameters in the "crit" header that are
+// not understood are rejected. Use the WithCriticalExtensions option or
+// `jws.RegisterCriticalExtension()` to declare the extensions that your
+// application handles. In JSON messages, such a signature is treated as
+// one that does not verify, and is reported by `(*VerifyResult).Rejected()`.
+//
+// To find out which signature was verified, and the key and algorithm
+// that verified it, use the WithVerifyResult option. To require every
+// signature in a JSON message to verify, use the WithRequireAllSignatures
+// option.
+func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...Option) ([]byte, error) {
+	cfg := newVerifyConfig(options)
+	result, err := verifyMessage(buf, alg, key, cfg)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create verifier")
+		return nil, err
+	}
+	return cfg.finish(result)
+}
+
+// verifyConfig holds the options that control how messages are verified
+type verifyConfig struct {
+	ctx               context.Context
+	detached          bool
+	detachedPayload   []byte
+	extensions        []string
+	allowedAlgorithms []jwa.SignatureAlgorithm
+	requireAll        bool
+	result            *VerifyResult
+}
+
+func newVerifyConfig(options []Option) *verifyConfig {
+	cfg := verifyConfig{
+		ctx: context.Background(),
+	}
+	for _, o := range options {
+		switch o.Ident() {
+		case identContext{}:
+			cfg.ctx = o.Value().(context.Context)
+		case identDetachedPayload{}:
+			cfg.detachedPayload = o.Value().([]byte)
+			cfg.detached = true
+		case identCriticalExtensions{}:
+			cfg.extensions = append(cfg.extensions, o.Value().([]string)...)
+		case identAllowedAlgorithms{}:
+			cfg.allowedAlgorithms = append(cfg.allowedAlgorithms, o.Value().([]jwa.SignatureAlgorithm)...)
+		case identRequireAllSignatures{}:
+			cfg.requireAll = o.Value().(bool)
+		case identVerifyResult{}:
+			cfg.result = o.Value().(*VerifyResult)
+		}
+	}
+	return &cfg
+}
+
+// finish makes sure that all signatures have been verified if the
+// WithRequireAllSignatures option was specified, and populates the
+// VerifyResult specified by the WithVerifyResult option
+func (cfg *verifyConfig) finish(result *VerifyResult) ([]byte, error) {
+	if cfg.requireAll && !result.complete() {
+		return nil, errors.Wrapf(ErrSignatureInvalid, `only %d out of %d signatures could be verified`, len(result.signatures), result.count)
+	}
+
+	if cfg.result != nil {
+		*cfg.result = *result
+	}
+	return result.payload, nil
+}
+
+// verifyMessage verifies the signatures in the message using alg and key.
+// Unless all signatures are required to verify, it stops at the first
+// signature that verifies. Signatures that do not verify are not an
+// error, as long as at least one of them does.
+func verifyMessage(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, cfg *verifyConfig) (*VerifyResult, error) {
+	ctx := cfg.ctx
+	detached := cfg.detached
+	detachedPayload := cfg.detachedPayload
+	extensions := cfg.extensions
+
+	if err := checkAllowedAlgorithm(alg, cfg.allowedAlgorithms); err != nil {
+		return nil, errors.Wrap(err, `failed to verify message`)
+	}
+
+	// If the key is a jwk.Key instance, make sure that it can be
+	// used with alg, and obtain the raw key
+	var verifiedKey jwk.Key
+	if jwkKey, ok := key.(jwk.Key); ok {
+		if err := checkKeyAlgorithm(alg, jwkKey); err != nil {
+			return nil, errors.Wrap(err, `invalid key for verification`)
+		}
+
+		var rawkey interface{}
+		if err := jwkKey.Raw(&rawkey); err != nil {
+			return nil, errors.Wrap(err, `failed to get raw key from jwk.Key instance`)
+		}
+		key = rawkey
+		verifiedKey = jwkKey
+	} else if cfg.result != nil {
+		// The conversion is only needed for reporting, so errors are ignored
+		verifiedKey, _ = jwk.New(key)
+	}
+
+	var verifier verify.Verifier
+	if alg == jwa.NoSignature {
+		verifier = noneVerifier{}
+	} else {
+		v, err := verify.New(alg)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create verifier")
+		}
+		verifier = v
 	}
 
 	buf = bytes.TrimSpace(buf)
@@ -252,26 +532,79 @@ func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}) (ret []byte
 			return nil, errors.Wrap(err, `failed to unmarshal JSON message`)
 		}
 
-		payload := base64.EncodeToString(m.payload)
+		if detached {
+			if len(m.payload) > 0 {
+				return nil, errors.New(`payload must be empty when jws.WithDetachedPayload() is specified`)
+			}
+			m.payload = detachedPayload
+		}
+
+		result := VerifyResult{
+			payload: m.payload,
+			count:   len(m.signatures),
+		}
 
 		buf := pool.GetBytesBuffer()
 		defer pool.ReleaseBytesBuffer(buf)
 		for i, sig := range m.signatures {
 			buf.Reset()
-			protected, err := json.Marshal(sig.protected)
+			protected, err := sig.encodeProtected()
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to encode "protected" for signature #%d`, i+1)
+			}
+
+			// The algorithm in the header must match what the caller
+			// specified, lest we verify using an unexpected algorithm
+			if headerAlgorithm(sig.protected, sig.headers) != alg {
+				continue
+			}
+
+			// A signature with headers that we can not process is treated
+			// in the same manner as a signature that does not verify
+			if err := checkCritical(sig.protected, sig.headers, extensions); err != nil {
+				result.reject(i, errors.Wrapf(err, `invalid "crit" header for signature #%d`, i+1))
+				continue
+			}
+
+			b64, err := signatureIsBase64Encoded(sig.protected, sig.headers)
 			if err != nil {
-				return nil, errors.Wrapf(err, `failed to marshal "protected" for signature #%d`, i+1)
+				result.reject(i, errors.Wrapf(err, `invalid "b64" header for signature #%d`, i+1))
+				continue
 			}
 
-			buf.WriteString(base64.EncodeToString(protected))
+			buf.WriteString(protected)
 			buf.WriteByte('.')
-			buf.WriteString(payload)
+			if b64 {
+				buf.WriteString(base64.EncodeToString(m.payload))
+			} else {
+				buf.Write(m.payload)
+			}
 
-			if err := verifier.Verify(buf.Bytes(), sig.signature, key); err == nil {
-				return m.payload, nil
+			if err := verifyWithContext(ctx, verifier, buf.Bytes(), sig.signature, key); err == nil {
+				result.signatures = append(result.signatures, &VerifiedSignature{
+					index:     i,
+					signature: sig,
+					algorithm: alg,
+					key:       verifiedKey,
+				})
+				if !cfg.requireAll {
+					return &result, nil
+				}
+				continue
+			}
+
+			if err := ctx.Err(); err != nil {
+				return nil, errors.Wrap(err, `context error while verifying message`)
 			}
 		}
-		return nil, errors.New(`could not verify with any of the signatures`)
+
+		if len(result.signatures) == 0 {
+			if len(result.rejected) > 0 {
+				return nil, errors.Wrapf(ErrSignatureInvalid, `could not verify with any of the signatures (%d signature(s) rejected)`, len(result.rejected))
+			}
+			return nil, errors.Wrap(ErrSignatureInvalid, `could not verify with any of the signatures`)
+		}
+		return &result, nil
 	}
 
 	protected, payload, signature, err := SplitCompact(bytes.NewReader(buf))
@@ -279,6 +612,40 @@ func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}) (ret []byte
 		return nil, errors.Wrap(err, `failed extract from compact serialization format`)
 	}
 
+	decodedHeader, err := base64.Decode(protected)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode protected headers`)
+	}
+
+	var hdr stdHeaders
+	if err := json.Unmarshal(decodedHeader, &hdr); err != nil {
+		return nil, errors.Wrap(err, `failed to parse JOSE headers`)
+	}
+
+	if hdralg := hdr.Algorithm(); hdralg != alg {
+		return nil, errors.Errorf(`algorithm %s does not match the algorithm in the header (%s)`, alg, hdralg)
+	}
+
+	if err := checkCritical(&hdr, nil, extensions); err != nil {
+		return nil, errors.Wrap(err, `invalid "crit" header`)
+	}
+
+	b64, err := signatureIsBase64Encoded(&hdr, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid "b64" header`)
+	}
+
+	if detached {
+		if len(payload) > 0 {
+			return nil, errors.New(`payload must be empty when jws.WithDetachedPayload() is specified`)
+		}
+		if b64 {
+			payload = []byte(base64.EncodeToString(detachedPayload))
+		} else {
+			payload = detachedPayload
+		}
+	}
+
 	verifyBuf := pool.GetBytesBuffer()
 	defer pool.ReleaseBytesBuffer(verifyBuf)
 
@@ -290,15 +657,37 @@ func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}) (ret []byte
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to decode signature`)
 	}
-	if err := verifier.Verify(verifyBuf.Bytes(), decodedSignature, key); err != nil {
-		return nil, errors.Wrap(err, `failed to verify message`)
-	}
-
-	decodedPayload, err := base64.Decode(payload)
-	if err != nil {
-		return nil, errors.Wrap(err, `message verified, failed to decode payload`)
+	if err := verifyWithContext(ctx, verifier, verifyBuf.Bytes(), decodedSignature, key); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, errors.Wrap(ctxErr, `context error while verifying message`)
+		}
+		return nil, errors.Wrapf(ErrSignatureInvalid, `failed to verify message: %s`, err)
+	}
+
+	result := VerifyResult{
+		payload: payload,
+		count:   1,
+		signatures: []*VerifiedSignature{
+			{
+				algorithm: alg,
+				key:       verifiedKey,
+				signature: &Signature{
+					protected:        &hdr,
+					signature:        decodedSignature,
+					encodedProtected: string(protected),
+				},
+			},
+		},
+	}
+
+	if b64 {
+		decodedPayload, err := base64.Decode(payload)
+		if err != nil {
+			return nil, errors.Wrap(err, `message verified, failed to decode payload`)
+		}
+		result.payload = decodedPayload
 	}
-	return decodedPayload, nil
+	return &result, nil
 }
 
 // VerifyWithJKU wraps VerifyWithJKUAndContext using the background context.
@@ -307,24 +696,21 @@ func VerifyWithJKU(buf []byte, jwkurl string, options ...Option) ([]byte, error)
 }
 
 // VerifyWithJKUAndContext verifies the JWS message using a remote JWK
-// file represented in the url.
+// file represented in the url. The context is used both to fetch the
+// JWK file and to verify the message (see WithContext).
 func VerifyWithJKUAndContext(ctx context.Context, buf []byte, jwkurl string, options ...Option) ([]byte, error) {
 	key, err := jwk.FetchHTTPWithContext(ctx, jwkurl, options...)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to fetch jwk via HTTP`)
 	}
 
-	return VerifyWithJWKSet(buf, key, nil)
+	return VerifyWithJWKSet(buf, key, nil, append(options, WithContext(ctx))...)
 }
 
-// VerifyWithJWK verifies the JWS message using the specified JWK
-func VerifyWithJWK(buf []byte, key jwk.Key) (payload []byte, err error) {
-	var rawkey interface{}
-	if err := key.Raw(&rawkey); err != nil {
-		return nil, errors.Wrap(err, `failed to materialize jwk.Key`)
-	}
-
-	payload, err = Verify(buf, jwa.SignatureAlgorithm(key.Algorithm()), rawkey)
+// VerifyWithJWK verifies the JWS message using the specified JWK.
+// The algorithm declared by the key ("alg") is used for verification.
+func VerifyWithJWK(buf []byte, key jwk.Key, options ...Option) (payload []byte, err error) {
+	payload, err = Verify(buf, jwa.SignatureAlgorithm(key.Algorithm()), key, options...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to verify message")
 	}
@@ -335,22 +721,42 @@ func VerifyWithJWK(buf []byte, key jwk.Key) (payload []byte, err error) {
 // By default it will only pick up keys that have the "use" key
 // set to either "sig" or "enc", but you can override it by
 // providing a keyaccept function.
-func VerifyWithJWKSet(buf []byte, keyset *jwk.Set, keyaccept JWKAcceptFunc) ([]byte, error) {
+//
+// Options such as WithAllowedAlgorithms are passed to `jws.Verify()`.
+// If the WithRequireAllSignatures option is specified, every signature
+// must be verified by one of the keys in the set.
+func VerifyWithJWKSet(buf []byte, keyset *jwk.Set, keyaccept JWKAcceptFunc, options ...Option) ([]byte, error) {
 	if keyaccept == nil {
 		keyaccept = DefaultJWKAcceptor
 	}
 
-	for _, key := range keyset.Keys {
+	cfg := newVerifyConfig(options)
+
+	var result VerifyResult
+	for _, key := range keyset.Keys() {
 		if !keyaccept(key) {
 			continue
 		}
 
-		payload, err := VerifyWithJWK(buf, key)
+		r, err := verifyMessage(buf, jwa.SignatureAlgorithm(key.Algorithm()), key, cfg)
 		if err == nil {
-			return payload, nil
+			result.merge(r)
+			if !cfg.requireAll || result.complete() {
+				return cfg.finish(&result)
+			}
+			continue
+		}
+
+		if err := cfg.ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, `context error while verifying message`)
 		}
 	}
 
+	// Some, but not all of the signatures could be verified
+	if len(result.signatures) > 0 {
+		return cfg.finish(&result)
+	}
+
 	// refs #140, #141
 	//
 	// We should not be Wrap()'ing the error here, because of various
@@ -360,7 +766,7 @@ func VerifyWithJWKSet(buf []byte, keyset *jwk.Set, keyaccept JWKAcceptFunc) ([]b
 	//
 	// Here, we just return that fact, and we do not rely on the value of
 	// previous errors.
-	return nil, errors.New("failed to verify with any of the keys")
+	return nil, errors.Wrap(ErrSignatureInvalid, "failed to verify with any of the keys")
 }
 
 // Parse parses contents from the given source and creates a jws.Message
@@ -494,9 +900,17 @@ func parseCompact(rdr io.Reader) (m *Message, err error) {
 		return nil, errors.Wrap(err, `failed to parse JOSE headers`)
 	}
 
-	decodedPayload, err := base64.Decode(payload)
+	b64, err := isBase64Encoded(&hdr)
 	if err != nil {
-		return nil, errors.Wrap(err, `failed to decode payload`)
+		return nil, errors.Wrap(err, `failed to check "b64" header`)
+	}
+
+	decodedPayload := payload
+	if b64 {
+		decodedPayload, err = base64.Decode(payload)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to decode payload`)
+		}
 	}
 
 	decodedSignature, err := base64.Decode(signature)
@@ -507,8 +921,9 @@ func parseCompact(rdr io.Reader) (m *Message, err error) {
 	var msg Message
 	msg.payload = decodedPayload
 	msg.signatures = append(msg.signatures, &Signature{
-		protected: &hdr,
-		signature: decodedSignature,
+		protected:        &hdr,
+		signature:        decodedSignature,
+		encodedProtected: string(protected),
 	})
 	return &msg, nil
 }
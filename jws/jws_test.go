@@ -2,15 +2,30 @@ package jws_test
 
 import (
 	"bytes"
+	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/pem"
+	"io"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/lestrrat-go/jwx/internal/ecutil"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
@@ -20,6 +35,7 @@ import (
 	"github.com/lestrrat-go/jwx/jws"
 	"github.com/lestrrat-go/jwx/jws/sign"
 	"github.com/lestrrat-go/jwx/jws/verify"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -165,7 +181,7 @@ func TestVerifyWithJWKSet(t *testing.T) {
 		return
 	}
 
-	verified, err := jws.VerifyWithJWKSet(buf, &jwk.Set{Keys: []jwk.Key{jwkKey}}, nil)
+	verified, err := jws.VerifyWithJWKSet(buf, jwk.NewSet(jwkKey), nil)
 	if !assert.NoError(t, err, "Verify is successful") {
 		return
 	}
@@ -190,7 +206,7 @@ func TestVerifyWithJWKSet(t *testing.T) {
 	if !assert.NoError(t, err, "JWK Public key generated") {
 		return
 	}
-	_, err = jws.VerifyWithJWKSet(buf, &jwk.Set{Keys: []jwk.Key{jwkKey2}}, nil)
+	_, err = jws.VerifyWithJWKSet(buf, jwk.NewSet(jwkKey2), nil)
 	if !assert.Error(t, err, "Verify with wrong key should fail") {
 		return
 	}
@@ -345,7 +361,7 @@ func TestEncode(t *testing.T) {
 
 		keys, _ := jwk.ParseString(jwksrc)
 		var key interface{}
-		if !assert.NoError(t, keys.Keys[0].Raw(&key), `jwk.Raw should succeed`) {
+		if !assert.NoError(t, keys.Keys()[0].Raw(&key), `jwk.Raw should succeed`) {
 			return
 		}
 		var jwsCompact []byte
@@ -413,7 +429,7 @@ func TestEncode(t *testing.T) {
 			t.Fatal("Failed to parse JWK")
 		}
 		var key interface{}
-		if !assert.NoError(t, keys.Keys[0].Raw(&key), `jwk.Raw should succeed`) {
+		if !assert.NoError(t, keys.Keys()[0].Raw(&key), `jwk.Raw should succeed`) {
 			return
 		}
 		var jwsCompact []byte
@@ -1008,3 +1024,1751 @@ func TestGHIssue126(t *testing.T) {
 		return
 	}
 }
+
+func TestSerialization(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+	sharedkey := []byte("Avracadabra")
+
+	signer, err := sign.New(jwa.HS256)
+	if !assert.NoError(t, err, `sign.New should succeed`) {
+		return
+	}
+
+	t.Run("Flattened JSON", func(t *testing.T) {
+		public := jws.NewHeaders()
+		_ = public.Set(jws.KeyIDKey, "mykey")
+		signed, err := jws.SignMulti(payload,
+			jws.WithSigner(signer, sharedkey, public, nil),
+			jws.WithSerialization(jws.SerializationFlattenedJSON),
+		)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		var raw map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(signed, &raw), `json.Unmarshal should succeed`) {
+			return
+		}
+		for _, field := range []string{"payload", "protected", "header", "signature"} {
+			if !assert.Contains(t, raw, field, `result should contain %#v`, field) {
+				return
+			}
+		}
+		if !assert.NotContains(t, raw, "signatures", `result should not contain "signatures"`) {
+			return
+		}
+
+		m, err := jws.Parse(bytes.NewReader(signed))
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		if !assert.Len(t, m.Signatures(), 1, `there should be one signature`) {
+			return
+		}
+		if !assert.Equal(t, "mykey", m.Signatures()[0].PublicHeaders().KeyID(), `"kid" should match`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+
+		// json.Marshal always uses the general JSON serialization format,
+		// even when the message contains only one signature
+		reserialized, err := json.Marshal(m)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		raw = map[string]interface{}{}
+		if !assert.NoError(t, json.Unmarshal(reserialized, &raw), `json.Unmarshal should succeed`) {
+			return
+		}
+		if !assert.Len(t, raw["signatures"], 1, `result should contain "signatures"`) {
+			return
+		}
+		for _, field := range []string{"protected", "header", "signature"} {
+			if !assert.NotContains(t, raw, field, `result should not contain %#v`, field) {
+				return
+			}
+		}
+
+		verified, err = jws.Verify(reserialized, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+	})
+	t.Run("General JSON", func(t *testing.T) {
+		testcases := map[string][]jws.Option{
+			"explicit": {jws.WithSigner(signer, sharedkey, nil, nil), jws.WithSerialization(jws.SerializationGeneralJSON)},
+			"default":  {jws.WithSigner(signer, sharedkey, nil, nil)},
+		}
+		for name, options := range testcases {
+			options := options
+			t.Run(name, func(t *testing.T) {
+				signed, err := jws.SignMulti(payload, options...)
+				if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+					return
+				}
+
+				var raw map[string]interface{}
+				if !assert.NoError(t, json.Unmarshal(signed, &raw), `json.Unmarshal should succeed`) {
+					return
+				}
+				if !assert.Len(t, raw["signatures"], 1, `result should contain "signatures"`) {
+					return
+				}
+
+				verified, err := jws.Verify(signed, jwa.HS256, sharedkey)
+				if !assert.NoError(t, err, `jws.Verify should succeed`) {
+					return
+				}
+				if !assert.Equal(t, payload, verified, `verified payload should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Compact", func(t *testing.T) {
+		signed, err := jws.SignMulti(payload,
+			jws.WithSigner(signer, sharedkey, nil, nil),
+			jws.WithSerialization(jws.SerializationCompact),
+		)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+		if !assert.Len(t, bytes.Split(signed, []byte{'.'}), 3, `result should be in compact form`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+	})
+	t.Run("Multiple signers", func(t *testing.T) {
+		for _, s := range []jws.Serialization{jws.SerializationCompact, jws.SerializationFlattenedJSON} {
+			_, err := jws.SignMulti(payload,
+				jws.WithSigner(signer, sharedkey, nil, nil),
+				jws.WithSigner(signer, sharedkey, nil, nil),
+				jws.WithSerialization(s),
+			)
+			if !assert.Error(t, err, `jws.SignMulti should fail`) {
+				return
+			}
+		}
+	})
+	t.Run("Protected headers are verified as-is", func(t *testing.T) {
+		// The protected header is not in the order that we would
+		// marshal it in, so verification must use the original bytes
+		signed, err := jws.SignLiteral(payload, jwa.HS256, sharedkey, []byte(`{"kid":"mykey","alg":"HS256"}`))
+		if !assert.NoError(t, err, `jws.SignLiteral should succeed`) {
+			return
+		}
+
+		parts := strings.Split(string(signed), ".")
+		flattened := `{"payload":"` + parts[1] + `","protected":"` + parts[0] + `","signature":"` + parts[2] + `"}`
+
+		verified, err := jws.Verify([]byte(flattened), jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+	})
+}
+
+func TestDetachedPayload(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+	sharedkey := []byte("Avracadabra")
+
+	t.Run("Compact", func(t *testing.T) {
+		signed, err := jws.Sign(nil, jwa.HS256, sharedkey, jws.WithDetachedPayload(payload))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		parts := strings.Split(string(signed), ".")
+		if !assert.Len(t, parts, 3, `there should be 3 segments`) {
+			return
+		}
+		if !assert.Empty(t, parts[1], `payload segment should be empty`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey, jws.WithDetachedPayload(payload))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, sharedkey, jws.WithDetachedPayload([]byte("Lorem ipsum dolor")))
+		if !assert.Error(t, err, `jws.Verify with wrong payload should fail`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, sharedkey)
+		if !assert.Error(t, err, `jws.Verify without payload should fail`) {
+			return
+		}
+
+		attached, err := jws.Sign(payload, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = jws.Verify(attached, jwa.HS256, sharedkey, jws.WithDetachedPayload(payload))
+		if !assert.Error(t, err, `jws.Verify with attached payload should fail`) {
+			return
+		}
+	})
+	t.Run("Sign with both payloads", func(t *testing.T) {
+		_, err := jws.Sign(payload, jwa.HS256, sharedkey, jws.WithDetachedPayload(payload))
+		if !assert.Error(t, err, `jws.Sign should fail`) {
+			return
+		}
+	})
+	for name, serialization := range map[string]jws.Serialization{"General JSON": jws.SerializationGeneralJSON, "Flattened JSON": jws.SerializationFlattenedJSON} {
+		serialization := serialization
+		t.Run(name, func(t *testing.T) {
+			signer, err := sign.New(jwa.HS256)
+			if !assert.NoError(t, err, `sign.New should succeed`) {
+				return
+			}
+
+			signed, err := jws.SignMulti(nil, jws.WithSigner(signer, sharedkey, nil, nil), jws.WithSerialization(serialization), jws.WithDetachedPayload(payload))
+			if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+				return
+			}
+
+			var m map[string]interface{}
+			if !assert.NoError(t, json.Unmarshal(signed, &m), `json.Unmarshal should succeed`) {
+				return
+			}
+			if !assert.NotContains(t, m, "payload", `payload should be omitted`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, jwa.HS256, sharedkey, jws.WithDetachedPayload(payload))
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `verified payload should match`) {
+				return
+			}
+
+			_, err = jws.Verify(signed, jwa.HS256, sharedkey, jws.WithDetachedPayload([]byte("Lorem ipsum dolor")))
+			if !assert.Error(t, err, `jws.Verify with wrong payload should fail`) {
+				return
+			}
+		})
+	}
+}
+
+func TestUnencodedPayload(t *testing.T) {
+	// RFC 7797 Section 4
+	const encoded = `eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19..A5dxf2s96_n5FLueVuW1Z_vh161FwXZC4YLPff6dmDY`
+	payload := []byte(`$.02`)
+	sharedkey, err := base64.RawURLEncoding.DecodeString(`AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow`)
+	if !assert.NoError(t, err, `decoding key should succeed`) {
+		return
+	}
+
+	t.Run("RFC 7797 example", func(t *testing.T) {
+		verified, err := jws.Verify([]byte(encoded), jwa.HS256, sharedkey, jws.WithDetachedPayload(payload))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+	})
+	t.Run("Compact", func(t *testing.T) {
+		hdrs := jws.NewHeaders()
+		hdrs.Set(jws.B64Key, false)
+
+		_, err := jws.Sign(payload, jwa.HS256, sharedkey, jws.WithHeaders(hdrs))
+		if !assert.Error(t, err, `jws.Sign with '.' in the unencoded payload should fail`) {
+			return
+		}
+
+		signed, err := jws.Sign([]byte(`hello`), jwa.HS256, sharedkey, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "hello", strings.Split(string(signed), ".")[1], `payload should not be encoded`) {
+			return
+		}
+
+		m, err := jws.Parse(bytes.NewReader(signed))
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{jws.B64Key}, m.Signatures()[0].ProtectedHeaders().Critical(), `"crit" should contain "b64"`) {
+			return
+		}
+		if !assert.Equal(t, []byte(`hello`), m.Payload(), `payload should match`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte(`hello`), verified, `verified payload should match`) {
+			return
+		}
+	})
+	t.Run("Detached", func(t *testing.T) {
+		hdrs := jws.NewHeaders()
+		hdrs.Set(jws.B64Key, false)
+
+		signed, err := jws.Sign(nil, jwa.HS256, sharedkey, jws.WithHeaders(hdrs), jws.WithDetachedPayload(payload))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey, jws.WithDetachedPayload(payload))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+	})
+	t.Run("JSON", func(t *testing.T) {
+		signer, err := sign.New(jwa.HS256)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+
+		protected := jws.NewHeaders()
+		protected.Set(jws.B64Key, false)
+		signed, err := jws.SignMulti(payload, jws.WithSigner(signer, sharedkey, nil, protected))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		var m map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(signed, &m), `json.Unmarshal should succeed`) {
+			return
+		}
+		if !assert.Equal(t, string(payload), m["payload"], `payload should not be encoded`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+	})
+	t.Run("b64 not in crit", func(t *testing.T) {
+		signer, err := sign.New(jwa.HS256)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+
+		hdr := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","b64":false}`))
+		signature, err := signer.Sign([]byte(hdr+".hello"), sharedkey)
+		if !assert.NoError(t, err, `signer.Sign should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify([]byte(hdr+".hello."+base64.RawURLEncoding.EncodeToString(signature)), jwa.HS256, sharedkey)
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+}
+
+func TestVerify_Critical(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+	sharedkey := []byte("Avracadabra")
+
+	signWithCrit := func(t *testing.T, crit []string, params map[string]interface{}) []byte {
+		t.Helper()
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.CriticalKey, crit)
+		for k, v := range params {
+			_ = hdrs.Set(k, v)
+		}
+
+		signed, err := jws.Sign(payload, jwa.HS256, sharedkey, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("Unknown extension", func(t *testing.T) {
+		signed := signWithCrit(t, []string{"x-unknown"}, map[string]interface{}{"x-unknown": true})
+		_, err := jws.Verify(signed, jwa.HS256, sharedkey)
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Extension passed via option", func(t *testing.T) {
+		signed := signWithCrit(t, []string{"x-option"}, map[string]interface{}{"x-option": true})
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey, jws.WithCriticalExtensions("x-option"))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+	})
+	t.Run("Registered extension", func(t *testing.T) {
+		jws.RegisterCriticalExtension("x-registered")
+		signed := signWithCrit(t, []string{"x-registered"}, map[string]interface{}{"x-registered": true})
+		_, err := jws.Verify(signed, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+	})
+	t.Run("Extension missing from protected headers", func(t *testing.T) {
+		signed := signWithCrit(t, []string{"x-missing"}, nil)
+		_, err := jws.Verify(signed, jwa.HS256, sharedkey, jws.WithCriticalExtensions("x-missing"))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Standard header", func(t *testing.T) {
+		signed := signWithCrit(t, []string{jws.TypeKey}, map[string]interface{}{jws.TypeKey: "JWT"})
+		_, err := jws.Verify(signed, jwa.HS256, sharedkey, jws.WithCriticalExtensions(jws.TypeKey))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("crit in unprotected headers", func(t *testing.T) {
+		signer, err := sign.New(jwa.HS256)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+
+		public := jws.NewHeaders()
+		_ = public.Set(jws.CriticalKey, []string{"x-option"})
+		_ = public.Set("x-option", true)
+		signed, err := jws.SignMulti(payload, jws.WithSigner(signer, sharedkey, public, nil))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, sharedkey, jws.WithCriticalExtensions("x-option"))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Unknown extension in one of multiple signatures", func(t *testing.T) {
+		signer, err := sign.New(jwa.HS256)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+
+		protected := jws.NewHeaders()
+		_ = protected.Set(jws.CriticalKey, []string{"x-unknown"})
+		_ = protected.Set("x-unknown", true)
+		signed, err := jws.SignMulti(payload,
+			jws.WithSigner(signer, sharedkey, nil, protected),
+			jws.WithSigner(signer, sharedkey, nil, nil),
+		)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		var result jws.VerifyResult
+		verified, err := jws.Verify(signed, jwa.HS256, sharedkey, jws.WithVerifyResult(&result))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+		if !assert.Len(t, result.Signatures(), 1, `one signature should be verified`) {
+			return
+		}
+		if !assert.Equal(t, 1, result.Signatures()[0].Index(), `second signature should be verified`) {
+			return
+		}
+		if !assert.Len(t, result.Rejected(), 1, `one signature should be rejected`) {
+			return
+		}
+		if !assert.Error(t, result.Rejected()[0], `first signature should be rejected`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, sharedkey, jws.WithRequireAllSignatures(true))
+		if !assert.True(t, errors.Is(err, jws.ErrSignatureInvalid), `jws.Verify should fail with jws.ErrSignatureInvalid`) {
+			return
+		}
+	})
+}
+
+func TestVerify_Algorithms(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+	sharedkey := []byte("Avracadabra")
+
+	t.Run("none", func(t *testing.T) {
+		hdr := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+		unsecured := []byte(hdr + "." + base64.RawURLEncoding.EncodeToString(payload) + ".")
+
+		_, err := jws.Verify(unsecured, jwa.NoSignature, nil)
+		if !assert.Error(t, err, `jws.Verify should fail unless "none" is allowed`) {
+			return
+		}
+
+		_, err = jws.Verify(unsecured, jwa.NoSignature, nil, jws.WithAllowedAlgorithms(jwa.HS256))
+		if !assert.Error(t, err, `jws.Verify should fail unless "none" is allowed`) {
+			return
+		}
+
+		verified, err := jws.Verify(unsecured, jwa.NoSignature, nil, jws.WithAllowedAlgorithms(jwa.NoSignature))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `verified payload should match`) {
+			return
+		}
+
+		_, err = jws.Verify(append(unsecured, []byte("Zm9v")...), jwa.NoSignature, nil, jws.WithAllowedAlgorithms(jwa.NoSignature))
+		if !assert.Error(t, err, `jws.Verify with non-empty signature should fail`) {
+			return
+		}
+	})
+	t.Run("Allowlist", func(t *testing.T) {
+		signed, err := jws.Sign(payload, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, sharedkey, jws.WithAllowedAlgorithms(jwa.HS256))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, sharedkey, jws.WithAllowedAlgorithms(jwa.RS256))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Header algorithm mismatch", func(t *testing.T) {
+		signer, err := sign.New(jwa.HS256)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+
+		hdr := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS384"}`))
+		encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+		signature, err := signer.Sign([]byte(hdr+"."+encodedPayload), sharedkey)
+		if !assert.NoError(t, err, `signer.Sign should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify([]byte(hdr+"."+encodedPayload+"."+base64.RawURLEncoding.EncodeToString(signature)), jwa.HS256, sharedkey)
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("jwk.Key", func(t *testing.T) {
+		key, err := jwk.New(sharedkey)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+
+		signed, err := jws.Sign(payload, jwa.HS256, sharedkey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		key.Set(jwk.AlgorithmKey, jwa.HS512)
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if !assert.Error(t, err, `jws.Verify with mismatching "alg" should fail`) {
+			return
+		}
+
+		rsakey, err := jwxtest.GenerateRsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.HS256, rsakey)
+		if !assert.Error(t, err, `jws.Verify with wrong key type should fail`) {
+			return
+		}
+	})
+}
+
+type customSigner struct{}
+
+func (customSigner) Algorithm() jwa.SignatureAlgorithm {
+	return customAlgorithm
+}
+
+func (customSigner) Sign(payload []byte, key interface{}) ([]byte, error) {
+	secret, ok := key.([]byte)
+	if !ok {
+		return nil, errors.New(`invalid key`)
+	}
+	h := sha512.New()
+	h.Write(secret)
+	h.Write(payload)
+	return h.Sum(nil), nil
+}
+
+type customVerifier struct{}
+
+func (customVerifier) Verify(payload, signature []byte, key interface{}) error {
+	expected, err := customSigner{}.Sign(payload, key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, signature) {
+		return errors.New(`signature mismatch`)
+	}
+	return nil
+}
+
+const customAlgorithm = jwa.SignatureAlgorithm("X-SHA512-PREFIX")
+
+func TestCustomAlgorithm(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+	sharedkey := []byte("Avracadabra")
+
+	_, err := jws.Sign(payload, customAlgorithm, sharedkey)
+	if !assert.Error(t, err, `jws.Sign with unregistered algorithm should fail`) {
+		return
+	}
+
+	sign.RegisterSigner(customAlgorithm, sign.SignerFactoryFn(func() (sign.Signer, error) {
+		return customSigner{}, nil
+	}))
+	verify.RegisterVerifier(customAlgorithm, verify.VerifierFactoryFn(func() (verify.Verifier, error) {
+		return customVerifier{}, nil
+	}))
+
+	signed, err := jws.Sign(payload, customAlgorithm, sharedkey)
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+
+	m, err := jws.Parse(bytes.NewReader(signed))
+	if !assert.NoError(t, err, `jws.Parse should succeed`) {
+		return
+	}
+	if !assert.Equal(t, customAlgorithm, m.Signatures()[0].ProtectedHeaders().Algorithm(), `"alg" should match`) {
+		return
+	}
+
+	verified, err := jws.Verify(signed, customAlgorithm, sharedkey)
+	if !assert.NoError(t, err, `jws.Verify should succeed`) {
+		return
+	}
+	if !assert.Equal(t, payload, verified, `verified payload should match`) {
+		return
+	}
+
+	_, err = jws.Verify(signed, customAlgorithm, []byte("wrong key"))
+	if !assert.Error(t, err, `jws.Verify with wrong key should fail`) {
+		return
+	}
+}
+
+func TestES256K(t *testing.T) {
+	// ES256K is only supported for verification, so the signature was
+	// created with a separate implementation
+	const pubkeyJSON = `{"kty":"EC","crv":"secp256k1","x":"dfnOogNXyN6BbsqX_Lr45vvtp0OwhO_FC5yQw5Y8c5U","y":"7dFw57hsgdmR2DuS9kSbp4LQgmYp77tNl3tNispxd5w"}`
+	const signed = `eyJhbGciOiJFUzI1NksifQ.TG9yZW0gaXBzdW0.m3mj8iWLGkEnG4hKn8ApZB_tYlECp_x61juNoguS89sLR3NrTF6ep8NGQPs_4xnBTvZf5hJ9jy5H2IzW96p4aA`
+	payload := []byte("Lorem ipsum")
+
+	pubkey, err := jwk.ParseKey([]byte(pubkeyJSON))
+	if !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+		return
+	}
+
+	t.Run("Verify", func(t *testing.T) {
+		verified, err := jws.Verify([]byte(signed), jwa.ES256K, pubkey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+
+		var rawkey ecdsa.PublicKey
+		if !assert.NoError(t, pubkey.Raw(&rawkey), `pubkey.Raw should succeed`) {
+			return
+		}
+		tampered := []byte(signed[:len(signed)-2] + "AA")
+		if _, err := jws.Verify(tampered, jwa.ES256K, &rawkey); !assert.Error(t, err, `jws.Verify should fail for a tampered signature`) {
+			return
+		}
+		if _, err := jws.Verify([]byte(signed), jwa.ES256, &rawkey); !assert.Error(t, err, `jws.Verify with ES256 should fail`) {
+			return
+		}
+	})
+	t.Run("Sign is rejected", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(ecutil.Secp256k1(), rand.Reader)
+		if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+			return
+		}
+
+		for _, key := range []interface{}{key, opaqueSigner{key: key}} {
+			if _, err := jws.Sign(payload, jwa.ES256K, key); !assert.Error(t, err, `jws.Sign with ES256K should fail`) {
+				return
+			}
+			if _, err := jws.Sign(payload, jwa.ES256, key); !assert.Error(t, err, `jws.Sign with a secp256k1 key should fail`) {
+				return
+			}
+		}
+
+		p256key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+			return
+		}
+		if _, err := jws.Sign(payload, jwa.ES256K, p256key); !assert.Error(t, err, `jws.Sign with ES256K should fail`) {
+			return
+		}
+		if _, err := jws.Verify([]byte(signed), jwa.ES256K, &p256key.PublicKey); !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+}
+
+// opaqueSigner hides the underlying private key, so that it can only
+// be used through the crypto.Signer interface (e.g. like keys stored
+// in an HSM or a KMS)
+type opaqueSigner struct {
+	key crypto.Signer
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestSign_CryptoSigner(t *testing.T) {
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	edkey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		alg jwa.SignatureAlgorithm
+		key crypto.Signer
+	}{
+		{alg: jwa.RS256, key: rsakey},
+		{alg: jwa.PS384, key: rsakey},
+		{alg: jwa.ES512, key: eckey},
+		{alg: jwa.EdDSA, key: edkey},
+	}
+
+	payload := []byte("Lorem ipsum")
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.alg.String(), func(t *testing.T) {
+			signed, err := jws.Sign(payload, tc.alg, opaqueSigner{key: tc.key})
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, tc.alg, tc.key.Public())
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payloads should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("mismatched key type", func(t *testing.T) {
+		_, err := jws.Sign(payload, jwa.ES256, opaqueSigner{key: rsakey})
+		if !assert.Error(t, err, `jws.Sign should fail`) {
+			return
+		}
+	})
+}
+
+type ctxKey struct{}
+
+// contextSigner records the context that was passed to SignContext
+type contextSigner struct {
+	crypto.Signer
+	seen *context.Context
+}
+
+func (s contextSigner) SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	*s.seen = ctx
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+func TestSign_HeadersUnchanged(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+
+	key, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, "mykey")
+
+	newHeaders := func() jws.Headers {
+		h := jws.NewHeaders()
+		_ = h.Set(jws.B64Key, false)
+		_ = h.Set(jws.ContentTypeKey, "example")
+		return h
+	}
+
+	checkHeaders := func(t *testing.T, h jws.Headers) {
+		t.Helper()
+		m, err := h.AsMap(context.TODO())
+		if !assert.NoError(t, err, `h.AsMap should succeed`) {
+			return
+		}
+		expected, _ := newHeaders().AsMap(context.TODO())
+		assert.Equal(t, expected, m, `headers should not be modified`)
+	}
+
+	t.Run("Sign", func(t *testing.T) {
+		hdrs := newHeaders()
+		signed, err := jws.Sign(payload, jwa.HS256, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		checkHeaders(t, hdrs)
+
+		m, err := jws.Parse(bytes.NewReader(signed))
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		protected := m.Signatures()[0].ProtectedHeaders()
+		if !assert.Equal(t, "mykey", protected.KeyID(), `"kid" should be set in the message`) {
+			return
+		}
+		if !assert.Equal(t, []string{jws.B64Key}, protected.Critical(), `"crit" should be set in the message`) {
+			return
+		}
+	})
+	t.Run("SignMulti", func(t *testing.T) {
+		signer, err := sign.New(jwa.HS256)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+
+		hdrs := newHeaders()
+		_, err = jws.SignMulti(payload, jws.WithSigner(signer, []byte("Avracadabra"), nil, hdrs))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+		checkHeaders(t, hdrs)
+	})
+}
+
+func TestWithContext(t *testing.T) {
+	key, err := jwxtest.GenerateEcdsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	payload := []byte("Lorem ipsum")
+	t.Run("context is passed to the key", func(t *testing.T) {
+		var seen context.Context
+		ctx := context.WithValue(context.Background(), ctxKey{}, "foo")
+		signed, err := jws.Sign(payload, jwa.ES512, contextSigner{Signer: key, seen: &seen}, jws.WithContext(ctx))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if !assert.NotNil(t, seen, `SignContext should have been called`) {
+			return
+		}
+		if !assert.Equal(t, "foo", seen.Value(ctxKey{}), `context should be passed to the key`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, jwa.ES512, &key.PublicKey, jws.WithContext(ctx))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+	})
+	t.Run("canceled context", func(t *testing.T) {
+		signed, err := jws.Sign(payload, jwa.ES512, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = jws.Sign(payload, jwa.ES512, key, jws.WithContext(ctx))
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.Sign should fail with context.Canceled`) {
+			return
+		}
+
+		signer, err := sign.New(jwa.ES512)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+		_, err = jws.SignMulti(payload, jws.WithContext(ctx), jws.WithSigner(signer, key, nil, nil))
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.SignMulti should fail with context.Canceled`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.ES512, &key.PublicKey, jws.WithContext(ctx))
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.Verify should fail with context.Canceled`) {
+			return
+		}
+	})
+}
+
+func TestVerifyWithKeyProvider(t *testing.T) {
+	key1, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	key2, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	if !assert.NoError(t, key1.Set(jwk.KeyIDKey, "key1"), `key1.Set should succeed`) {
+		return
+	}
+	if !assert.NoError(t, key2.Set(jwk.KeyIDKey, "key2"), `key2.Set should succeed`) {
+		return
+	}
+
+	pubset := &jwk.Set{}
+	for _, key := range []jwk.Key{key1, key2} {
+		var rawkey interface{}
+		if !assert.NoError(t, key.Raw(&rawkey), `key.Raw should succeed`) {
+			return
+		}
+		pubkey, err := jwk.PublicKeyOf(rawkey)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		pubjwk, err := jwk.New(pubkey)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		if !assert.NoError(t, pubjwk.Set(jwk.KeyIDKey, key.KeyID()), `pubjwk.Set should succeed`) {
+			return
+		}
+		if !assert.NoError(t, pubset.Add(pubjwk), `pubset.Add should succeed`) {
+			return
+		}
+	}
+
+	payload := []byte("Lorem ipsum")
+	signed, err := jws.Sign(payload, jwa.ES256, key2)
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+
+	t.Run("KeySetProvider", func(t *testing.T) {
+		verified, err := jws.VerifyWithKeyProvider(signed, jws.KeySetProvider(pubset))
+		if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+
+		// Only key1 is provided as a candidate, which can not verify the message
+		var rawkey ecdsa.PrivateKey
+		if !assert.NoError(t, key2.Raw(&rawkey), `key2.Raw should succeed`) {
+			return
+		}
+		hdrs := jws.NewHeaders()
+		if !assert.NoError(t, hdrs.Set(jws.KeyIDKey, "key1"), `hdrs.Set should succeed`) {
+			return
+		}
+		mismatched, err := jws.Sign(payload, jwa.ES256, &rawkey, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = jws.VerifyWithKeyProvider(mismatched, jws.KeySetProvider(pubset))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+
+		_, err = jws.VerifyWithKeyProvider(signed, jws.KeySetProvider(pubset), jws.WithAllowedAlgorithms(jwa.RS256))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail for disallowed algorithm`) {
+			return
+		}
+	})
+	t.Run("KeyProviderFunc", func(t *testing.T) {
+		var seen string
+		provider := jws.KeyProviderFunc(func(_ context.Context, headers jws.Headers) ([]jwk.Key, error) {
+			seen = headers.KeyID()
+			return pubset.Keys(), nil
+		})
+		_, err := jws.VerifyWithKeyProvider(signed, provider)
+		if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "key2", seen, `provider should receive the protected headers`) {
+			return
+		}
+
+		failing := jws.KeyProviderFunc(func(_ context.Context, _ jws.Headers) ([]jwk.Key, error) {
+			return nil, errors.New(`failed`)
+		})
+		_, err = jws.VerifyWithKeyProvider(signed, failing)
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+	})
+	t.Run("AutoRefreshProvider", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(pubset)
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL)
+
+		verified, err := jws.VerifyWithKeyProvider(signed, jws.AutoRefreshProvider(ar, srv.URL), jws.WithContext(ctx))
+		if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+	})
+	t.Run("X509ChainProvider", func(t *testing.T) {
+		key, err := jwxtest.GenerateEcdsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+			return
+		}
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "jwx"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+			return
+		}
+
+		thumbprint := sha256.Sum256(der)
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.X509CertChainKey, []string{base64.StdEncoding.EncodeToString(der)})
+		_ = hdrs.Set(jws.X509CertThumbprintS256Key, base64.RawURLEncoding.EncodeToString(thumbprint[:]))
+
+		signed, err := jws.Sign(payload, jwa.ES512, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		_, err = jws.VerifyWithKeyProvider(signed, jws.X509ChainProvider())
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail without root certificates`) {
+			return
+		}
+
+		verified, err := jws.VerifyWithKeyProvider(signed, jws.X509ChainProvider(jws.WithInsecureSkipX509Verify()))
+		if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+
+		_ = hdrs.Set(jws.X509CertThumbprintS256Key, base64.RawURLEncoding.EncodeToString(make([]byte, sha256.Size)))
+		signed, err = jws.Sign(payload, jwa.ES512, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = jws.VerifyWithKeyProvider(signed, jws.X509ChainProvider(jws.WithInsecureSkipX509Verify()))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail for mismatched thumbprint`) {
+			return
+		}
+	})
+}
+
+type testCertificate struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+func makeTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	t.Helper()
+	key, err := jwxtest.GenerateEcdsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		t.FailNow()
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+		t.FailNow()
+	}
+	cert, err := x509.ParseCertificate(der)
+	if !assert.NoError(t, err, `x509.ParseCertificate should succeed`) {
+		t.FailNow()
+	}
+	return &testCertificate{cert: cert, der: der, key: key}
+}
+
+func TestVerifyWithX509Chain(t *testing.T) {
+	now := time.Now()
+	root := makeTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	intermediate := makeTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		PermittedDNSDomains:   []string{"example.com"},
+	}, root)
+	leaf := makeTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "signer.example.com"},
+		DNSNames:     []string{"signer.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, intermediate)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	signWithChain := func(t *testing.T, signer *testCertificate, chain ...*testCertificate) []byte {
+		t.Helper()
+		var x5c []string
+		for _, cert := range chain {
+			x5c = append(x5c, base64.StdEncoding.EncodeToString(cert.der))
+		}
+		hdrs := jws.NewHeaders()
+		if !assert.NoError(t, hdrs.Set(jws.X509CertChainKey, x5c), `hdrs.Set should succeed`) {
+			t.FailNow()
+		}
+		signed, err := jws.Sign([]byte("Lorem ipsum"), jwa.ES512, signer.key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	signed := signWithChain(t, leaf, leaf, intermediate)
+
+	t.Run("no roots", func(t *testing.T) {
+		_, err := jws.VerifyWithKeyProvider(signed, jws.X509ChainProvider(jws.WithX509KeyUsages(x509.ExtKeyUsageCodeSigning)))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+		_, err = jws.VerifyWithKeyProvider(signed, jws.X509ChainProvider(jws.WithX509Roots(nil)))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+	})
+	t.Run("valid chain", func(t *testing.T) {
+		payload, err := jws.VerifyWithX509Chain(signed, roots)
+		if !assert.NoError(t, err, `jws.VerifyWithX509Chain should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte("Lorem ipsum"), payload, `payloads should match`) {
+			return
+		}
+
+		_, err = jws.VerifyWithX509Chain(signed, roots,
+			jws.WithX509KeyUsages(x509.ExtKeyUsageCodeSigning),
+			jws.WithX509DNSName("signer.example.com"),
+		)
+		if !assert.NoError(t, err, `jws.VerifyWithX509Chain should succeed`) {
+			return
+		}
+	})
+	t.Run("untrusted root", func(t *testing.T) {
+		_, err := jws.VerifyWithX509Chain(signed, x509.NewCertPool())
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+	t.Run("missing intermediate", func(t *testing.T) {
+		_, err := jws.VerifyWithX509Chain(signWithChain(t, leaf, leaf), roots)
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		_, err := jws.VerifyWithX509Chain(signed, roots, jws.WithX509CurrentTime(now.Add(2*time.Hour)))
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+	t.Run("extended key usage", func(t *testing.T) {
+		_, err := jws.VerifyWithX509Chain(signed, roots, jws.WithX509KeyUsages(x509.ExtKeyUsageServerAuth))
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+	t.Run("DNS name", func(t *testing.T) {
+		_, err := jws.VerifyWithX509Chain(signed, roots, jws.WithX509DNSName("other.example.com"))
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+	t.Run("name constraints", func(t *testing.T) {
+		outside := makeTestCertificate(t, &x509.Certificate{
+			SerialNumber: big.NewInt(4),
+			Subject:      pkix.Name{CommonName: "signer.example.org"},
+			DNSNames:     []string{"signer.example.org"},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+		}, intermediate)
+		_, err := jws.VerifyWithX509Chain(signWithChain(t, outside, outside, intermediate), roots)
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+	t.Run("key usage does not allow signatures", func(t *testing.T) {
+		encipher := makeTestCertificate(t, &x509.Certificate{
+			SerialNumber: big.NewInt(5),
+			Subject:      pkix.Name{CommonName: "encipher.example.com"},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+			KeyUsage:     x509.KeyUsageKeyAgreement,
+		}, intermediate)
+		_, err := jws.VerifyWithX509Chain(signWithChain(t, encipher, encipher, intermediate), roots)
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+	t.Run("signed by a different key", func(t *testing.T) {
+		_, err := jws.VerifyWithX509Chain(signWithChain(t, intermediate, leaf, intermediate), roots)
+		if !assert.Error(t, err, `jws.VerifyWithX509Chain should fail`) {
+			return
+		}
+	})
+}
+
+func TestJKUProvider(t *testing.T) {
+	key, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	if !assert.NoError(t, key.Set(jwk.KeyIDKey, "key1"), `key.Set should succeed`) {
+		return
+	}
+
+	var rawkey ecdsa.PrivateKey
+	if !assert.NoError(t, key.Raw(&rawkey), `key.Raw should succeed`) {
+		return
+	}
+	pubkey, err := jwk.New(rawkey.PublicKey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	if !assert.NoError(t, pubkey.Set(jwk.KeyIDKey, "key1"), `pubkey.Set should succeed`) {
+		return
+	}
+
+	var fetched int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jwk.NewSet(pubkey))
+	}))
+	defer srv.Close()
+
+	signWithJKU := func(t *testing.T, u string) []byte {
+		t.Helper()
+		hdrs := jws.NewHeaders()
+		if !assert.NoError(t, hdrs.Set(jws.JWKSetURLKey, u), `hdrs.Set should succeed`) {
+			t.FailNow()
+		}
+		signed, err := jws.Sign([]byte("Lorem ipsum"), jwa.ES256, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The URL has not been registered beforehand
+		atomic.StoreInt32(&fetched, 0)
+		ar := jwk.NewAutoRefresh(ctx)
+		provider := jws.JKUProvider(ar, jws.OriginAllowlist(srv.URL))
+		signed := signWithJKU(t, srv.URL+"/jwks.json")
+		for i := 0; i < 5; i++ {
+			payload, err := jws.VerifyWithKeyProvider(signed, provider, jws.WithContext(ctx))
+			if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []byte("Lorem ipsum"), payload, `payloads should match`) {
+				return
+			}
+		}
+		if !assert.Equal(t, int32(1), atomic.LoadInt32(&fetched), `key set should be fetched only once`) {
+			return
+		}
+		if !assert.True(t, ar.IsRegistered(srv.URL+"/jwks.json"), `url should be registered`) {
+			return
+		}
+	})
+	t.Run("prefix", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		provider := jws.JKUProvider(ar, jws.PrefixAllowlist(srv.URL+"/keys/"))
+		_, err := jws.VerifyWithKeyProvider(signWithJKU(t, srv.URL+"/keys/jwks.json"), provider, jws.WithContext(ctx))
+		if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+			return
+		}
+
+		_, err = jws.VerifyWithKeyProvider(signWithJKU(t, srv.URL+"/other/jwks.json"), provider, jws.WithContext(ctx))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+		if !assert.False(t, ar.IsRegistered(srv.URL+"/other/jwks.json"), `disallowed url should not be registered`) {
+			return
+		}
+	})
+	t.Run("limit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL + "/configured.json")
+		provider := jws.JKUProvider(ar, jws.OriginAllowlist(srv.URL), jws.WithMaxRegisteredURLs(1))
+		for _, u := range []string{srv.URL + "/first.json", srv.URL + "/first.json", srv.URL + "/configured.json"} {
+			_, err := jws.VerifyWithKeyProvider(signWithJKU(t, u), provider, jws.WithContext(ctx))
+			if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed for %s`, u) {
+				return
+			}
+		}
+
+		_, err := jws.VerifyWithKeyProvider(signWithJKU(t, srv.URL+"/second.json"), provider, jws.WithContext(ctx))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail once the limit is reached`) {
+			return
+		}
+		if !assert.False(t, ar.IsRegistered(srv.URL+"/second.json"), `url should not be registered`) {
+			return
+		}
+	})
+	t.Run("disallowed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		atomic.StoreInt32(&fetched, 0)
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL + "/jwks.json")
+		provider := jws.JKUProvider(ar, jws.OriginAllowlist("https://example.com"))
+		_, err := jws.VerifyWithKeyProvider(signWithJKU(t, srv.URL+"/jwks.json"), provider, jws.WithContext(ctx))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+
+		for _, u := range []string{
+			"file:///etc/passwd",
+			strings.Replace(srv.URL, "http://", "http://user:pass@", 1) + "/jwks.json",
+			srv.URL + "/keys/../jwks.json",
+			srv.URL + "/jwks.json?random",
+			srv.URL + "/jwks.json#random",
+		} {
+			provider := jws.JKUProvider(ar, jws.URLAllowlistFunc(func(*url.URL) bool { return true }))
+			_, err := jws.VerifyWithKeyProvider(signWithJKU(t, u), provider, jws.WithContext(ctx))
+			if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail for %s`, u) {
+				return
+			}
+		}
+		if !assert.Equal(t, int32(0), atomic.LoadInt32(&fetched), `key set should not be fetched`) {
+			return
+		}
+		if !assert.False(t, ar.IsRegistered(srv.URL+"/jwks.json?random"), `disallowed url should not be registered`) {
+			return
+		}
+	})
+}
+
+func TestX5UProvider(t *testing.T) {
+	now := time.Now()
+	root := makeTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	leaf := makeTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "signer.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, root)
+
+	var fetched int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: leaf.der})
+	}))
+	defer srv.Close()
+
+	hdrs := jws.NewHeaders()
+	if !assert.NoError(t, hdrs.Set(jws.X509URLKey, srv.URL+"/chain.pem"), `hdrs.Set should succeed`) {
+		return
+	}
+	signed, err := jws.Sign([]byte("Lorem ipsum"), jwa.ES512, leaf.key, jws.WithHeaders(hdrs))
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	t.Run("allowed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The URL has not been registered beforehand
+		atomic.StoreInt32(&fetched, 0)
+		ar := jwk.NewAutoRefresh(ctx)
+		provider := jws.X5UProvider(ar, jws.OriginAllowlist(srv.URL), jws.WithX509Roots(roots))
+		for i := 0; i < 5; i++ {
+			payload, err := jws.VerifyWithKeyProvider(signed, provider, jws.WithContext(ctx))
+			if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []byte("Lorem ipsum"), payload, `payloads should match`) {
+				return
+			}
+		}
+		if !assert.Equal(t, int32(1), atomic.LoadInt32(&fetched), `certificate chain should be fetched only once`) {
+			return
+		}
+	})
+	t.Run("untrusted root", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL+"/chain.pem", jwk.WithSetParser(jws.ParsePEMCertificateChain))
+		provider := jws.X5UProvider(ar, jws.OriginAllowlist(srv.URL), jws.WithX509Roots(x509.NewCertPool()))
+		_, err := jws.VerifyWithKeyProvider(signed, provider, jws.WithContext(ctx))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+	})
+	t.Run("disallowed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		atomic.StoreInt32(&fetched, 0)
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL+"/chain.pem", jwk.WithSetParser(jws.ParsePEMCertificateChain))
+		provider := jws.X5UProvider(ar, jws.PrefixAllowlist("https://example.com/"), jws.WithX509Roots(roots))
+		_, err := jws.VerifyWithKeyProvider(signed, provider, jws.WithContext(ctx))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+
+		if !assert.Equal(t, int32(0), atomic.LoadInt32(&fetched), `certificate chain should not be fetched`) {
+			return
+		}
+	})
+}
+
+func TestVerifyResult(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	edkey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+
+	type party struct {
+		kid    string
+		alg    jwa.SignatureAlgorithm
+		key    interface{}
+		public interface{}
+		jwkKey jwk.Key
+	}
+	parties := []*party{
+		{kid: "alice", alg: jwa.RS256, key: rsakey, public: &rsakey.PublicKey},
+		{kid: "bob", alg: jwa.ES256, key: eckey, public: &eckey.PublicKey},
+		{kid: "carol", alg: jwa.EdDSA, key: edkey, public: edkey.Public()},
+	}
+
+	var options []jws.Option
+	for _, p := range parties {
+		key, err := jwk.New(p.public)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		_ = key.Set(jwk.KeyIDKey, p.kid)
+		_ = key.Set(jwk.AlgorithmKey, p.alg.String())
+		p.jwkKey = key
+
+		signer, err := sign.New(p.alg)
+		if !assert.NoError(t, err, `sign.New should succeed`) {
+			return
+		}
+		protected := jws.NewHeaders()
+		_ = protected.Set(jws.KeyIDKey, p.kid)
+		options = append(options, jws.WithSigner(signer, p.key, nil, protected))
+	}
+
+	signed, err := jws.SignMulti(payload, options...)
+	if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+		return
+	}
+
+	t.Run("Compact", func(t *testing.T) {
+		compact, err := jws.Sign(payload, jwa.ES256, eckey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		var result jws.VerifyResult
+		verified, err := jws.Verify(compact, jwa.ES256, &eckey.PublicKey, jws.WithVerifyResult(&result))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+		if !assert.Equal(t, payload, result.Payload(), `payloads should match`) {
+			return
+		}
+		if !assert.Len(t, result.Signatures(), 1, `there should be 1 verified signature`) {
+			return
+		}
+
+		sig := result.Signatures()[0]
+		if !assert.Equal(t, 0, sig.Index(), `index should be 0`) {
+			return
+		}
+		if !assert.Equal(t, jwa.ES256, sig.Algorithm(), `algorithm should match`) {
+			return
+		}
+		if !assert.NotNil(t, sig.Key(), `raw key should be converted to jwk.Key`) {
+			return
+		}
+		expected, err := parties[1].jwkKey.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `Thumbprint should succeed`) {
+			return
+		}
+		actual, err := sig.Key().Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `Thumbprint should succeed`) {
+			return
+		}
+		if !assert.Equal(t, expected, actual, `thumbprints should match`) {
+			return
+		}
+	})
+	t.Run("VerifyWithJWKSet", func(t *testing.T) {
+		set := jwk.NewSet(parties[1].jwkKey)
+
+		var result jws.VerifyResult
+		verified, err := jws.VerifyWithJWKSet(signed, set, nil, jws.WithVerifyResult(&result))
+		if !assert.NoError(t, err, `jws.VerifyWithJWKSet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+		if !assert.Len(t, result.Signatures(), 1, `there should be 1 verified signature`) {
+			return
+		}
+
+		sig := result.Signatures()[0]
+		if !assert.Equal(t, 1, sig.Index(), `index should be 1`) {
+			return
+		}
+		if !assert.Equal(t, jwa.ES256, sig.Algorithm(), `algorithm should match`) {
+			return
+		}
+		if !assert.Equal(t, "bob", sig.Key().KeyID(), `key ID should match`) {
+			return
+		}
+		if !assert.Equal(t, "bob", sig.Signature().ProtectedHeaders().KeyID(), `signature should match`) {
+			return
+		}
+	})
+	t.Run("RequireAllSignatures", func(t *testing.T) {
+		partial := jwk.NewSet(parties[0].jwkKey, parties[1].jwkKey)
+		_, err := jws.VerifyWithJWKSet(signed, partial, nil, jws.WithRequireAllSignatures(true))
+		if !assert.Error(t, err, `jws.VerifyWithJWKSet should fail`) {
+			return
+		}
+
+		full := jwk.NewSet(parties[2].jwkKey, parties[0].jwkKey, parties[1].jwkKey)
+		var result jws.VerifyResult
+		_, err = jws.VerifyWithJWKSet(signed, full, nil, jws.WithRequireAllSignatures(true), jws.WithVerifyResult(&result))
+		if !assert.NoError(t, err, `jws.VerifyWithJWKSet should succeed`) {
+			return
+		}
+		if !assert.Len(t, result.Signatures(), len(parties), `all signatures should be verified`) {
+			return
+		}
+		for i, sig := range result.Signatures() {
+			if !assert.Equal(t, i, sig.Index(), `signatures should be ordered`) {
+				return
+			}
+			if !assert.Equal(t, parties[i].kid, sig.Key().KeyID(), `key ID should match`) {
+				return
+			}
+			if !assert.Equal(t, parties[i].alg, sig.Algorithm(), `algorithm should match`) {
+				return
+			}
+		}
+
+		_, err = jws.Verify(signed, jwa.ES256, &eckey.PublicKey, jws.WithRequireAllSignatures(true))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+
+		_, err = jws.VerifyWithKeyProvider(signed, jws.KeySetProvider(partial), jws.WithRequireAllSignatures(true))
+		if !assert.Error(t, err, `jws.VerifyWithKeyProvider should fail`) {
+			return
+		}
+		result = jws.VerifyResult{}
+		_, err = jws.VerifyWithKeyProvider(signed, jws.KeySetProvider(full), jws.WithRequireAllSignatures(true), jws.WithVerifyResult(&result))
+		if !assert.NoError(t, err, `jws.VerifyWithKeyProvider should succeed`) {
+			return
+		}
+		if !assert.Len(t, result.Signatures(), len(parties), `all signatures should be verified`) {
+			return
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	payload := []byte("Lorem ipsum")
+	key, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	other, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, "key1")
+	_ = other.Set(jwk.KeyIDKey, "key2")
+
+	signed, err := jws.Sign(payload, jwa.ES256, key)
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+
+	t.Run("ErrSignatureInvalid", func(t *testing.T) {
+		_, err := jws.Verify(signed, jwa.ES256, other)
+		if !assert.True(t, errors.Is(err, jws.ErrSignatureInvalid), `error should match jws.ErrSignatureInvalid`) {
+			return
+		}
+
+		_, err = jws.VerifyWithJWKSet(signed, jwk.NewSet(other), nil)
+		if !assert.True(t, errors.Is(err, jws.ErrSignatureInvalid), `error should match jws.ErrSignatureInvalid`) {
+			return
+		}
+
+		_, err = jws.Verify([]byte("foo.bar"), jwa.ES256, other)
+		if !assert.False(t, errors.Is(err, jws.ErrSignatureInvalid), `malformed message should not match jws.ErrSignatureInvalid`) {
+			return
+		}
+	})
+	t.Run("jwk.ErrKeyNotFound", func(t *testing.T) {
+		_, err := jws.VerifyWithKeyProvider(signed, jws.KeySetProvider(jwk.NewSet(other)))
+		if !assert.True(t, errors.Is(err, jwk.ErrKeyNotFound), `error should match jwk.ErrKeyNotFound`) {
+			return
+		}
+
+		var kerr *jwk.KeyNotFoundError
+		if !assert.True(t, errors.As(err, &kerr), `error should be a *jwk.KeyNotFoundError`) {
+			return
+		}
+		if !assert.Equal(t, "key1", kerr.KeyID, `key ID should match`) {
+			return
+		}
+	})
+	t.Run("jwa.ErrUnsupportedAlgorithm", func(t *testing.T) {
+		_, err := jws.Sign(payload, jwa.SignatureAlgorithm("BOGUS"), key)
+		if !assert.True(t, errors.Is(err, jwa.ErrUnsupportedAlgorithm), `error should match jwa.ErrUnsupportedAlgorithm`) {
+			return
+		}
+
+		var aerr *jwa.UnsupportedAlgorithmError
+		if !assert.True(t, errors.As(err, &aerr), `error should be a *jwa.UnsupportedAlgorithmError`) {
+			return
+		}
+		if !assert.Equal(t, "BOGUS", aerr.Algorithm, `algorithm should match`) {
+			return
+		}
+
+		_, err = verify.New(jwa.SignatureAlgorithm("BOGUS"))
+		if !assert.True(t, errors.Is(err, jwa.ErrUnsupportedAlgorithm), `error should match jwa.ErrUnsupportedAlgorithm`) {
+			return
+		}
+	})
+}
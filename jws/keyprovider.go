@@ -0,0 +1,322 @@
+package jws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// KeyProvider provides the candidate keys that are used to verify
+// JWS messages via `jws.VerifyWithKeyProvider()` or `jwt.WithKeyProvider()`.
+//
+// Keys is called for each signature in the message, and receives the
+// protected headers of the signature, which contain hints such as "kid",
+// "jku", "x5c", "x5t#S256" and "alg". Each of the returned keys is tried
+// in turn. Returning no keys is not an error: the signature is skipped.
+type KeyProvider interface {
+	Keys(ctx context.Context, headers Headers) ([]jwk.Key, error)
+}
+
+// KeyProviderFunc is a KeyProvider implemented using a plain function
+type KeyProviderFunc func(context.Context, Headers) ([]jwk.Key, error)
+
+// Keys calls the underlying function to provide the keys
+func (fn KeyProviderFunc) Keys(ctx context.Context, headers Headers) ([]jwk.Key, error) {
+	return fn(ctx, headers)
+}
+
+// lookupKeys returns the keys in the set that match the "kid" header.
+// If the headers do not contain a "kid", all keys in the set are returned
+func lookupKeys(set *jwk.Set, headers Headers) []jwk.Key {
+	var keys []jwk.Key
+	if kid := headers.KeyID(); kid != "" {
+		keys = set.LookupKeyID(kid)
+	} else {
+		keys = set.Keys()
+	}
+
+	var accepted []jwk.Key
+	for _, key := range keys {
+		if DefaultJWKAcceptor.Accept(key) {
+			accepted = append(accepted, key)
+		}
+	}
+	return accepted
+}
+
+type keySetProvider struct {
+	set *jwk.Set
+}
+
+// KeySetProvider returns a KeyProvider that provides keys from a static
+// key set. If the message contains a "kid" header, only the keys with a
+// matching key ID are provided. Otherwise, all keys in the set are provided.
+func KeySetProvider(set *jwk.Set) KeyProvider {
+	return &keySetProvider{set: set}
+}
+
+func (p *keySetProvider) Keys(_ context.Context, headers Headers) ([]jwk.Key, error) {
+	return lookupKeys(p.set, headers), nil
+}
+
+type autoRefreshProvider struct {
+	ar  *jwk.AutoRefresh
+	url string
+}
+
+// AutoRefreshProvider returns a KeyProvider that provides keys from the
+// key set at the given URL, which is fetched through the jwk.AutoRefresh
+// object. This allows keys to be rotated without restarting your
+// application. The URL must be configured using `(*jwk.AutoRefresh).Configure()`
+// beforehand. Keys are chosen in the same way as `jws.KeySetProvider()`.
+func AutoRefreshProvider(ar *jwk.AutoRefresh, url string) KeyProvider {
+	return &autoRefreshProvider{
+		ar:  ar,
+		url: url,
+	}
+}
+
+func (p *autoRefreshProvider) Keys(ctx context.Context, headers Headers) ([]jwk.Key, error) {
+	set, err := p.ar.Fetch(ctx, p.url)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch key set from %s`, p.url)
+	}
+	return lookupKeys(set, headers), nil
+}
+
+type x509ChainProvider struct {
+	roots       *x509.CertPool
+	insecure    bool
+	currentTime time.Time
+	keyUsages   []x509.ExtKeyUsage
+	dnsName     string
+}
+
+// X509ChainProvider returns a KeyProvider that provides the public key
+// of the first (leaf) certificate in the "x5c" header. If the "x5t" or
+// "x5t#S256" headers are present, they must match the thumbprint of the
+// certificate.
+//
+// The certificate chain is validated against the root certificates given
+// in the WithX509Roots option, using the remaining certificates in the
+// "x5c" header as intermediates. The validation can be configured using
+// the WithX509CurrentTime, WithX509KeyUsages and WithX509DNSName options.
+// If the leaf certificate specifies key usages, it must allow digital
+// signatures.
+//
+// If the WithX509Roots option is not specified, Keys returns an error,
+// unless the chain validation is explicitly disabled using the
+// WithInsecureSkipX509Verify option.
+func X509ChainProvider(options ...Option) KeyProvider {
+	return newX509ChainProvider(options...)
+}
+
+func newX509ChainProvider(options ...Option) *x509ChainProvider {
+	var p x509ChainProvider
+	for _, o := range options {
+		switch o.Ident() {
+		case identX509Roots{}:
+			p.roots = o.Value().(*x509.CertPool)
+		case identInsecureSkipX509Verify{}:
+			p.insecure = o.Value().(bool)
+		case identX509CurrentTime{}:
+			p.currentTime = o.Value().(time.Time)
+		case identX509KeyUsages{}:
+			p.keyUsages = o.Value().([]x509.ExtKeyUsage)
+		case identX509DNSName{}:
+			p.dnsName = o.Value().(string)
+		}
+	}
+	return &p
+}
+
+func (p *x509ChainProvider) Keys(_ context.Context, headers Headers) ([]jwk.Key, error) {
+	list := headers.X509CertChain()
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	var chain jwk.CertificateChain
+	if err := chain.Accept(list); err != nil {
+		return nil, errors.Wrap(err, `failed to parse "x5c" header`)
+	}
+
+	return p.keysFromChain(headers, chain.Get())
+}
+
+// keysFromChain checks the thumbprint headers and validates the chain
+// (unless explicitly disabled), and returns the public key of the leaf certificate
+func (p *x509ChainProvider) keysFromChain(headers Headers, certs []*x509.Certificate) ([]jwk.Key, error) {
+	if p.roots == nil && !p.insecure {
+		return nil, errors.New(`root certificates must be specified using jws.WithX509Roots()`)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New(`empty certificate chain`)
+	}
+	leaf := certs[0]
+
+	s1 := sha1.Sum(leaf.Raw)
+	if err := checkThumbprint(headers.X509CertThumbprint(), s1[:]); err != nil {
+		return nil, errors.Wrap(err, `invalid "x5t" header`)
+	}
+	s256 := sha256.Sum256(leaf.Raw)
+	if err := checkThumbprint(headers.X509CertThumbprintS256(), s256[:]); err != nil {
+		return nil, errors.Wrap(err, `invalid "x5t#S256" header`)
+	}
+
+	if p.roots != nil {
+		if err := p.verifyChain(certs); err != nil {
+			return nil, errors.Wrap(err, `failed to verify certificate chain`)
+		}
+	}
+
+	key, err := jwk.New(leaf.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create jwk.Key from certificate`)
+	}
+	return []jwk.Key{key}, nil
+}
+
+func (p *x509ChainProvider) verifyChain(certs []*x509.Certificate) error {
+	leaf := certs[0]
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return errors.New(`certificate key usage does not allow digital signatures`)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	keyUsages := p.keyUsages
+	if len(keyUsages) == 0 {
+		// x509 assumes ExtKeyUsageServerAuth by default, which
+		// is not appropriate for certificates used for signing
+		keyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       p.dnsName,
+		Intermediates: intermediates,
+		Roots:         p.roots,
+		CurrentTime:   p.currentTime,
+		KeyUsages:     keyUsages,
+	})
+	return err
+}
+
+// checkThumbprint compares the base64url encoded thumbprint found in
+// the headers to the computed thumbprint. An empty thumbprint is
+// not an error.
+func checkThumbprint(encoded string, computed []byte) error {
+	if encoded == "" {
+		return nil
+	}
+
+	decoded, err := base64.DecodeString(encoded)
+	if err != nil {
+		return errors.Wrap(err, `failed to decode thumbprint`)
+	}
+
+	if subtle.ConstantTimeCompare(decoded, computed) != 1 {
+		return errors.New(`thumbprint does not match certificate`)
+	}
+	return nil
+}
+
+// VerifyWithKeyProvider verifies the JWS message using the keys provided by
+// the KeyProvider. For each signature in the message, the provider is asked
+// for candidate keys, and each key is tried using `jws.Verify()` with the
+// algorithm specified in the "alg" header of the signature.
+//
+// As the algorithm is taken from the message, the keys must be compatible
+// with the algorithm (see `jws.Verify()` for details), and you should also
+// restrict the acceptable algorithms using the WithAllowedAlgorithms option.
+// Other options are also passed to `jws.Verify()`.
+//
+// If the WithRequireAllSignatures option is specified, every signature
+// must be verified by one of the keys provided for it.
+func VerifyWithKeyProvider(buf []byte, provider KeyProvider, options ...Option) ([]byte, error) {
+	cfg := newVerifyConfig(options)
+
+	msg, err := Parse(bytes.NewReader(buf))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse message`)
+	}
+
+	var result VerifyResult
+	var found bool
+	var kid string
+	for i, sig := range msg.Signatures() {
+		if result.verified(i) {
+			continue
+		}
+
+		protected := sig.ProtectedHeaders()
+		if protected == nil {
+			protected = NewHeaders()
+		}
+
+		keys, err := provider.Keys(cfg.ctx, protected)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to fetch keys for signature #%d`, i+1)
+		}
+
+		if len(keys) > 0 {
+			found = true
+		} else if kid == "" {
+			kid = protected.KeyID()
+		}
+
+		alg := headerAlgorithm(sig.ProtectedHeaders(), sig.PublicHeaders())
+		for _, key := range keys {
+			r, err := verifyMessage(buf, alg, key, cfg)
+			if err == nil {
+				result.merge(r)
+				if !cfg.requireAll || result.complete() {
+					return cfg.finish(&result)
+				}
+				if result.verified(i) {
+					break
+				}
+				continue
+			}
+
+			if err := cfg.ctx.Err(); err != nil {
+				return nil, errors.Wrap(err, `context error while verifying message`)
+			}
+		}
+	}
+
+	// Some, but not all of the signatures could be verified
+	if len(result.signatures) > 0 {
+		return cfg.finish(&result)
+	}
+
+	if !found {
+		return nil, errors.Wrap(&jwk.KeyNotFoundError{KeyID: kid}, `no keys were provided`)
+	}
+	return nil, errors.Wrap(ErrSignatureInvalid, `failed to verify with any of the provided keys`)
+}
+
+// VerifyWithX509Chain verifies the JWS message using the public key of the
+// leaf certificate in the "x5c" header, after validating the certificate
+// chain against the given root certificates. It is equivalent to calling
+// `jws.VerifyWithKeyProvider()` with `jws.X509ChainProvider()` and the
+// WithX509Roots option. The options are passed to both functions.
+func VerifyWithX509Chain(buf []byte, roots *x509.CertPool, options ...Option) ([]byte, error) {
+	if roots == nil {
+		return nil, errors.New(`root certificates must be specified`)
+	}
+
+	provider := X509ChainProvider(append(append([]Option(nil), options...), WithX509Roots(roots))...)
+	return VerifyWithKeyProvider(buf, provider, options...)
+}
@@ -1,8 +1,11 @@
 package jws
 
 import (
+	"strings"
+
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/pool"
 	"github.com/pkg/errors"
 )
 
@@ -25,6 +28,7 @@ func (s Signature) ProtectedHeaders() Headers {
 
 func (s *Signature) SetProtectedHeaders(v Headers) *Signature {
 	s.protected = v
+	s.encodedProtected = ""
 	return s
 }
 
@@ -90,7 +94,7 @@ func (m Message) LookupSignature(kid string) []*Signature {
 }
 
 type messageProxy struct {
-	Payload    string            `json:"payload"` // base64 URL encoded
+	Payload    string            `json:"payload,omitempty"` // base64 URL encoded, omitted if detached
 	Signatures []*signatureProxy `json:"signatures,omitempty"`
 
 	// These are only available when we're using flattened JSON
@@ -102,8 +106,8 @@ type messageProxy struct {
 }
 
 type signatureProxy struct {
-	Header    json.RawMessage `json:"header"`
-	Protected string          `json:"protected"`
+	Header    json.RawMessage `json:"header,omitempty"`
+	Protected string          `json:"protected,omitempty"`
 	Signature string          `json:"signature"`
 }
 
@@ -113,20 +117,17 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 		return errors.Wrap(err, `failed to unmarshal into temporary structure`)
 	}
 
-	// Everything in the proxy is base64 encoded, except for signatures.header
-	if len(proxy.Payload) == 0 {
+	// Everything in the proxy is base64 encoded, except for signatures.header.
+	// The payload may be missing if it's detached (RFC 7515 Appendix F),
+	// but then there must be signatures present
+	if len(proxy.Payload) == 0 && proxy.Signature == nil && len(proxy.Signatures) == 0 {
 		return errors.New(`"payload" must be non-empty`)
 	}
 
-	buf, err := base64.DecodeString(proxy.Payload)
-	if err != nil {
-		return errors.Wrap(err, `failed to decode payload`)
-	}
-	m.payload = buf
-
+	var err error
 	if proxy.Signature != nil {
 		if len(proxy.Signatures) > 0 {
-			return errors.Wrap(err, `invalid format ("signatures" and "signature" keys cannot both be present)`)
+			return errors.New(`invalid format ("signatures" and "signature" keys cannot both be present)`)
 		}
 
 		var sigproxy signatureProxy
@@ -152,6 +153,7 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 		}
 
 		if len(sigproxy.Protected) > 0 {
+			sig.encodedProtected = sigproxy.Protected
 			buf, err = base64.DecodeString(sigproxy.Protected)
 			if err != nil {
 				return errors.Wrapf(err, `failed to decode "protected" for signature #%d`, i+1)
@@ -174,52 +176,204 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 		m.signatures = append(m.signatures, &sig)
 	}
 
-	return nil
-}
+	// The payload can only be decoded after we know the value of "b64"
+	b64, err := m.isBase64Encoded()
+	if err != nil {
+		return err
+	}
 
-func (m Message) MarshalJSON() ([]byte, error) {
-	var proxy messageProxy
+	if b64 {
+		buf, err = base64.DecodeString(proxy.Payload)
+		if err != nil {
+			return errors.Wrap(err, `failed to decode payload`)
+		}
+	} else {
+		buf = []byte(proxy.Payload)
+	}
 
-	proxy.Payload = base64.EncodeToString(m.payload)
+	if len(buf) > 0 {
+		m.payload = buf
+	}
 
-	if len(m.signatures) == 1 {
-		sig := m.signatures[0]
-		var s = string(sig.signature)
-		proxy.Signature = &s
+	return nil
+}
 
-		buf, err := json.Marshal(sig.headers)
+// isBase64Encoded reports whether the payload of the message is
+// base64url encoded. Per RFC 7797, all signatures must agree on
+// the value of the "b64" header.
+func (m Message) isBase64Encoded() (bool, error) {
+	b64 := true
+	for i, sig := range m.signatures {
+		v, err := isBase64Encoded(sig.protected)
 		if err != nil {
-			return nil, errors.Wrap(err, `failed to marshal "header"`)
+			return false, errors.Wrapf(err, `failed to check "b64" header for signature #%d`, i+1)
+		}
+		if i > 0 && v != b64 {
+			return false, errors.New(`all signatures must have the same value for "b64" header`)
 		}
-		hdr := json.RawMessage(buf)
-		proxy.Header = &hdr
+		b64 = v
+	}
+	return b64, nil
+}
+
+func (m Message) encodePayload() (string, error) {
+	b64, err := m.isBase64Encoded()
+	if err != nil {
+		return "", err
+	}
 
-		buf, err = json.Marshal(sig.protected)
+	if b64 {
+		return base64.EncodeToString(m.payload), nil
+	}
+	return string(m.payload), nil
+}
+
+// encodeProtected returns the base64 encoded protected headers. If the
+// signature was parsed from a message, the original representation is used.
+func (s *Signature) encodeProtected() (string, error) {
+	if s.encodedProtected != "" {
+		return s.encodedProtected, nil
+	}
+
+	if s.protected == nil {
+		return "", nil
+	}
+
+	buf, err := json.Marshal(s.protected)
+	if err != nil {
+		return "", errors.Wrap(err, `failed to marshal "protected"`)
+	}
+	return base64.EncodeToString(buf), nil
+}
+
+func (s *Signature) encodeHeaders() (json.RawMessage, error) {
+	if s.headers == nil {
+		return nil, nil
+	}
+
+	buf, err := json.Marshal(s.headers)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal "header"`)
+	}
+
+	// Omit empty headers
+	if len(buf) <= 2 {
+		return nil, nil
+	}
+	return buf, nil
+}
+
+func (s *Signature) makeProxy() (*signatureProxy, error) {
+	var proxy signatureProxy
+
+	hdr, err := s.encodeHeaders()
+	if err != nil {
+		return nil, err
+	}
+	proxy.Header = hdr
+
+	protected, err := s.encodeProtected()
+	if err != nil {
+		return nil, err
+	}
+	proxy.Protected = protected
+	proxy.Signature = base64.EncodeToString(s.signature)
+	return &proxy, nil
+}
+
+// MarshalJSON serializes the message using the general JSON serialization
+// format. To obtain the flattened JSON serialization, use
+// `jws.WithSerialization(jws.SerializationFlattenedJSON)` when signing.
+func (m Message) MarshalJSON() ([]byte, error) {
+	return m.marshalGeneral()
+}
+
+func (m Message) marshalGeneral() ([]byte, error) {
+	var proxy messageProxy
+
+	payload, err := m.encodePayload()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode payload`)
+	}
+	proxy.Payload = payload
+	for i, sig := range m.signatures {
+		sigproxy, err := sig.makeProxy()
 		if err != nil {
-			return nil, errors.Wrap(err, `failed to marshal "protected"`)
+			return nil, errors.Wrapf(err, `failed to encode signature #%d`, i+1)
 		}
-		protected := base64.EncodeToString(buf)
-		proxy.Protected = &protected
-	} else {
-		for i, sig := range m.signatures {
-			var sigproxy signatureProxy
+		proxy.Signatures = append(proxy.Signatures, sigproxy)
+	}
 
-			buf, err := json.Marshal(sig.headers)
-			if err != nil {
-				return nil, errors.Wrapf(err, `failed to marshal "header" for signature #%d`, i+1)
-			}
-			sigproxy.Header = buf
+	return json.Marshal(proxy)
+}
 
-			buf, err = json.Marshal(sig.protected)
-			if err != nil {
-				return nil, errors.Wrapf(err, `failed to marshal "protected" for signature #%d`, i+1)
-			}
-			sigproxy.Protected = base64.EncodeToString(buf)
-			sigproxy.Signature = base64.EncodeToString(sig.signature)
+func (m Message) marshalFlattened() ([]byte, error) {
+	if len(m.signatures) != 1 {
+		return nil, errors.Errorf(`flattened JSON serialization requires exactly one signature (got %d)`, len(m.signatures))
+	}
 
-			proxy.Signatures = append(proxy.Signatures, &sigproxy)
-		}
+	var proxy messageProxy
+
+	payload, err := m.encodePayload()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode payload`)
 	}
+	proxy.Payload = payload
+
+	sigproxy, err := m.signatures[0].makeProxy()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode signature`)
+	}
+
+	if len(sigproxy.Header) > 0 {
+		proxy.Header = &sigproxy.Header
+	}
+	if len(sigproxy.Protected) > 0 {
+		proxy.Protected = &sigproxy.Protected
+	}
+	proxy.Signature = &sigproxy.Signature
 
 	return json.Marshal(proxy)
 }
+
+// marshalCompact serializes the message using the compact serialization format
+func (m Message) marshalCompact() ([]byte, error) {
+	if len(m.signatures) != 1 {
+		return nil, errors.Errorf(`compact serialization requires exactly one signature (got %d)`, len(m.signatures))
+	}
+
+	sig := m.signatures[0]
+	hdr, err := sig.encodeHeaders()
+	if err != nil {
+		return nil, err
+	}
+	if len(hdr) > 0 {
+		return nil, errors.New(`compact serialization cannot contain unprotected headers`)
+	}
+
+	protected, err := sig.encodeProtected()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := m.encodePayload()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode payload`)
+	}
+	if strings.IndexByte(payload, '.') >= 0 {
+		return nil, errors.New(`unencoded payload in compact serialization must not contain '.'`)
+	}
+
+	buf := pool.GetBytesBuffer()
+	defer pool.ReleaseBytesBuffer(buf)
+
+	buf.WriteString(protected)
+	buf.WriteByte('.')
+	buf.WriteString(payload)
+	buf.WriteByte('.')
+	buf.WriteString(base64.EncodeToString(sig.signature))
+
+	result := make([]byte, buf.Len())
+	copy(result, buf.Bytes())
+	return result, nil
+}
@@ -1,14 +1,50 @@
 package jws
 
 import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jws/sign"
 	"github.com/lestrrat-go/option"
 )
 
 type Option = option.Interface
 
+type identAllowedAlgorithms struct{}
+type identContext struct{}
+type identCriticalExtensions struct{}
+type identDetachedPayload struct{}
 type identPayloadSigner struct{}
 type identHeaders struct{}
+type identInsecureSkipX509Verify struct{}
+type identMaxRegisteredURLs struct{}
+type identRequireAllSignatures struct{}
+type identSerialization struct{}
+type identVerifyResult struct{}
+type identX509CurrentTime struct{}
+type identX509DNSName struct{}
+type identX509KeyUsages struct{}
+type identX509Roots struct{}
+
+// Serialization describes the format that a JWS message is serialized in
+type Serialization int
+
+const (
+	// SerializationCompact represents the JWS compact serialization format.
+	// It can only be used when there is exactly one signature, and no
+	// unprotected headers.
+	SerializationCompact Serialization = iota + 1
+	// SerializationGeneralJSON represents the general JWS JSON serialization
+	// format, where signatures are stored in the "signatures" array.
+	SerializationGeneralJSON
+	// SerializationFlattenedJSON represents the flattened JWS JSON
+	// serialization format, where the "protected", "header", and
+	// "signature" fields are stored at the top level of the JSON object.
+	// It can only be used when there is exactly one signature.
+	SerializationFlattenedJSON
+)
 
 func WithSigner(signer sign.Signer, key interface{}, public, protected Headers) Option {
 	return option.New(identPayloadSigner{}, &payloadSigner{
@@ -22,3 +58,121 @@ func WithSigner(signer sign.Signer, key interface{}, public, protected Headers)
 func WithHeaders(h Headers) Option {
 	return option.New(identHeaders{}, h)
 }
+
+// WithSerialization specifies the serialization format that should be used
+// by functions such as `jws.SignMulti`.
+func WithSerialization(v Serialization) Option {
+	return option.New(identSerialization{}, v)
+}
+
+// WithDetachedPayload can be used to both sign or verify a JWS message with a
+// detached payload (RFC 7515 Appendix F).
+//
+// When this option is used for `jws.Sign()` or `jws.SignMulti()`, the
+// payload argument must be nil. The value given to this option is signed,
+// but it is omitted from the resulting message: in compact serialization
+// the payload segment is left empty (`header..signature`), and in JSON
+// serializations the "payload" field is omitted.
+//
+// When this option is used for `jws.Verify()`, the message must not
+// contain a payload. The value given to this option is used as the payload
+// when verifying the signature.
+func WithDetachedPayload(v []byte) Option {
+	return option.New(identDetachedPayload{}, v)
+}
+
+// WithCriticalExtensions specifies the header parameter names that are
+// understood by the caller of `jws.Verify()`, in addition to those
+// registered via `jws.RegisterCriticalExtension()`. Messages that list
+// other names in the "crit" header are rejected.
+func WithCriticalExtensions(names ...string) Option {
+	return option.New(identCriticalExtensions{}, names)
+}
+
+// WithAllowedAlgorithms specifies the list of signature algorithms that
+// are acceptable when verifying a message using `jws.Verify()` and its
+// variants. If the algorithm used for verification is not in this list,
+// verification fails.
+//
+// Unsecured messages (i.e. "alg": "none") can only be verified if
+// jwa.NoSignature is included in this list.
+func WithAllowedAlgorithms(algs ...jwa.SignatureAlgorithm) Option {
+	return option.New(identAllowedAlgorithms{}, algs)
+}
+
+// WithContext specifies the context.Context that is used by `jws.Sign()`,
+// `jws.SignMulti()` and `jws.Verify()` and its variants. The context is
+// checked for cancellation before each signature is created or verified,
+// and it is passed to signers and verifiers that implement
+// `sign.ContextSigner` or `verify.ContextVerifier`. The built-in signers
+// pass it on to keys that implement `sign.ContextCryptoSigner`.
+func WithContext(ctx context.Context) Option {
+	return option.New(identContext{}, ctx)
+}
+
+// WithX509Roots specifies the trusted root certificates that are used by
+// `jws.X509ChainProvider()` to validate the certificate chain in the "x5c"
+// header. This option is required, unless WithInsecureSkipX509Verify is
+// specified.
+func WithX509Roots(pool *x509.CertPool) Option {
+	return option.New(identX509Roots{}, pool)
+}
+
+// WithInsecureSkipX509Verify allows `jws.X509ChainProvider()` to be used
+// without the WithX509Roots option. In this case the certificate chain
+// in the "x5c" header is NOT validated, and anybody can create a
+// self-signed certificate and include it in the message, so the key
+// must be checked by other means (e.g. by comparing its thumbprint to a
+// known value). This option should only be used for testing.
+func WithInsecureSkipX509Verify() Option {
+	return option.New(identInsecureSkipX509Verify{}, true)
+}
+
+// WithMaxRegisteredURLs specifies the maximum number of URLs that
+// `jws.JKUProvider()` and `jws.X5UProvider()` register to the
+// jwk.AutoRefresh object when they are first found in a message.
+// Once the limit is reached, messages pointing to other URLs that
+// have not been registered beforehand are rejected.
+func WithMaxRegisteredURLs(n int) Option {
+	return option.New(identMaxRegisteredURLs{}, n)
+}
+
+// WithX509CurrentTime specifies the time at which the certificate chain
+// in the "x5c" header is validated by `jws.X509ChainProvider()`. If this
+// option is not specified, the current time is used.
+func WithX509CurrentTime(t time.Time) Option {
+	return option.New(identX509CurrentTime{}, t)
+}
+
+// WithX509KeyUsages specifies the extended key usages that the certificate
+// in the "x5c" header must be valid for. If this option is not specified,
+// any extended key usage is accepted.
+func WithX509KeyUsages(usages ...x509.ExtKeyUsage) Option {
+	return option.New(identX509KeyUsages{}, usages)
+}
+
+// WithX509DNSName specifies the name that the certificate in the "x5c"
+// header must be valid for. See `(*x509.Certificate).VerifyHostname()`
+// for details. Name constraints in the chain are always enforced.
+func WithX509DNSName(name string) Option {
+	return option.New(identX509DNSName{}, name)
+}
+
+// WithVerifyResult specifies a VerifyResult object that is populated by
+// `jws.Verify()` and its variants when the verification succeeds. It
+// reports which signatures were verified, and the keys and algorithms
+// that verified them, which is useful for audit logging.
+func WithVerifyResult(r *VerifyResult) Option {
+	return option.New(identVerifyResult{}, r)
+}
+
+// WithRequireAllSignatures specifies that every signature in the message
+// must verify, instead of just one of them. This is useful for messages
+// that require approval from multiple parties.
+//
+// When used with `jws.Verify()`, all signatures must be verifiable using
+// the same key. With `jws.VerifyWithJWKSet()` and `jws.VerifyWithKeyProvider()`,
+// each signature may be verified by a different key.
+func WithRequireAllSignatures(v bool) Option {
+	return option.New(identRequireAllSignatures{}, v)
+}
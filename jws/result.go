@@ -0,0 +1,99 @@
+package jws
+
+import (
+	"sort"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// Payload returns the payload of the verified message
+func (r *VerifyResult) Payload() []byte {
+	return r.payload
+}
+
+// Signatures returns the signatures that were verified, ordered by their
+// position in the message. Unless the WithRequireAllSignatures option is
+// used, verification stops at the first signature that verifies, and
+// only that signature is returned.
+func (r *VerifyResult) Signatures() []*VerifiedSignature {
+	return r.signatures
+}
+
+// Rejected returns the signatures that were skipped without checking
+// their signature value because their headers could not be processed
+// (e.g. an unsupported extension was listed in the "crit" header). The
+// map is keyed by the position of the signature in the message, and
+// its values describe why the signature was rejected.
+func (r *VerifyResult) Rejected() map[int]error {
+	return r.rejected
+}
+
+// reject records that the signature at index i was rejected
+func (r *VerifyResult) reject(i int, err error) {
+	if r.rejected == nil {
+		r.rejected = make(map[int]error)
+	}
+	r.rejected[i] = err
+}
+
+// merge adds the signatures in other that have not been verified yet
+func (r *VerifyResult) merge(other *VerifyResult) {
+	r.payload = other.payload
+	r.count = other.count
+	for i, err := range other.rejected {
+		r.reject(i, err)
+	}
+
+	seen := make(map[int]struct{})
+	for _, sig := range r.signatures {
+		seen[sig.index] = struct{}{}
+	}
+	for _, sig := range other.signatures {
+		if _, ok := seen[sig.index]; ok {
+			continue
+		}
+		r.signatures = append(r.signatures, sig)
+	}
+	sort.Slice(r.signatures, func(i, j int) bool {
+		return r.signatures[i].index < r.signatures[j].index
+	})
+}
+
+// verified returns true if the signature at index i has been verified
+func (r *VerifyResult) verified(i int) bool {
+	for _, sig := range r.signatures {
+		if sig.index == i {
+			return true
+		}
+	}
+	return false
+}
+
+// complete returns true if all signatures in the message have been verified
+func (r *VerifyResult) complete() bool {
+	return r.count > 0 && len(r.signatures) == r.count
+}
+
+// Index returns the position of the signature in the message. For messages
+// in compact serialization, this is always 0.
+func (s *VerifiedSignature) Index() int {
+	return s.index
+}
+
+// Signature returns the signature, including its headers
+func (s *VerifiedSignature) Signature() *Signature {
+	return s.signature
+}
+
+// Algorithm returns the algorithm that was used to verify the signature
+func (s *VerifiedSignature) Algorithm() jwa.SignatureAlgorithm {
+	return s.algorithm
+}
+
+// Key returns the key that verified the signature. If a raw key was
+// used for verification, it is converted using `jwk.New()`, and this
+// method returns nil if the conversion fails.
+func (s *VerifiedSignature) Key() jwk.Key {
+	return s.key
+}
@@ -1,10 +1,15 @@
 package sign
 
 import (
+	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
 
+	"github.com/lestrrat-go/jwx/internal/ecutil"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
@@ -24,8 +29,8 @@ func init() {
 }
 
 func makeECDSASignFunc(hash crypto.Hash) ecdsaSignFunc {
-	return func(payload []byte, key *ecdsa.PrivateKey) ([]byte, error) {
-		curveBits := key.Curve.Params().BitSize
+	return func(payload []byte, key crypto.Signer, curve elliptic.Curve) ([]byte, error) {
+		curveBits := curve.Params().BitSize
 		keyBytes := curveBits / 8
 		// Curve bits do not need to be a multiple of 8.
 		if curveBits%8 > 0 {
@@ -35,11 +40,26 @@ func makeECDSASignFunc(hash crypto.Hash) ecdsaSignFunc {
 		if _, err := h.Write(payload); err != nil {
 			return nil, errors.Wrap(err, "failed to write payload using ecdsa")
 		}
-		r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		// crypto.Signer returns ASN.1 DER encoded signatures, which need
+		// to be converted to the R||S form required by JWS (RFC 7518 3.4)
+		signed, err := key.Sign(rand.Reader, h.Sum(nil), hash)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to sign payload using ecdsa")
 		}
 
+		var sig struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(signed, &sig); err != nil {
+			return nil, errors.Wrap(err, "failed to parse ecdsa signature")
+		} else if len(rest) > 0 {
+			return nil, errors.New("failed to parse ecdsa signature: trailing data")
+		}
+		r, s := sig.R, sig.S
+		if r == nil || s == nil || r.Sign() <= 0 || s.Sign() <= 0 || len(r.Bytes()) > keyBytes || len(s.Bytes()) > keyBytes {
+			return nil, errors.New("invalid ecdsa signature")
+		}
+
 		rBytes := r.Bytes()
 		rBytesPadded := make([]byte, keyBytes)
 		copy(rBytesPadded[keyBytes-len(rBytes):], rBytes)
@@ -69,20 +89,47 @@ func (s ECDSASigner) Algorithm() jwa.SignatureAlgorithm {
 	return s.alg
 }
 
+// Sign creates a signature using crypto/ecdsa. key must be a non-nil instance of
+// `*"crypto/ecdsa".PrivateKey`, or a `crypto.Signer` whose public key is
+// a `*"crypto/ecdsa".PublicKey` (e.g. a key stored in an HSM or a KMS)
+//
+// secp256k1 keys can not be used, as ES256K is only supported for
+// verifying signatures.
 func (s ECDSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
 	if key == nil {
 		return nil, errors.New(`missing private key while signing payload`)
 	}
 
-	var pubkey *ecdsa.PrivateKey
+	var signer crypto.Signer
 	switch v := key.(type) {
 	case ecdsa.PrivateKey:
-		pubkey = &v
-	case *ecdsa.PrivateKey:
-		pubkey = v
+		signer = &v
+	case crypto.Signer:
+		signer = v
 	default:
-		return nil, errors.Errorf(`invalid key type %T. *ecdsa.PrivateKey is required`, key)
+		return nil, errors.Errorf(`invalid key type %T. *ecdsa.PrivateKey or crypto.Signer is required`, key)
+	}
+
+	pubkey, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf(`invalid crypto.Signer: public key must be *ecdsa.PublicKey, got %T`, signer.Public())
+	}
+
+	// secp256k1 keys must only be used with ES256K (RFC 8812), which
+	// is only supported for verifying signatures
+	if ecutil.IsSecp256k1(pubkey.Curve) {
+		return nil, errors.Errorf(`invalid curve %s for algorithm %s`, pubkey.Curve.Params().Name, s.alg)
 	}
 
-	return s.sign(payload, pubkey)
+	return s.sign(payload, signer, pubkey.Curve)
+}
+
+// SignContext is the same as Sign, but the context is passed to the
+// key if it is a ContextCryptoSigner
+func (s ECDSASigner) SignContext(ctx context.Context, payload []byte, key interface{}) ([]byte, error) {
+	key, err := bindContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Sign(payload, key)
 }
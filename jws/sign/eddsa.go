@@ -1,7 +1,10 @@
 package sign
 
 import (
+	"context"
+	"crypto"
 	"crypto/ed25519"
+	"crypto/rand"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
@@ -15,11 +18,30 @@ func (s EdDSASigner) Algorithm() jwa.SignatureAlgorithm {
 	return jwa.EdDSA
 }
 
+// Sign creates a signature using crypto/ed25519. key must be an instance of
+// `"crypto/ed25519".PrivateKey`, or a `crypto.Signer` whose public key is
+// a `"crypto/ed25519".PublicKey` (e.g. a key stored in an HSM or a KMS)
 func (s EdDSASigner) Sign(payload []byte, keyif interface{}) ([]byte, error) {
 	switch key := keyif.(type) {
 	case ed25519.PrivateKey:
 		return ed25519.Sign(key, payload), nil
+	case crypto.Signer:
+		if _, ok := key.Public().(ed25519.PublicKey); !ok {
+			return nil, errors.Errorf(`invalid crypto.Signer: public key must be ed25519.PublicKey, got %T`, key.Public())
+		}
+		// Ed25519 signs the message itself, so no hash function is specified
+		return key.Sign(rand.Reader, payload, crypto.Hash(0))
 	default:
 		return nil, errors.Errorf(`invalid key type %T`, keyif)
 	}
 }
+
+// SignContext is the same as Sign, but the context is passed to the
+// key if it is a ContextCryptoSigner
+func (s EdDSASigner) SignContext(ctx context.Context, payload []byte, key interface{}) ([]byte, error) {
+	key, err := bindContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Sign(payload, key)
+}
@@ -1,8 +1,10 @@
 package sign
 
 import (
-	"crypto/ecdsa"
-	"crypto/rsa"
+	"context"
+	"crypto"
+	"crypto/elliptic"
+	"io"
 
 	"github.com/lestrrat-go/jwx/jwa"
 )
@@ -12,14 +14,44 @@ type Signer interface {
 	// `key` is the key used for signing the payload, and is usually
 	// the private key type associated with the signature method. For example,
 	// for `jwa.RSXXX` and `jwa.PSXXX` types, you need to pass the
-	// `*"crypto/rsa".PrivateKey` type.
+	// `*"crypto/rsa".PrivateKey` type, or a `crypto.Signer` backed by
+	// an RSA key.
 	// Check the documentation for each signer for details
 	Sign(payload []byte, key interface{}) ([]byte, error)
 
 	Algorithm() jwa.SignatureAlgorithm
 }
 
-type rsaSignFunc func([]byte, *rsa.PrivateKey) ([]byte, error)
+// ContextSigner is implemented by Signers that can take a context.Context.
+// When a context is specified (e.g. via `jws.WithContext`), SignContext
+// is called instead of Sign.
+type ContextSigner interface {
+	SignContext(ctx context.Context, payload []byte, key interface{}) ([]byte, error)
+}
+
+// ContextCryptoSigner is a crypto.Signer that can take a context.Context,
+// such as keys stored in a remote KMS. The RSA, ECDSA and EdDSA signers
+// pass the context given to their SignContext method to these keys.
+type ContextCryptoSigner interface {
+	crypto.Signer
+	SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// SignerFactory creates Signer instances. It is used to register
+// signers for custom algorithms via `sign.RegisterSigner`
+type SignerFactory interface {
+	Create() (Signer, error)
+}
+
+// SignerFactoryFn is a SignerFactory implemented using a plain function
+type SignerFactoryFn func() (Signer, error)
+
+// Create calls the underlying function to create a Signer
+func (fn SignerFactoryFn) Create() (Signer, error) {
+	return fn()
+}
+
+type rsaSignFunc func([]byte, crypto.Signer) ([]byte, error)
 
 // RSASigner uses crypto/rsa to sign the payloads.
 type RSASigner struct {
@@ -27,7 +59,7 @@ type RSASigner struct {
 	sign rsaSignFunc
 }
 
-type ecdsaSignFunc func([]byte, *ecdsa.PrivateKey) ([]byte, error)
+type ecdsaSignFunc func([]byte, crypto.Signer, elliptic.Curve) ([]byte, error)
 
 // ECDSASigner uses crypto/ecdsa to sign the payloads.
 type ECDSASigner struct {
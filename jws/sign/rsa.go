@@ -1,6 +1,7 @@
 package sign
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
@@ -48,23 +49,24 @@ func init() {
 }
 
 func makeSignPKCS1v15(hash crypto.Hash) rsaSignFunc {
-	return func(payload []byte, key *rsa.PrivateKey) ([]byte, error) {
+	return func(payload []byte, key crypto.Signer) ([]byte, error) {
 		h := hash.New()
 		if _, err := h.Write(payload); err != nil {
 			return nil, errors.Wrap(err, "failed to write payload using SignPKCS1v15")
 		}
-		return rsa.SignPKCS1v15(rand.Reader, key, hash, h.Sum(nil))
+		return key.Sign(rand.Reader, h.Sum(nil), hash)
 	}
 }
 
 func makeSignPSS(hash crypto.Hash) rsaSignFunc {
-	return func(payload []byte, key *rsa.PrivateKey) ([]byte, error) {
+	return func(payload []byte, key crypto.Signer) ([]byte, error) {
 		h := hash.New()
 		if _, err := h.Write(payload); err != nil {
 			return nil, errors.Wrap(err, "failed to write payload using SignPSS")
 		}
-		return rsa.SignPSS(rand.Reader, key, hash, h.Sum(nil), &rsa.PSSOptions{
+		return key.Sign(rand.Reader, h.Sum(nil), &rsa.PSSOptions{
 			SaltLength: rsa.PSSSaltLengthAuto,
+			Hash:       hash,
 		})
 	}
 }
@@ -85,21 +87,35 @@ func (s RSASigner) Algorithm() jwa.SignatureAlgorithm {
 }
 
 // Sign creates a signature using crypto/rsa. key must be a non-nil instance of
-// `*"crypto/rsa".PrivateKey`.
+// `*"crypto/rsa".PrivateKey`, or a `crypto.Signer` whose public key is
+// a `*"crypto/rsa".PublicKey` (e.g. a key stored in an HSM or a KMS)
 func (s RSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
 	if key == nil {
 		return nil, errors.New(`missing private key while signing payload`)
 	}
 
-	var privkey *rsa.PrivateKey
+	var signer crypto.Signer
 	switch v := key.(type) {
 	case rsa.PrivateKey:
-		privkey = &v
-	case *rsa.PrivateKey:
-		privkey = v
+		signer = &v
+	case crypto.Signer:
+		if _, ok := v.Public().(*rsa.PublicKey); !ok {
+			return nil, errors.Errorf(`invalid crypto.Signer: public key must be *rsa.PublicKey, got %T`, v.Public())
+		}
+		signer = v
 	default:
-		return nil, errors.Errorf(`invalid key type %T. *rsa.PrivateKey is required`, key)
+		return nil, errors.Errorf(`invalid key type %T. *rsa.PrivateKey or crypto.Signer is required`, key)
 	}
 
-	return s.sign(payload, privkey)
+	return s.sign(payload, signer)
+}
+
+// SignContext is the same as Sign, but the context is passed to the
+// key if it is a ContextCryptoSigner
+func (s RSASigner) SignContext(ctx context.Context, payload []byte, key interface{}) ([]byte, error) {
+	key, err := bindContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Sign(payload, key)
 }
@@ -1,11 +1,38 @@
 package sign
 
 import (
+	"context"
+	"crypto"
+	"io"
+	"sync"
+
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
 
+var muSignerDB sync.RWMutex
+var signerDB = map[jwa.SignatureAlgorithm]SignerFactory{}
+
+// RegisterSigner registers a factory that creates Signers for the given
+// algorithm. This allows applications to add support for signature
+// algorithms that are not natively supported by this library. The
+// algorithm is also registered via `jwa.RegisterSignatureAlgorithm`,
+// so that it is accepted as a valid "alg" value.
+//
+// Algorithms that are natively supported can not be overridden.
+func RegisterSigner(alg jwa.SignatureAlgorithm, f SignerFactory) {
+	jwa.RegisterSignatureAlgorithm(alg)
+	muSignerDB.Lock()
+	signerDB[alg] = f
+	muSignerDB.Unlock()
+}
+
 // New creates a signer that signs payloads using the given signature algorithm.
+//
+// jwa.ES256K is only supported for verifying signatures, as the secp256k1
+// implementation in this module is not constant-time. Applications that
+// need to create ES256K signatures must register their own signer using
+// RegisterSigner.
 func New(alg jwa.SignatureAlgorithm) (Signer, error) {
 	switch alg {
 	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
@@ -17,6 +44,45 @@ func New(alg jwa.SignatureAlgorithm) (Signer, error) {
 	case jwa.EdDSA:
 		return newEdDSA()
 	default:
-		return nil, errors.Errorf(`unsupported signature algorithm %s`, alg)
+		muSignerDB.RLock()
+		f, ok := signerDB[alg]
+		muSignerDB.RUnlock()
+		if !ok {
+			return nil, &jwa.UnsupportedAlgorithmError{Algorithm: alg.String(), Usage: `signature`}
+		}
+
+		signer, err := f.Create()
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create signer for %s`, alg)
+		}
+		return signer, nil
+	}
+}
+
+// contextBoundSigner binds a context to a ContextCryptoSigner, so that
+// it can be used where a crypto.Signer is expected
+type contextBoundSigner struct {
+	ctx    context.Context
+	signer ContextCryptoSigner
+}
+
+func (s contextBoundSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s contextBoundSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.SignContext(s.ctx, rand, digest, opts)
+}
+
+// bindContext checks the context for cancellation, and binds it to
+// the key if the key is a ContextCryptoSigner
+func bindContext(ctx context.Context, key interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `context error while signing payload`)
+	}
+
+	if v, ok := key.(ContextCryptoSigner); ok {
+		return contextBoundSigner{ctx: ctx, signer: v}, nil
 	}
+	return key, nil
 }
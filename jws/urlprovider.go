@@ -0,0 +1,259 @@
+package jws
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// URLAllowlist decides if a URL found in the "jku" or "x5u" headers of
+// a JWS message may be fetched.
+type URLAllowlist interface {
+	IsAllowed(u *url.URL) bool
+}
+
+// URLAllowlistFunc is a URLAllowlist implemented using a plain function
+type URLAllowlistFunc func(*url.URL) bool
+
+// IsAllowed calls the underlying function to decide if the URL is allowed
+func (fn URLAllowlistFunc) IsAllowed(u *url.URL) bool {
+	return fn(u)
+}
+
+// OriginAllowlist returns a URLAllowlist that allows URLs whose origin
+// (i.e. scheme, host and port) matches one of the given origins exactly,
+// such as "https://example.com" or "https://example.com:8443"
+func OriginAllowlist(origins ...string) URLAllowlist {
+	allowed := make(map[string]struct{})
+	for _, origin := range origins {
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = struct{}{}
+	}
+
+	return URLAllowlistFunc(func(u *url.URL) bool {
+		_, ok := allowed[strings.ToLower(u.Scheme+"://"+u.Host)]
+		return ok
+	})
+}
+
+// PrefixAllowlist returns a URLAllowlist that allows URLs that start with
+// one of the given prefixes, such as "https://example.com/keys/". Prefixes
+// should include at least the path separator after the host name, as
+// "https://example.com" would also match "https://example.com.evil.com".
+func PrefixAllowlist(prefixes ...string) URLAllowlist {
+	return URLAllowlistFunc(func(u *url.URL) bool {
+		s := u.String()
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(s, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// DefaultMaxRegisteredURLs is the maximum number of URLs that are
+// registered by `jws.JKUProvider()` and `jws.X5UProvider()`, unless
+// the WithMaxRegisteredURLs option is specified
+const DefaultMaxRegisteredURLs = 100
+
+// checkURL parses the URL found in the headers, and makes sure that it
+// is allowed by the allowlist. URLs that are not absolute http(s) URLs,
+// contain user information, a query or a fragment, or contain ".." path
+// segments are always rejected.
+func checkURL(allowlist URLAllowlist, rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", errors.Wrap(err, `failed to parse url`)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", errors.Errorf(`invalid url scheme %s`, u.Scheme)
+	}
+
+	if u.User != nil {
+		return "", errors.New(`url must not contain user information`)
+	}
+
+	if u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return "", errors.New(`url must not contain a query or a fragment`)
+	}
+
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == ".." {
+			return "", errors.New(`url must not contain ".." path segments`)
+		}
+	}
+
+	if allowlist == nil || !allowlist.IsAllowed(u) {
+		return "", errors.Errorf(`url %s is not allowed`, rawurl)
+	}
+	return u.String(), nil
+}
+
+// urlRegistrar registers the URLs found in the headers to the
+// jwk.AutoRefresh object when they are first used. As the headers
+// have not been verified at this point, the number of URLs that it
+// registers is bounded: otherwise anybody could make us fetch (and
+// keep refreshing) an unlimited number of URLs that match the allowlist.
+type urlRegistrar struct {
+	ar         *jwk.AutoRefresh
+	allowlist  URLAllowlist
+	options    []jwk.AutoRefreshOption
+	max        int
+	mu         sync.Mutex
+	registered map[string]struct{}
+}
+
+func newURLRegistrar(ar *jwk.AutoRefresh, allowlist URLAllowlist, arOptions []jwk.AutoRefreshOption, options []Option) *urlRegistrar {
+	max := DefaultMaxRegisteredURLs
+	for _, o := range options {
+		switch o.Ident() {
+		case identMaxRegisteredURLs{}:
+			max = o.Value().(int)
+		}
+	}
+
+	return &urlRegistrar{
+		ar:         ar,
+		allowlist:  allowlist,
+		options:    arOptions,
+		max:        max,
+		registered: make(map[string]struct{}),
+	}
+}
+
+// register checks the URL, and registers it to the jwk.AutoRefresh
+// object unless it has already been registered
+func (r *urlRegistrar) register(rawurl string) (string, error) {
+	u, err := checkURL(r.allowlist, rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ar.IsRegistered(u) {
+		return u, nil
+	}
+
+	if len(r.registered) >= r.max {
+		return "", errors.Errorf(`url %s can not be registered: too many urls (%d) have been registered`, rawurl, len(r.registered))
+	}
+
+	r.ar.Configure(u, r.options...)
+	r.registered[u] = struct{}{}
+	return u, nil
+}
+
+type jkuProvider struct {
+	*urlRegistrar
+}
+
+// JKUProvider returns a KeyProvider that provides keys from the key set
+// pointed to by the "jku" header of the message. The URL must be allowed
+// by the URLAllowlist, otherwise an error is returned, and nothing is fetched.
+//
+// Key sets are fetched through the jwk.AutoRefresh object, so that
+// messages pointing to the same URL do not trigger repeated fetches.
+// URLs that have not been registered to the jwk.AutoRefresh object are
+// registered using the default options when they are first used, up to
+// the number of URLs given in the WithMaxRegisteredURLs option (default:
+// DefaultMaxRegisteredURLs). To use other options, such as a custom HTTP
+// client, register the URLs beforehand using `(*jwk.AutoRefresh).Configure()`.
+//
+// Keys are chosen in the same way as `jws.KeySetProvider()`.
+func JKUProvider(ar *jwk.AutoRefresh, allowlist URLAllowlist, options ...Option) KeyProvider {
+	return &jkuProvider{
+		urlRegistrar: newURLRegistrar(ar, allowlist, nil, options),
+	}
+}
+
+func (p *jkuProvider) Keys(ctx context.Context, headers Headers) ([]jwk.Key, error) {
+	rawurl := headers.JWKSetURL()
+	if rawurl == "" {
+		return nil, nil
+	}
+
+	u, err := p.register(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid "jku" header`)
+	}
+
+	set, err := p.ar.Fetch(ctx, u)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch key set from %s`, u)
+	}
+	return lookupKeys(set, headers), nil
+}
+
+type x5uProvider struct {
+	*x509ChainProvider
+	*urlRegistrar
+}
+
+// X5UProvider returns a KeyProvider that provides the public key of the
+// first certificate in the PEM encoded certificate chain pointed to by
+// the "x5u" header of the message. The URL must be allowed by the
+// URLAllowlist.
+//
+// Certificate chains are fetched through the jwk.AutoRefresh object in
+// the same way as `jws.JKUProvider()`, using `jws.ParsePEMCertificateChain`
+// as the parser. URLs that are registered beforehand must use it as well:
+//
+//	ar.Configure(u, jwk.WithSetParser(jws.ParsePEMCertificateChain))
+//
+// The certificate chain is handled in the same way as
+// `jws.X509ChainProvider()`, and therefore the WithX509Roots option is
+// required to validate it.
+func X5UProvider(ar *jwk.AutoRefresh, allowlist URLAllowlist, options ...Option) KeyProvider {
+	return &x5uProvider{
+		x509ChainProvider: newX509ChainProvider(options...),
+		urlRegistrar:      newURLRegistrar(ar, allowlist, []jwk.AutoRefreshOption{jwk.WithSetParser(ParsePEMCertificateChain)}, options),
+	}
+}
+
+func (p *x5uProvider) Keys(ctx context.Context, headers Headers) ([]jwk.Key, error) {
+	rawurl := headers.X509URL()
+	if rawurl == "" {
+		return nil, nil
+	}
+
+	u, err := p.register(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid "x5u" header`)
+	}
+
+	set, err := p.ar.Fetch(ctx, u)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch certificate chain from %s`, u)
+	}
+
+	if set.Len() != 1 {
+		return nil, errors.Errorf(`invalid certificate chain from %s`, u)
+	}
+
+	return p.keysFromChain(headers, set.Keys()[0].X509CertChain())
+}
+
+// ParsePEMCertificateChain parses a PEM encoded certificate chain, as
+// referred to by "x5u" headers (RFC 7515 Section 4.1.5), into a key set
+// containing the public key of the first certificate. The certificates
+// are stored in the "x5c" field of the key. It is meant to be used as
+// the parser for URLs used with `jws.X5UProvider()`.
+func ParsePEMCertificateChain(src io.Reader) (*jwk.Set, error) {
+	set, err := jwk.Parse(src, jwk.WithPEM(true))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse certificate chain`)
+	}
+
+	if set.Len() != 1 || len(set.Keys()[0].X509CertChain()) == 0 {
+		return nil, errors.New(`expected exactly one certificate chain`)
+	}
+	return set, nil
+}
@@ -4,6 +4,7 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 
+	"github.com/lestrrat-go/jwx/internal/ecutil"
 	"github.com/lestrrat-go/jwx/internal/pool"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
@@ -13,9 +14,10 @@ var ecdsaVerifyFuncs = map[jwa.SignatureAlgorithm]ecdsaVerifyFunc{}
 
 func init() {
 	algs := map[jwa.SignatureAlgorithm]crypto.Hash{
-		jwa.ES256: crypto.SHA256,
-		jwa.ES384: crypto.SHA384,
-		jwa.ES512: crypto.SHA512,
+		jwa.ES256:  crypto.SHA256,
+		jwa.ES384:  crypto.SHA384,
+		jwa.ES512:  crypto.SHA512,
+		jwa.ES256K: crypto.SHA256,
 	}
 
 	for alg, h := range algs {
@@ -53,6 +55,7 @@ func newECDSA(alg jwa.SignatureAlgorithm) (*ECDSAVerifier, error) {
 	}
 
 	return &ECDSAVerifier{
+		alg:    alg,
 		verify: verifyfn,
 	}, nil
 }
@@ -72,5 +75,10 @@ func (v ECDSAVerifier) Verify(payload []byte, signature []byte, key interface{})
 		return errors.Errorf(`invalid key type %T. *ecdsa.PublicKey is required`, key)
 	}
 
+	// secp256k1 keys must only be used with ES256K, and vice versa (RFC 8812)
+	if (v.alg == jwa.ES256K) != ecutil.IsSecp256k1(pubkey.Curve) {
+		return errors.Errorf(`invalid curve %s for algorithm %s`, pubkey.Curve.Params().Name, v.alg)
+	}
+
 	return v.verify(payload, signature, pubkey)
 }
@@ -1,9 +1,11 @@
 package verify
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jws/sign"
 )
 
@@ -18,6 +20,27 @@ type Verifier interface {
 	Verify(payload []byte, signature []byte, key interface{}) error
 }
 
+// ContextVerifier is implemented by Verifiers that can take a
+// context.Context. When a context is specified (e.g. via
+// `jws.WithContext`), VerifyContext is called instead of Verify.
+type ContextVerifier interface {
+	VerifyContext(ctx context.Context, payload []byte, signature []byte, key interface{}) error
+}
+
+// VerifierFactory creates Verifier instances. It is used to register
+// verifiers for custom algorithms via `verify.RegisterVerifier`
+type VerifierFactory interface {
+	Create() (Verifier, error)
+}
+
+// VerifierFactoryFn is a VerifierFactory implemented using a plain function
+type VerifierFactoryFn func() (Verifier, error)
+
+// Create calls the underlying function to create a Verifier
+func (fn VerifierFactoryFn) Create() (Verifier, error) {
+	return fn()
+}
+
 type rsaVerifyFunc func([]byte, []byte, *rsa.PublicKey) error
 
 type RSAVerifier struct {
@@ -27,6 +50,7 @@ type RSAVerifier struct {
 type ecdsaVerifyFunc func([]byte, []byte, *ecdsa.PublicKey) error
 
 type ECDSAVerifier struct {
+	alg    jwa.SignatureAlgorithm
 	verify ecdsaVerifyFunc
 }
 
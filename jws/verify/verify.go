@@ -1,23 +1,53 @@
 package verify
 
 import (
+	"sync"
+
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
 
+var muVerifierDB sync.RWMutex
+var verifierDB = map[jwa.SignatureAlgorithm]VerifierFactory{}
+
+// RegisterVerifier registers a factory that creates Verifiers for the
+// given algorithm. This allows applications to add support for signature
+// algorithms that are not natively supported by this library. The
+// algorithm is also registered via `jwa.RegisterSignatureAlgorithm`,
+// so that it is accepted as a valid "alg" value.
+//
+// Algorithms that are natively supported can not be overridden.
+func RegisterVerifier(alg jwa.SignatureAlgorithm, f VerifierFactory) {
+	jwa.RegisterSignatureAlgorithm(alg)
+	muVerifierDB.Lock()
+	verifierDB[alg] = f
+	muVerifierDB.Unlock()
+}
+
 // New creates a new JWS verifier using the specified algorithm
 // and the public key
 func New(alg jwa.SignatureAlgorithm) (Verifier, error) {
 	switch alg {
 	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
 		return newRSA(alg)
-	case jwa.ES256, jwa.ES384, jwa.ES512:
+	case jwa.ES256, jwa.ES384, jwa.ES512, jwa.ES256K:
 		return newECDSA(alg)
 	case jwa.HS256, jwa.HS384, jwa.HS512:
 		return newHMAC(alg)
 	case jwa.EdDSA:
 		return newEdDSA()
 	default:
-		return nil, errors.Errorf(`unsupported signature algorithm: %#v`, alg)
+		muVerifierDB.RLock()
+		f, ok := verifierDB[alg]
+		muVerifierDB.RUnlock()
+		if !ok {
+			return nil, &jwa.UnsupportedAlgorithmError{Algorithm: alg.String(), Usage: `signature`}
+		}
+
+		verifier, err := f.Create()
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create verifier for %s`, alg)
+		}
+		return verifier, nil
 	}
 }
@@ -0,0 +1,74 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTokenExpired is matched by `errors.Is()` when the "exp" claim
+	// of the token is not satisfied
+	ErrTokenExpired = errors.New(`token is expired`)
+
+	// ErrTokenNotYetValid is matched by `errors.Is()` when the "nbf"
+	// claim of the token is not satisfied
+	ErrTokenNotYetValid = errors.New(`token is not valid yet`)
+
+	// ErrInvalidIssuedAt is matched by `errors.Is()` when the "iat"
+	// claim of the token is in the future
+	ErrInvalidIssuedAt = errors.New(`token is issued in the future`)
+
+	// ErrClaimMismatch is matched by `errors.Is()` when a claim of the
+	// token does not have the expected value, such as "iss" or "aud"
+	ErrClaimMismatch = errors.New(`claim mismatch`)
+)
+
+// TimeClaimError is returned by `jwt.Validate()` when one of the time
+// based claims ("exp", "nbf" or "iat") is not satisfied. It matches
+// ErrTokenExpired, ErrTokenNotYetValid or ErrInvalidIssuedAt, respectively.
+type TimeClaimError struct {
+	// Claim is the name of the claim
+	Claim string
+	// Value is the time specified by the claim, truncated to the second
+	Value time.Time
+	// Now is the time that the claim was validated against, truncated
+	// to the second
+	Now time.Time
+	// Skew is the acceptable skew specified using WithAcceptableSkew
+	Skew time.Duration
+}
+
+func (e *TimeClaimError) Error() string {
+	return fmt.Sprintf(`%s not satisfied`, e.Claim)
+}
+
+// Is returns true if target is the sentinel error corresponding to the claim
+func (e *TimeClaimError) Is(target error) bool {
+	switch e.Claim {
+	case ExpirationKey:
+		return target == ErrTokenExpired
+	case NotBeforeKey:
+		return target == ErrTokenNotYetValid
+	case IssuedAtKey:
+		return target == ErrInvalidIssuedAt
+	}
+	return false
+}
+
+// ClaimMismatchError is returned by `jwt.Validate()` when a claim does
+// not have the expected value. It matches ErrClaimMismatch.
+type ClaimMismatchError struct {
+	// Claim is the name of the claim
+	Claim string
+}
+
+func (e *ClaimMismatchError) Error() string {
+	return fmt.Sprintf(`%s not satisfied`, e.Claim)
+}
+
+// Is returns true if target is ErrClaimMismatch
+func (e *ClaimMismatchError) Is(target error) bool {
+	return target == ErrClaimMismatch
+}
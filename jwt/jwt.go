@@ -5,6 +5,7 @@ package jwt
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/lestrrat-go/jwx/internal/json"
 
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
 	"github.com/pkg/errors"
@@ -31,8 +33,19 @@ func ParseBytes(s []byte, options ...Option) (Token, error) {
 // The token must be encoded in either JSON format or compact format.
 //
 // If the token is signed and you want to verify the payload matches the signature,
-// you must pass the jwt.WithVerify(alg, key) or jwt.WithKeySet(*jwk.Set) option.
-// If you do not specify these parameters, no verification will be performed.
+// you must pass the jwt.WithVerify(alg, key), jwt.WithKeySet(*jwk.Set) or
+// jwt.WithKeyProvider(jws.KeyProvider) option. If you do not specify these
+// parameters, no verification will be performed.
+//
+// When using jwt.WithKeySet(*jwk.Set) or jwt.WithKeyProvider(jws.KeyProvider),
+// the algorithm is taken from the token header. The chosen key must be compatible with that algorithm,
+// but you should also restrict the acceptable algorithms using the
+// jwt.WithAllowedSignatureAlgorithms() option.
+//
+// If the token is encrypted (i.e. it is a nested JWT, where the JWS is
+// wrapped in a JWE message), you must pass the jwt.WithDecrypt(alg, key) or
+// jwt.WithDecryptKeySet(*jwk.Set) option. The token is then decrypted
+// first, and the decrypted payload is verified and parsed as described above.
 //
 // If you also want to assert the validity of the JWT itself (i.e. expiration
 // and such), use the `Valid()` function on the returned token, or pass the
@@ -45,15 +58,34 @@ func ParseBytes(s []byte, options ...Option) (Token, error) {
 func Parse(src io.Reader, options ...Option) (Token, error) {
 	var params VerifyParameters
 	var keyset *jwk.Set
+	var provider jws.KeyProvider
 	var useDefault bool
 	var token Token
 	var validate bool
+	var decrypt *decryptParams
+	var decryptKeySet *jwk.Set
+	var decryptOptions []jwe.DecryptOption
+	var verifyOptions []jws.Option
 	for _, o := range options {
 		switch o.Ident() {
+		case identContext{}:
+			ctx := o.Value().(context.Context)
+			decryptOptions = append(decryptOptions, jwe.WithDecryptContext(ctx))
+			verifyOptions = append(verifyOptions, jws.WithContext(ctx))
+		case identDecrypt{}:
+			decrypt = o.Value().(*decryptParams)
+		case identDecryptKeySet{}:
+			decryptKeySet = o.Value().(*jwk.Set)
+		case identDecryptAlgorithms{}:
+			decryptOptions = append(decryptOptions, jwe.WithAllowedAlgorithms(o.Value().([]jwa.KeyEncryptionAlgorithm)...))
+		case identSignatureAlgorithms{}:
+			verifyOptions = append(verifyOptions, jws.WithAllowedAlgorithms(o.Value().([]jwa.SignatureAlgorithm)...))
 		case identVerify{}:
 			params = o.Value().(VerifyParameters)
 		case identKeySet{}:
 			keyset = o.Value().(*jwk.Set)
+		case identKeyProvider{}:
+			provider = o.Value().(jws.KeyProvider)
 		case identToken{}:
 			token = o.Value().(Token)
 		case identDefault{}:
@@ -69,6 +101,36 @@ func Parse(src io.Reader, options ...Option) (Token, error) {
 		return nil, errors.Wrap(err, `failed to read from token data source`)
 	}
 	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New(`empty token data`)
+	}
+
+	if isEncrypted(data) {
+		var decrypted []byte
+		switch {
+		case decryptKeySet != nil:
+			decrypted, err = jwe.DecryptWithKeySet(data, decryptKeySet, decryptOptions...)
+		case decrypt != nil:
+			decrypted, err = jwe.Decrypt(data, decrypt.alg, decrypt.key, decryptOptions...)
+		default:
+			return nil, errors.New(`token is encrypted, but no decryption key was specified`)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to decrypt token`)
+		}
+		data = bytes.TrimSpace(decrypted)
+		if len(data) == 0 {
+			return nil, errors.New(`empty token data`)
+		}
+	}
+
+	if provider != nil {
+		payload, err := jws.VerifyWithKeyProvider(data, provider, verifyOptions...)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to verify jws signature`)
+		}
+		return parsePayload(token, payload, validate, options...)
+	}
 
 	// If with matching kid is true, then look for the corresponding key in the
 	// given key set, by matching the "kid" key
@@ -77,23 +139,40 @@ func Parse(src io.Reader, options ...Option) (Token, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, `failed to find matching key for verification`)
 		}
-		return parse(token, data, true, alg, key, validate, options...)
+		return parse(token, data, true, alg, key, verifyOptions, validate, options...)
 	}
 
 	if params != nil {
-		return parse(token, data, true, params.Algorithm(), params.Key(), validate, options...)
+		return parse(token, data, true, params.Algorithm(), params.Key(), verifyOptions, validate, options...)
+	}
+
+	return parse(token, data, false, "", nil, nil, validate, options...)
+}
+
+// isEncrypted returns true if the data looks like a JWE message,
+// as opposed to a JWS message or raw JSON
+func isEncrypted(data []byte) bool {
+	if data[0] != '{' {
+		// compact JWE messages have five segments, while JWS messages have three
+		return bytes.Count(data, []byte{'.'}) == 4
 	}
 
-	return parse(token, data, false, "", nil, validate, options...)
+	var probe struct {
+		CipherText *json.RawMessage `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.CipherText != nil
 }
 
 // verify parameter exists to make sure that we don't accidentally skip
 // over verification just because alg == ""  or key == nil or something.
-func parse(token Token, data []byte, verify bool, alg jwa.SignatureAlgorithm, key interface{}, validate bool, options ...Option) (Token, error) {
+func parse(token Token, data []byte, verify bool, alg jwa.SignatureAlgorithm, key interface{}, verifyOptions []jws.Option, validate bool, options ...Option) (Token, error) {
 	var payload []byte
 	if verify {
 		// If verify is true, the data MUST be a valid jws message
-		v, err := jws.Verify(data, alg, key)
+		v, err := jws.Verify(data, alg, key, verifyOptions...)
 		if err != nil {
 			return nil, errors.Wrap(err, `failed to verify jws signature`)
 		}
@@ -120,6 +199,12 @@ func parse(token Token, data []byte, verify bool, alg jwa.SignatureAlgorithm, ke
 		}
 	}
 
+	return parsePayload(token, payload, validate, options...)
+}
+
+// parsePayload parses the (verified) JWS payload into the token,
+// and validates it if requested
+func parsePayload(token Token, payload []byte, validate bool, options ...Option) (Token, error) {
 	if token == nil {
 		token = New()
 	}
@@ -142,6 +227,10 @@ func parse(token Token, data []byte, verify bool, alg jwa.SignatureAlgorithm, ke
 	return token, nil
 }
 
+// lookupMatchingKey finds the key to verify the token with. Note that the
+// algorithm is taken from the token header, which can not be trusted:
+// the jwk.Key is returned as is so that `jws.Verify()` can make sure
+// that the algorithm is compatible with the key.
 func lookupMatchingKey(data []byte, keyset *jwk.Set, useDefault bool) (jwa.SignatureAlgorithm, interface{}, error) {
 	msg, err := jws.Parse(bytes.NewReader(data))
 	if err != nil {
@@ -152,28 +241,23 @@ func lookupMatchingKey(data []byte, keyset *jwk.Set, useDefault bool) (jwa.Signa
 	kid := headers.KeyID()
 	if kid == "" {
 		if !useDefault {
-			return "", nil, errors.New(`failed to find matching key: no key ID specified in token`)
+			return "", nil, errors.Wrap(&jwk.KeyNotFoundError{}, `no key ID specified in token`)
 		} else if useDefault && keyset.Len() > 1 {
-			return "", nil, errors.New(`failed to find matching key: no key ID specified in token but multiple in key set`)
+			return "", nil, errors.Wrap(&jwk.KeyNotFoundError{}, `no key ID specified in token but multiple in key set`)
 		}
 	}
 
 	var keys []jwk.Key
 	if kid == "" {
-		keys = keyset.Keys
+		keys = keyset.Keys()
 	} else {
 		keys = keyset.LookupKeyID(kid)
 	}
 	if len(keys) == 0 {
-		return "", nil, errors.Errorf(`failed to find matching key for key ID %#v in key set`, kid)
-	}
-
-	var rawKey interface{}
-	if err := keys[0].Raw(&rawKey); err != nil {
-		return "", nil, errors.Wrapf(err, `failed to construct raw key from keyset (key ID=%#v)`, kid)
+		return "", nil, &jwk.KeyNotFoundError{KeyID: kid}
 	}
 
-	return headers.Algorithm(), rawKey, nil
+	return headers.Algorithm(), keys[0], nil
 }
 
 // ParseVerify is marked to be deprecated. Please use jwt.Parse
@@ -199,6 +283,9 @@ func ParseVerify(src io.Reader, alg jwa.SignatureAlgorithm, key interface{}) (To
 // If the key is a jwk.Key and the key contains a key ID (`kid` field),
 // then it is added to the protected header generated by the signature
 //
+// The key may also be a crypto.Signer, such as keys stored in an HSM or
+// a KMS. See `jws.Sign` for details.
+//
 // The algorithm specified in the `alg` parameter must be able to support
 // the type of key you provided, otherwise an error is returned.
 //
@@ -206,8 +293,11 @@ func ParseVerify(src io.Reader, alg jwa.SignatureAlgorithm, key interface{}) (To
 // to the literal value `JWT`.
 func Sign(t Token, alg jwa.SignatureAlgorithm, key interface{}, options ...Option) ([]byte, error) {
 	var hdr jws.Headers
+	var signOptions []jws.Option
 	for _, o := range options {
 		switch o.Ident() {
+		case identContext{}:
+			signOptions = append(signOptions, jws.WithContext(o.Value().(context.Context)))
 		case identHeaders{}:
 			hdr = o.Value().(jws.Headers)
 		}
@@ -225,10 +315,64 @@ func Sign(t Token, alg jwa.SignatureAlgorithm, key interface{}, options ...Optio
 	if err := hdr.Set(`typ`, `JWT`); err != nil {
 		return nil, errors.Wrap(err, `failed to sign payload`)
 	}
-	sign, err := jws.Sign(buf, alg, key, jws.WithHeaders(hdr))
+	sign, err := jws.Sign(buf, alg, key, append(signOptions, jws.WithHeaders(hdr))...)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to sign payload`)
 	}
 
 	return sign, nil
 }
+
+// SignAndEncrypt is a convenience function to create a nested JWT: the token
+// is first signed in the same way as `jwt.Sign`, and the resulting JWS
+// message is then encrypted using `keyalg`, `key` and `contentalg`. The
+// result is serialized in JWE compact form.
+//
+// The encryption key may be either a raw key or a jwk.Key. See `jwe.Encrypt`
+// for details.
+//
+// The protected header of the JWE message will automatically have the
+// `cty` field set to the literal value `JWT`. If you would like to pass
+// other header values, use the WithEncryptHeaders option. Options for
+// `jwt.Sign` such as WithHeaders are applied to the signature.
+func SignAndEncrypt(t Token, signalg jwa.SignatureAlgorithm, signkey interface{}, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, options ...Option) ([]byte, error) {
+	ctx := context.TODO()
+	var hdr jwe.Headers
+	var encryptOptions []jwe.Option
+	for _, o := range options {
+		switch o.Ident() {
+		case identContext{}:
+			ctx = o.Value().(context.Context)
+			encryptOptions = append(encryptOptions, jwe.WithContext(ctx))
+		case identEncryptHeaders{}:
+			hdr = o.Value().(jwe.Headers)
+		}
+	}
+
+	signed, err := Sign(t, signalg, signkey, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign token`)
+	}
+
+	if hdr == nil {
+		hdr = jwe.NewHeaders()
+	} else {
+		// Do not modify the headers given by the user
+		cloned, err := hdr.Clone(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to copy headers`)
+		}
+		hdr = cloned
+	}
+
+	if err := hdr.Set(jwe.ContentTypeKey, `JWT`); err != nil {
+		return nil, errors.Wrap(err, `failed to encrypt payload`)
+	}
+
+	encrypted, err := jwe.Encrypt(signed, keyalg, key, contentalg, jwa.NoCompress, append(encryptOptions, jwe.WithProtectedHeaders(hdr))...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encrypt payload`)
+	}
+
+	return encrypted, nil
+}
@@ -72,7 +72,7 @@ func ExampleParse_JWKS() {
 			bogusKey := jwk.NewSymmetricKey()
 
 			// This key set contains two keys, the first one is the correct one
-			keyset = &jwk.Set{Keys: []jwk.Key{pubKey, bogusKey}}
+			keyset = jwk.NewSet(pubKey, bogusKey)
 		}
 
 		{ // Actual verification:
@@ -133,7 +133,7 @@ func ExampleParse_JWKS() {
 			}
 
 			// This JWKS can *only* have 1 key.
-			keyset = &jwk.Set{Keys: []jwk.Key{pubKey}}
+			keyset = jwk.NewSet(pubKey)
 		}
 
 		{
@@ -14,8 +14,11 @@ import (
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jws/sign"
+	"github.com/lestrrat-go/jwx/jws/verify"
 	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/stretchr/testify/assert"
 )
@@ -171,7 +174,7 @@ func TestJWTParseVerify(t *testing.T) {
 			}
 
 			pubkey.Set(jwk.KeyIDKey, kid)
-			t2, err := jwt.Parse(bytes.NewReader(signed), jwt.WithKeySet(&jwk.Set{Keys: []jwk.Key{pubkey}}))
+			t2, err := jwt.Parse(bytes.NewReader(signed), jwt.WithKeySet(jwk.NewSet(pubkey)))
 			if !assert.NoError(t, err, `jwt.Parse with key set should succeed`) {
 				return
 			}
@@ -191,7 +194,7 @@ func TestJWTParseVerify(t *testing.T) {
 			if err != nil {
 				t.Fatal("Failed to sign JWT")
 			}
-			_, err = jwt.Parse(bytes.NewReader(signedNoKid), jwt.WithKeySet(&jwk.Set{Keys: []jwk.Key{pubkey}}))
+			_, err = jwt.Parse(bytes.NewReader(signedNoKid), jwt.WithKeySet(jwk.NewSet(pubkey)))
 			if !assert.Error(t, err, `jwt.Parse should fail`) {
 				return
 			}
@@ -208,7 +211,7 @@ func TestJWTParseVerify(t *testing.T) {
 			if err != nil {
 				t.Fatal("Failed to sign JWT")
 			}
-			t2, err := jwt.Parse(bytes.NewReader(signedNoKid), jwt.WithKeySet(&jwk.Set{Keys: []jwk.Key{pubkey}}), jwt.UseDefaultKey(true))
+			t2, err := jwt.Parse(bytes.NewReader(signedNoKid), jwt.WithKeySet(jwk.NewSet(pubkey)), jwt.UseDefaultKey(true))
 			if !assert.NoError(t, err, `jwt.Parse with key set should succeed`) {
 				return
 			}
@@ -233,7 +236,7 @@ func TestJWTParseVerify(t *testing.T) {
 			if err != nil {
 				t.Fatal("Failed to sign JWT")
 			}
-			_, err = jwt.Parse(bytes.NewReader(signedNoKid), jwt.WithKeySet(&jwk.Set{Keys: []jwk.Key{pubkey1, pubkey2}}), jwt.UseDefaultKey(true))
+			_, err = jwt.Parse(bytes.NewReader(signedNoKid), jwt.WithKeySet(jwk.NewSet(pubkey1, pubkey2)), jwt.UseDefaultKey(true))
 			if !assert.Error(t, err, `jwt.Parse should fail`) {
 				return
 			}
@@ -277,7 +280,7 @@ func TestJWTParseVerify(t *testing.T) {
 
 		pubkey.Set(jwk.KeyIDKey, kid)
 
-		_, err = jwt.Parse(bytes.NewReader(signedButNot), jwt.WithKeySet(&jwk.Set{Keys: []jwk.Key{pubkey}}))
+		_, err = jwt.Parse(bytes.NewReader(signedButNot), jwt.WithKeySet(jwk.NewSet(pubkey)))
 		// This should fail
 		if !assert.Error(t, err, `jwt.Parse with key set + alg=none should fail`) {
 			return
@@ -285,6 +288,80 @@ func TestJWTParseVerify(t *testing.T) {
 	})
 }
 
+func TestJWTParseVerify_Algorithms(t *testing.T) {
+	t.Parallel()
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, "RSA key generated") {
+		return
+	}
+
+	kid := "test-jwt-parse-verify-algorithms"
+	hdrs := jws.NewHeaders()
+	hdrs.Set(jws.KeyIDKey, kid)
+
+	t1 := jwt.New()
+	signed, err := jwt.Sign(t1, jwa.RS256, key, jwt.WithHeaders(hdrs))
+	if !assert.NoError(t, err, "jwt.Sign should succeed") {
+		return
+	}
+
+	pubkey := jwk.NewRSAPublicKey()
+	if !assert.NoError(t, pubkey.FromRaw(&key.PublicKey)) {
+		return
+	}
+	pubkey.Set(jwk.KeyIDKey, kid)
+	set := jwk.NewSet(pubkey)
+
+	t.Run("Allowed algorithm", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.Parse(bytes.NewReader(signed), jwt.WithKeySet(set), jwt.WithAllowedSignatureAlgorithms(jwa.RS256, jwa.ES256))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+	})
+	t.Run("Disallowed algorithm", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.Parse(bytes.NewReader(signed), jwt.WithKeySet(set), jwt.WithAllowedSignatureAlgorithms(jwa.ES256))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+		_, err = jwt.Parse(bytes.NewReader(signed), jwt.WithVerify(jwa.RS256, &key.PublicKey), jwt.WithAllowedSignatureAlgorithms(jwa.ES256))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("Key declares a different algorithm", func(t *testing.T) {
+		t.Parallel()
+		pubkey := jwk.NewRSAPublicKey()
+		if !assert.NoError(t, pubkey.FromRaw(&key.PublicKey)) {
+			return
+		}
+		pubkey.Set(jwk.KeyIDKey, kid)
+		pubkey.Set(jwk.AlgorithmKey, jwa.PS256)
+		_, err := jwt.Parse(bytes.NewReader(signed), jwt.WithKeySet(jwk.NewSet(pubkey)))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("HMAC signed using the public key", func(t *testing.T) {
+		t.Parallel()
+		// Algorithm confusion: an attacker signs the token using HS256,
+		// using the (public) RSA key as the secret
+		keybuf, err := json.Marshal(pubkey)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		forged, err := jwt.Sign(t1, jwa.HS256, keybuf, jwt.WithHeaders(hdrs))
+		if !assert.NoError(t, err, "jwt.Sign should succeed") {
+			return
+		}
+		_, err = jwt.Parse(bytes.NewReader(forged), jwt.WithKeySet(set))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
+}
+
 func TestValidateClaims(t *testing.T) {
 	t.Parallel()
 	// GitHub issue #37: tokens are invalid in the second they are created (because Now() is not after IssuedAt())
@@ -472,3 +549,214 @@ func TestSignJWK(t *testing.T) {
 	signatures := header.LookupSignature("test")
 	assert.Len(t, signatures, 1)
 }
+
+func TestSignAndEncrypt(t *testing.T) {
+	t.Parallel()
+
+	signkey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	enckey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	t1 := jwt.New()
+	t1.Set(jwt.SubjectKey, "nested")
+
+	encrypted, err := jwt.SignAndEncrypt(t1, jwa.RS256, signkey, jwa.RSA_OAEP, &enckey.PublicKey, jwa.A128GCM)
+	if !assert.NoError(t, err, `jwt.SignAndEncrypt should succeed`) {
+		return
+	}
+
+	msg, err := jwe.Parse(encrypted)
+	if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "JWT", msg.ProtectedHeaders().ContentType(), `cty should be JWT`) {
+		return
+	}
+
+	t.Run("Parse without decryption key", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.Parse(bytes.NewReader(encrypted))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("Parse with decryption key", func(t *testing.T) {
+		t.Parallel()
+		t2, err := jwt.Parse(bytes.NewReader(encrypted), jwt.WithDecrypt(jwa.RSA_OAEP, enckey), jwt.WithVerify(jwa.RS256, &signkey.PublicKey))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, t1, t2, `t1 == t2`) {
+			return
+		}
+	})
+	t.Run("Parse with wrong signature key", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.Parse(bytes.NewReader(encrypted), jwt.WithDecrypt(jwa.RSA_OAEP, enckey), jwt.WithVerify(jwa.RS256, &enckey.PublicKey))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("Parse with jwk.Set", func(t *testing.T) {
+		t.Parallel()
+		kid := "test-nested-jwt"
+		hdrs := jwe.NewHeaders()
+		hdrs.Set(jwe.KeyIDKey, kid)
+		encrypted, err := jwt.SignAndEncrypt(t1, jwa.RS256, signkey, jwa.RSA_OAEP, &enckey.PublicKey, jwa.A128GCM, jwt.WithEncryptHeaders(hdrs))
+		if !assert.NoError(t, err, `jwt.SignAndEncrypt should succeed`) {
+			return
+		}
+		if _, ok := hdrs.Get(jwe.ContentTypeKey); !assert.False(t, ok, `headers passed to WithEncryptHeaders should not be modified`) {
+			return
+		}
+
+		privkey := jwk.NewRSAPrivateKey()
+		if !assert.NoError(t, privkey.FromRaw(enckey)) {
+			return
+		}
+		privkey.Set(jwk.KeyIDKey, kid)
+		set := jwk.NewSet(privkey)
+
+		t2, err := jwt.Parse(bytes.NewReader(encrypted), jwt.WithDecryptKeySet(set), jwt.WithVerify(jwa.RS256, &signkey.PublicKey))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, t1, t2, `t1 == t2`) {
+			return
+		}
+
+		_, err = jwt.Parse(bytes.NewReader(encrypted), jwt.WithDecryptKeySet(set), jwt.WithAllowedKeyEncryptionAlgorithms(jwa.RSA_OAEP_256))
+		if !assert.Error(t, err, `jwt.Parse with disallowed algorithm should fail`) {
+			return
+		}
+	})
+}
+
+type aliasSigner struct {
+	sign.Signer
+	alg jwa.SignatureAlgorithm
+}
+
+func (s aliasSigner) Algorithm() jwa.SignatureAlgorithm {
+	return s.alg
+}
+
+func TestCustomAlgorithm(t *testing.T) {
+	t.Parallel()
+	const alg = jwa.SignatureAlgorithm("X-HS256-ALIAS")
+	sharedkey := []byte("Avracadabra")
+
+	sign.RegisterSigner(alg, sign.SignerFactoryFn(func() (sign.Signer, error) {
+		s, err := sign.New(jwa.HS256)
+		if err != nil {
+			return nil, err
+		}
+		return aliasSigner{Signer: s, alg: alg}, nil
+	}))
+	verify.RegisterVerifier(alg, verify.VerifierFactoryFn(func() (verify.Verifier, error) {
+		return verify.New(jwa.HS256)
+	}))
+
+	t1 := jwt.New()
+	t1.Set(jwt.SubjectKey, "custom")
+	signed, err := jwt.Sign(t1, alg, sharedkey)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	t2, err := jwt.Parse(bytes.NewReader(signed), jwt.WithVerify(alg, sharedkey))
+	if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		return
+	}
+	if !assert.Equal(t, t1, t2, `t1 == t2`) {
+		return
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	token := jwt.New()
+	if !assert.NoError(t, token.Set(jwt.IssuerKey, "https://github.com/lestrrat-go/jwx"), `token.Set should succeed`) {
+		return
+	}
+
+	signed, err := jwt.Sign(token, jwa.RS256, key, jwt.WithContext(context.Background()))
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	_, err = jwt.Parse(bytes.NewReader(signed), jwt.WithVerify(jwa.RS256, &key.PublicKey), jwt.WithContext(context.Background()))
+	if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = jwt.Sign(token, jwa.RS256, key, jwt.WithContext(ctx))
+	if !assert.Error(t, err, `jwt.Sign should fail with a canceled context`) {
+		return
+	}
+
+	_, err = jwt.Parse(bytes.NewReader(signed), jwt.WithVerify(jwa.RS256, &key.PublicKey), jwt.WithContext(ctx))
+	if !assert.Error(t, err, `jwt.Parse should fail with a canceled context`) {
+		return
+	}
+
+	_, err = jwt.SignAndEncrypt(token, jwa.RS256, key, jwa.RSA_OAEP, &key.PublicKey, jwa.A128GCM, jwt.WithContext(ctx))
+	if !assert.Error(t, err, `jwt.SignAndEncrypt should fail with a canceled context`) {
+		return
+	}
+}
+
+func TestJWTParseWithKeyProvider(t *testing.T) {
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, "RSA key generated") {
+		return
+	}
+
+	kid := "test-jwt-parse-with-key-provider"
+	hdrs := jws.NewHeaders()
+	hdrs.Set(jws.KeyIDKey, kid)
+
+	t1 := jwt.New()
+	t1.Set(jwt.SubjectKey, "jwx")
+	signed, err := jwt.Sign(t1, jwa.RS256, key, jwt.WithHeaders(hdrs))
+	if !assert.NoError(t, err, "jwt.Sign should succeed") {
+		return
+	}
+
+	pubkey := jwk.NewRSAPublicKey()
+	if !assert.NoError(t, pubkey.FromRaw(&key.PublicKey)) {
+		return
+	}
+	pubkey.Set(jwk.KeyIDKey, kid)
+	provider := jws.KeySetProvider(jwk.NewSet(pubkey))
+
+	t2, err := jwt.Parse(bytes.NewReader(signed), jwt.WithKeyProvider(provider), jwt.WithAllowedSignatureAlgorithms(jwa.RS256))
+	if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "jwx", t2.Subject(), `subject should match`) {
+		return
+	}
+
+	_, err = jwt.Parse(bytes.NewReader(signed), jwt.WithKeyProvider(provider), jwt.WithAllowedSignatureAlgorithms(jwa.ES256))
+	if !assert.Error(t, err, `jwt.Parse should fail`) {
+		return
+	}
+
+	_, err = jwt.Parse(bytes.NewReader(signed), jwt.WithKeyProvider(jws.KeySetProvider(&jwk.Set{})))
+	if !assert.Error(t, err, `jwt.Parse should fail`) {
+		return
+	}
+}
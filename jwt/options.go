@@ -1,9 +1,11 @@
 package jwt
 
 import (
+	"context"
 	"time"
 
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
 	"github.com/lestrrat-go/jwx/jwt/openid"
@@ -16,11 +18,18 @@ type identAcceptableSkew struct{}
 type identAudience struct{}
 type identClaim struct{}
 type identClock struct{}
+type identContext struct{}
+type identDecrypt struct{}
+type identDecryptAlgorithms struct{}
+type identDecryptKeySet struct{}
 type identDefault struct{}
+type identEncryptHeaders struct{}
 type identHeaders struct{}
 type identIssuer struct{}
 type identJwtid struct{}
+type identKeyProvider struct{}
 type identKeySet struct{}
+type identSignatureAlgorithms struct{}
 type identSubject struct{}
 type identToken struct{}
 type identValidate struct{}
@@ -92,6 +101,62 @@ func WithKeySet(set *jwk.Set) ParseOption {
 	return newParseOption(identKeySet{}, set)
 }
 
+// WithKeyProvider forces the Parse method to verify the JWT message
+// using one of the keys provided by the given jws.KeyProvider. The
+// provider receives the protected headers of the JWT, and can choose
+// the candidate keys based on "kid", "x5c", etc. For example, to verify
+// JWTs against a remote key set that is periodically refreshed, use
+// `jwt.WithKeyProvider(jws.AutoRefreshProvider(ar, url))`.
+//
+// As the algorithm is taken from the JWT header, you should also restrict
+// the acceptable algorithms using the WithAllowedSignatureAlgorithms option.
+// See `jws.VerifyWithKeyProvider` for details.
+func WithKeyProvider(p jws.KeyProvider) ParseOption {
+	return newParseOption(identKeyProvider{}, p)
+}
+
+type decryptParams struct {
+	alg jwa.KeyEncryptionAlgorithm
+	key interface{}
+}
+
+// WithAllowedSignatureAlgorithms specifies the list of signature
+// algorithms that are acceptable when verifying JWTs. This is especially
+// important in conjunction with `jwt.WithKeySet`, where the algorithm is
+// taken from the JWT header. See `jws.WithAllowedAlgorithms` for details.
+func WithAllowedSignatureAlgorithms(algs ...jwa.SignatureAlgorithm) ParseOption {
+	return newParseOption(identSignatureAlgorithms{}, algs)
+}
+
+// WithDecrypt specifies the key that is used to decrypt encrypted
+// (nested) JWTs. When the token passed to the Parse method is a JWE
+// message, it is first decrypted using `alg` and `key`, and the resulting
+// payload is then parsed (and verified, if requested) as a JWT.
+//
+// The key may be either a raw key or a jwk.Key. See `jwe.Decrypt` for details.
+func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
+	return newParseOption(identDecrypt{}, &decryptParams{
+		alg: alg,
+		key: key,
+	})
+}
+
+// WithDecryptKeySet specifies the key set that is used to decrypt
+// encrypted (nested) JWTs. The key to be used is chosen by matching the
+// Key ID of the JWE message and the ID of the given keys.
+// See `jwe.DecryptWithKeySet` for details.
+func WithDecryptKeySet(set *jwk.Set) ParseOption {
+	return newParseOption(identDecryptKeySet{}, set)
+}
+
+// WithAllowedKeyEncryptionAlgorithms specifies the list of key encryption
+// algorithms that are acceptable when decrypting encrypted (nested) JWTs.
+// This is especially useful in conjunction with `jwt.WithDecryptKeySet`,
+// where the algorithm is taken from the JWE message headers.
+func WithAllowedKeyEncryptionAlgorithms(algs ...jwa.KeyEncryptionAlgorithm) ParseOption {
+	return newParseOption(identDecryptAlgorithms{}, algs)
+}
+
 // UseDefaultKey is used in conjunction with the option WithKeySet
 // to instruct the Parse method to default to the single key in a key
 // set when no Key ID is included in the JWT. If the key set contains
@@ -121,6 +186,21 @@ func WithHeaders(hdrs jws.Headers) ParseOption {
 	return newParseOption(identHeaders{}, hdrs)
 }
 
+// WithContext specifies the context.Context that is used when signing,
+// encrypting, verifying and decrypting tokens via `Sign()`,
+// `SignAndEncrypt()` and `Parse()`. See `jws.WithContext`,
+// `jwe.WithContext` and `jwe.WithDecryptContext` for details.
+func WithContext(ctx context.Context) Option {
+	return option.New(identContext{}, ctx)
+}
+
+// WithEncryptHeaders is passed to `SignAndEncrypt()` method, to allow
+// specifying arbitrary header values to be included in the protected
+// header of the jwe message
+func WithEncryptHeaders(hdrs jwe.Headers) Option {
+	return option.New(identEncryptHeaders{}, hdrs)
+}
+
 // WithValidate is passed to `Parse()` method to denote that the
 // validation of the JWT token should be performed after a successful]
 // parsing of the incoming payload.
@@ -1,8 +1,6 @@
 package jwt
 
 import (
-	"errors"
-	"fmt"
 	"time"
 )
 
@@ -24,6 +22,11 @@ func Verify(t Token, options ...ValidateOption) error {
 
 // Validate makes sure that the essential claims stand.
 //
+// If the "exp", "nbf" or "iat" claims are not satisfied, a *TimeClaimError
+// is returned, which matches ErrTokenExpired, ErrTokenNotYetValid or
+// ErrInvalidIssuedAt when used with `errors.Is()`. If any other claim
+// does not match the expected value, a *ClaimMismatchError is returned.
+//
 // See the various `WithXXX` functions for optional parameters
 // that can control the behavior of this method.
 func Validate(t Token, options ...ValidateOption) error {
@@ -57,21 +60,21 @@ func Validate(t Token, options ...ValidateOption) error {
 	// check for iss
 	if len(issuer) > 0 {
 		if v := t.Issuer(); v != "" && v != issuer {
-			return errors.New(`iss not satisfied`)
+			return &ClaimMismatchError{Claim: IssuerKey}
 		}
 	}
 
 	// check for jti
 	if len(jwtid) > 0 {
 		if v := t.JwtID(); v != "" && v != jwtid {
-			return errors.New(`jti not satisfied`)
+			return &ClaimMismatchError{Claim: JwtIDKey}
 		}
 	}
 
 	// check for sub
 	if len(subject) > 0 {
 		if v := t.Subject(); v != "" && v != subject {
-			return errors.New(`sub not satisfied`)
+			return &ClaimMismatchError{Claim: SubjectKey}
 		}
 	}
 
@@ -85,7 +88,7 @@ func Validate(t Token, options ...ValidateOption) error {
 			}
 		}
 		if !found {
-			return errors.New(`aud not satisfied`)
+			return &ClaimMismatchError{Claim: AudienceKey}
 		}
 	}
 
@@ -94,7 +97,7 @@ func Validate(t Token, options ...ValidateOption) error {
 		now := clock.Now().Truncate(time.Second)
 		ttv := tv.Truncate(time.Second)
 		if !now.Before(ttv.Add(skew)) {
-			return errors.New(`exp not satisfied`)
+			return &TimeClaimError{Claim: ExpirationKey, Value: ttv, Now: now, Skew: skew}
 		}
 	}
 
@@ -103,7 +106,7 @@ func Validate(t Token, options ...ValidateOption) error {
 		now := clock.Now().Truncate(time.Second)
 		ttv := tv.Truncate(time.Second)
 		if now.Before(ttv.Add(-1 * skew)) {
-			return errors.New(`iat not satisfied`)
+			return &TimeClaimError{Claim: IssuedAtKey, Value: ttv, Now: now, Skew: skew}
 		}
 	}
 
@@ -113,13 +116,13 @@ func Validate(t Token, options ...ValidateOption) error {
 		ttv := tv.Truncate(time.Second)
 		// now cannot be before t, so we check for now > t - skew
 		if !now.After(ttv.Add(-1 * skew)) {
-			return errors.New(`nbf not satisfied`)
+			return &TimeClaimError{Claim: NotBeforeKey, Value: ttv, Now: now, Skew: skew}
 		}
 	}
 
 	for name, expectedValue := range claimValues {
 		if v, ok := t.Get(name); !ok || v != expectedValue {
-			return fmt.Errorf(`%v not satisfied`, name)
+			return &ClaimMismatchError{Claim: name}
 		}
 	}
 
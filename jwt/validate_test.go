@@ -1,6 +1,7 @@
 package jwt_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -198,3 +199,88 @@ func TestGHIssue10(t *testing.T) {
 		}
 	})
 }
+
+func TestValidateErrors(t *testing.T) {
+	t.Parallel()
+	tm := time.Now().Truncate(time.Second)
+
+	t.Run("exp", func(t *testing.T) {
+		t.Parallel()
+		t1 := jwt.New()
+		t1.Set(jwt.ExpirationKey, tm)
+
+		clock := jwt.ClockFunc(func() time.Time { return tm.Add(time.Minute) })
+		err := jwt.Validate(t1, jwt.WithClock(clock), jwt.WithAcceptableSkew(time.Second))
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `error should match jwt.ErrTokenExpired`) {
+			return
+		}
+		if !assert.False(t, errors.Is(err, jwt.ErrTokenNotYetValid), `error should not match jwt.ErrTokenNotYetValid`) {
+			return
+		}
+
+		var terr *jwt.TimeClaimError
+		if !assert.True(t, errors.As(err, &terr), `error should be a *jwt.TimeClaimError`) {
+			return
+		}
+		if !assert.Equal(t, jwt.ExpirationKey, terr.Claim, `claim should match`) {
+			return
+		}
+		if !assert.True(t, tm.Equal(terr.Value), `exp should match`) {
+			return
+		}
+		if !assert.True(t, tm.Add(time.Minute).Equal(terr.Now), `now should match`) {
+			return
+		}
+		if !assert.Equal(t, time.Second, terr.Skew, `skew should match`) {
+			return
+		}
+	})
+	t.Run("nbf", func(t *testing.T) {
+		t.Parallel()
+		t1 := jwt.New()
+		t1.Set(jwt.NotBeforeKey, tm.Add(time.Hour))
+
+		err := jwt.Validate(t1, jwt.WithClock(jwt.ClockFunc(func() time.Time { return tm })))
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenNotYetValid), `error should match jwt.ErrTokenNotYetValid`) {
+			return
+		}
+	})
+	t.Run("iat", func(t *testing.T) {
+		t.Parallel()
+		t1 := jwt.New()
+		t1.Set(jwt.IssuedAtKey, tm.Add(time.Hour))
+
+		err := jwt.Validate(t1, jwt.WithClock(jwt.ClockFunc(func() time.Time { return tm })))
+		if !assert.True(t, errors.Is(err, jwt.ErrInvalidIssuedAt), `error should match jwt.ErrInvalidIssuedAt`) {
+			return
+		}
+	})
+	t.Run("claim mismatch", func(t *testing.T) {
+		t.Parallel()
+		t1 := jwt.New()
+		t1.Set(jwt.AudienceKey, "foo")
+		t1.Set("email", "email@example.com")
+
+		testcases := []struct {
+			Claim  string
+			Option jwt.ValidateOption
+		}{
+			{Claim: jwt.AudienceKey, Option: jwt.WithAudience("bar")},
+			{Claim: "email", Option: jwt.WithClaimValue("email", "poop")},
+		}
+		for _, tc := range testcases {
+			err := jwt.Validate(t1, tc.Option)
+			if !assert.True(t, errors.Is(err, jwt.ErrClaimMismatch), `error should match jwt.ErrClaimMismatch`) {
+				return
+			}
+
+			var cerr *jwt.ClaimMismatchError
+			if !assert.True(t, errors.As(err, &cerr), `error should be a *jwt.ClaimMismatchError`) {
+				return
+			}
+			if !assert.Equal(t, tc.Claim, cerr.Claim, `claim should match`) {
+				return
+			}
+		}
+	})
+}